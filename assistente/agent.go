@@ -0,0 +1,356 @@
+package assistente
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador"
+	"github.com/user/agente/internal/claude"
+	"github.com/user/agente/patrimonial"
+	"github.com/user/agente/patrimonial/assets"
+)
+
+// historyTurns is how many previous turns are sent to the model.
+const historyTurns = 20
+
+// QuoteFunc starts a quote request and returns a short summary for the owner.
+// images are photos of the item to attach to the supplier messages.
+// In the daemon it is comprador.Agent.StartQuote; a CLI talking to a running
+// daemon submits the request over the control socket instead.
+type QuoteFunc func(ctx context.Context, description string, urgent bool, images ...string) (string, error)
+
+// Agent is the conversational owner assistant. Its tools wrap the Comprador
+// and Patrimonial agents; anything that messages a supplier is held as a
+// pending action until the owner confirms it.
+type Agent struct {
+	claude      *claude.Client
+	store       *Store
+	comprador   *comprador.Agent
+	patrimonial *patrimonial.Agent // nil when no patrimonial DB is configured
+	quote       QuoteFunc
+}
+
+// New creates the assistant. pat may be nil.
+func New(db *sql.DB, cl *claude.Client, comp *comprador.Agent, pat *patrimonial.Agent, quote QuoteFunc) *Agent {
+	return &Agent{
+		claude:      cl,
+		store:       NewStore(db),
+		comprador:   comp,
+		patrimonial: pat,
+		quote:       quote,
+	}
+}
+
+// Reply answers one owner message in a conversation (e.g. "cli" or
+// "whatsapp:<phone>"), persisting both turns.
+func (a *Agent) Reply(ctx context.Context, conversationID, text string) (string, error) {
+	reply, err := a.reply(ctx, conversationID, text)
+	if err != nil {
+		return "", err
+	}
+	_ = a.store.Append(conversationID, "user", text)
+	_ = a.store.Append(conversationID, "assistant", reply)
+	return reply, nil
+}
+
+// Awaiting reports whether the assistant asked the owner to confirm an
+// action in the conversation, so the next message is the answer.
+func (a *Agent) Awaiting(conversationID string) bool {
+	pending, err := a.store.Pending(conversationID)
+	return err == nil && pending != nil
+}
+
+func (a *Agent) reply(ctx context.Context, conversationID, text string) (string, error) {
+	pending, err := a.store.Pending(conversationID)
+	if err != nil {
+		return "", err
+	}
+	if pending != nil {
+		switch {
+		case isYes(text):
+			_ = a.store.ClearPending(conversationID)
+			return a.execute(ctx, *pending)
+		case isNo(text):
+			_ = a.store.ClearPending(conversationID)
+			return "Ok, não enviei nada.", nil
+		default:
+			// Anything else abandons the proposal and is handled as a new message
+			_ = a.store.ClearPending(conversationID)
+		}
+	}
+
+	history, err := a.store.History(conversationID, historyTurns)
+	if err != nil {
+		return "", err
+	}
+
+	var proposed *PendingAction
+	answer, err := a.claude.ChatWithTools(ctx, claude.ChatRequest{
+		System:  a.systemPrompt(),
+		History: history,
+		User:    text,
+		Tools:   a.tools(),
+	}, func(name string, input json.RawMessage) (string, error) {
+		out, action, err := a.runTool(ctx, name, input)
+		if action != nil {
+			proposed = action
+		}
+		return out, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if proposed != nil {
+		if err := a.store.SetPending(conversationID, *proposed); err != nil {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer) + fmt.Sprintf(
+			"\n\nPosso pedir cotação aos fornecedores para: %q? (sim/não)", proposed.Description)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// execute runs a confirmed action.
+func (a *Agent) execute(ctx context.Context, action PendingAction) (string, error) {
+	switch action.Kind {
+	case "quote":
+		return a.quote(ctx, action.Description, action.Urgent, action.Images...)
+	default:
+		return "", fmt.Errorf("ação desconhecida: %s", action.Kind)
+	}
+}
+
+func (a *Agent) systemPrompt() string {
+	p := "Você é o assistente pessoal de compras e patrimônio do dono da casa. Hoje é " +
+		time.Now().Format("02/01/2006") + ". Responda em português, de forma curta (a conversa pode ser pelo WhatsApp). " +
+		"Use as ferramentas para consultar dados reais; nunca invente preços, fornecedores ou ativos. " +
+		"Para cotar, use request_quote: ela NÃO envia nada, apenas propõe o envio — o dono confirma depois."
+	if a.patrimonial == nil {
+		p += " As ferramentas de patrimônio não estão disponíveis nesta sessão."
+	}
+	return p
+}
+
+func (a *Agent) tools() []claude.ToolDef {
+	empty := map[string]any{"type": "object", "properties": map[string]any{}}
+	tools := []claude.ToolDef{
+		{
+			Name:        "request_quote",
+			Description: "Propõe pedir cotação de itens aos fornecedores. Só é enviado após confirmação do dono.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"description": map[string]any{"type": "string", "description": "Itens e quantidades, ex: 10 sacos de cimento 50kg"},
+					"urgent":      map[string]any{"type": "boolean"},
+				},
+				"required": []string{"description"},
+			},
+		},
+		{Name: "quote_status", Description: "Pedidos de cotação em aberto e respostas recebidas", InputSchema: empty},
+		{
+			Name:        "purchase_history",
+			Description: "Últimas compras realizadas",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"last": map[string]any{"type": "integer"}},
+			},
+		},
+		{Name: "list_suppliers", Description: "Fornecedores ativos com cidade e categorias", InputSchema: empty},
+	}
+	if a.patrimonial == nil {
+		return tools
+	}
+	return append(tools,
+		claude.ToolDef{Name: "list_assets", Description: "Ativos cadastrados (casa, carro, eletros) com seus IDs", InputSchema: empty},
+		claude.ToolDef{
+			Name:        "assess_assets",
+			Description: "Avalia o risco e as necessidades de manutenção de um ativo (asset_id) ou de todos",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"asset_id": map[string]any{"type": "string"}},
+			},
+		},
+		claude.ToolDef{
+			Name:        "add_maintenance",
+			Description: "Registra uma manutenção já realizada em um ativo",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"asset_id":    map[string]any{"type": "string"},
+					"description": map[string]any{"type": "string"},
+					"cost":        map[string]any{"type": "number"},
+					"supplier":    map[string]any{"type": "string"},
+					"done_at":     map[string]any{"type": "string", "description": "YYYY-MM-DD; padrão hoje"},
+				},
+				"required": []string{"asset_id", "description"},
+			},
+		},
+		claude.ToolDef{
+			Name:        "plan_asset_purchase",
+			Description: "Gera a lista de compras de um ativo e propõe cotá-la (só envia após confirmação do dono)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"asset_id": map[string]any{"type": "string"},
+					"issue":    map[string]any{"type": "string", "description": "Problema relatado, se houver"},
+				},
+				"required": []string{"asset_id"},
+			},
+		},
+	)
+}
+
+// runTool executes a tool call. Tools that would message suppliers return a
+// PendingAction instead of acting.
+func (a *Agent) runTool(ctx context.Context, name string, input json.RawMessage) (string, *PendingAction, error) {
+	var in struct {
+		Description string  `json:"description"`
+		Urgent      bool    `json:"urgent"`
+		Last        int     `json:"last"`
+		AssetID     string  `json:"asset_id"`
+		Issue       string  `json:"issue"`
+		Cost        float64 `json:"cost"`
+		Supplier    string  `json:"supplier"`
+		DoneAt      string  `json:"done_at"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &in); err != nil {
+			return "", nil, err
+		}
+	}
+
+	switch name {
+	case "request_quote":
+		if strings.TrimSpace(in.Description) == "" {
+			return "", nil, fmt.Errorf("descrição vazia")
+		}
+		action := &PendingAction{Kind: "quote", Description: in.Description, Urgent: in.Urgent}
+		return "Proposta registrada; aguardando confirmação do dono. Nada foi enviado ainda.", action, nil
+
+	case "quote_status":
+		out, err := a.comprador.StatusReport()
+		return out, nil, err
+
+	case "purchase_history":
+		if in.Last <= 0 {
+			in.Last = 10
+		}
+		out, err := a.comprador.HistoryText(in.Last)
+		return out, nil, err
+
+	case "list_suppliers":
+		sups, err := a.comprador.Suppliers()
+		if err != nil {
+			return "", nil, err
+		}
+		type sup struct {
+			Name       string   `json:"name"`
+			City       string   `json:"city"`
+			Categories []string `json:"categories"`
+		}
+		out := make([]sup, len(sups))
+		for i, s := range sups {
+			out[i] = sup{s.Name, s.City, s.Categories}
+		}
+		return toJSON(out), nil, nil
+	}
+
+	if a.patrimonial == nil {
+		return "", nil, fmt.Errorf("ferramenta desconhecida: %s", name)
+	}
+
+	switch name {
+	case "list_assets":
+		list, err := a.patrimonial.Assets()
+		if err != nil {
+			return "", nil, err
+		}
+		type asset struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			Type  string `json:"type"`
+			Brand string `json:"brand,omitempty"`
+			Model string `json:"model,omitempty"`
+		}
+		out := make([]asset, len(list))
+		for i, as := range list {
+			out[i] = asset{as.ID, as.Name, as.Type, as.Brand, as.Model}
+		}
+		return toJSON(out), nil, nil
+
+	case "assess_assets":
+		if in.AssetID != "" {
+			as, err := a.patrimonial.AssessOne(ctx, in.AssetID)
+			if err != nil {
+				return "", nil, err
+			}
+			return toJSON(as), nil, nil
+		}
+		all, err := a.patrimonial.Assess(ctx)
+		return toJSON(all), nil, err
+
+	case "add_maintenance":
+		rec := assets.MaintenanceRecord{Description: in.Description, Cost: in.Cost, Supplier: in.Supplier}
+		if in.DoneAt != "" {
+			t, err := time.Parse("2006-01-02", in.DoneAt)
+			if err != nil {
+				return "", nil, fmt.Errorf("done_at inválido: %w", err)
+			}
+			rec.DoneAt = t
+		}
+		if err := a.patrimonial.AddMaintenance(in.AssetID, rec); err != nil {
+			return "", nil, err
+		}
+		return "Manutenção registrada.", nil, nil
+
+	case "plan_asset_purchase":
+		asset, items, err := a.patrimonial.PlanPurchase(ctx, in.AssetID, in.Issue)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(items) == 0 {
+			return fmt.Sprintf("%s não precisa de nenhum item no momento.", asset.Name), nil, nil
+		}
+		desc := fmt.Sprintf("Para %s: %s", asset.Name, strings.Join(items, ", "))
+		action := &PendingAction{Kind: "quote", Description: desc, Images: asset.Photos}
+		return "Itens necessários: " + strings.Join(items, "; ") +
+			". Proposta de cotação registrada; aguardando confirmação do dono.", action, nil
+	}
+
+	return "", nil, fmt.Errorf("ferramenta desconhecida: %s", name)
+}
+
+func toJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return string(b)
+}
+
+func isYes(text string) bool {
+	switch normalize(text) {
+	case "sim", "s", "ok", "pode", "pode sim", "confirmo", "confirma", "manda", "pode mandar", "envia", "yes":
+		return true
+	}
+	return false
+}
+
+func isNo(text string) bool {
+	switch normalize(text) {
+	case "nao", "n", "cancela", "cancelar", "deixa", "no":
+		return true
+	}
+	return false
+}
+
+func normalize(text string) string {
+	text = strings.ToLower(strings.TrimSpace(text))
+	text = strings.Trim(text, ".!? ")
+	return strings.ReplaceAll(text, "ã", "a")
+}
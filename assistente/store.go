@@ -0,0 +1,101 @@
+package assistente
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/user/agente/internal/claude"
+)
+
+// PendingAction is an outward-facing action the assistant proposed and that
+// only runs after the owner confirms it.
+type PendingAction struct {
+	Kind        string   `json:"kind"` // "quote"
+	Description string   `json:"description"`
+	Urgent      bool     `json:"urgent,omitempty"`
+	Images      []string `json:"images,omitempty"`
+}
+
+// Store persists conversations and pending confirmations.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a conversation store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Append records one turn of a conversation.
+func (s *Store) Append(conversationID, role, content string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO assistant_messages (conversation_id, role, content, created_at)
+		 VALUES (?, ?, ?, ?)`,
+		conversationID, role, content, time.Now(),
+	)
+	return err
+}
+
+// History returns the last n turns of a conversation, oldest first.
+func (s *Store) History(conversationID string, n int) ([]claude.Message, error) {
+	rows, err := s.db.Query(
+		`SELECT role, content FROM (
+		   SELECT id, role, content FROM assistant_messages
+		   WHERE conversation_id = ? ORDER BY id DESC LIMIT ?
+		 ) ORDER BY id`, conversationID, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []claude.Message
+	for rows.Next() {
+		var m claude.Message
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// SetPending replaces the action awaiting confirmation in a conversation.
+func (s *Store) SetPending(conversationID string, action PendingAction) error {
+	raw, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO assistant_pending (conversation_id, action, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT(conversation_id) DO UPDATE SET action = excluded.action, created_at = excluded.created_at`,
+		conversationID, string(raw), time.Now(),
+	)
+	return err
+}
+
+// Pending returns the action awaiting confirmation, or nil.
+func (s *Store) Pending(conversationID string) (*PendingAction, error) {
+	var raw string
+	err := s.db.QueryRow(
+		`SELECT action FROM assistant_pending WHERE conversation_id = ?`, conversationID,
+	).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var action PendingAction
+	if err := json.Unmarshal([]byte(raw), &action); err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// ClearPending drops the action awaiting confirmation.
+func (s *Store) ClearPending(conversationID string) error {
+	_, err := s.db.Exec(`DELETE FROM assistant_pending WHERE conversation_id = ?`, conversationID)
+	return err
+}
@@ -3,18 +3,30 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/user/agente/assistente"
 	"github.com/user/agente/comprador"
+	"github.com/user/agente/comprador/memory"
+	"github.com/user/agente/comprador/messages"
+	"github.com/user/agente/comprador/simulator"
 	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/alert"
 	"github.com/user/agente/internal/claude"
 	"github.com/user/agente/internal/db"
 	"github.com/user/agente/internal/whatsapp"
+	"github.com/user/agente/patrimonial"
 )
 
 func main() {
@@ -32,6 +44,9 @@ func rootCmd() *cobra.Command {
 		waDBPath    string
 		timeout     int
 		ownerPhone  string
+		socketPath  string
+		patDBPath   string
+		estimate    bool
 	)
 
 	root := &cobra.Command{
@@ -54,8 +69,12 @@ func rootCmd() *cobra.Command {
 	root.PersistentFlags().StringVar(&waDBPath, "wa-db", "data/whatsapp.db", "Caminho do banco de sessão WhatsApp")
 	root.PersistentFlags().IntVar(&timeout, "timeout", 30, "Timeout de cotação em minutos")
 	root.PersistentFlags().StringVar(&ownerPhone, "owner", "", "Telefone do dono para notificação (ex: 5567999990000). Padrão: env OWNER_PHONE")
+	root.PersistentFlags().StringVar(&socketPath, "socket", comprador.DefaultSocketPath, "Socket de controle do daemon (comprador serve)")
+	root.PersistentFlags().StringVar(&patDBPath, "patrimonial-db", "", "Banco do Patrimonial usado pelo assistente. Padrão: env PATRIMONIAL_DB ou data/patrimonial.db")
 
-	buildAgent := func(cmd *cobra.Command, ctx context.Context) (*comprador.Agent, error) {
+	// openAgent builds the agent; connect=false keeps the mock sender, which is
+	// what clients of a running daemon want (the daemon owns the WhatsApp session).
+	openAgent := func(ctx context.Context, connect bool) (*comprador.Agent, error) {
 		database, err := db.Open(dbPath)
 		if err != nil {
 			return nil, fmt.Errorf("open db: %w", err)
@@ -73,28 +92,76 @@ func rootCmd() *cobra.Command {
 		}
 
 		cfg := comprador.Config{
+			Throttle:     throttleConfig(dryRun),
 			City:         city,
 			QuoteTimeout: time.Duration(timeout) * time.Minute,
 			DryRun:       dryRun,
 			WhatsAppDB:   waDBPath,
 			OwnerPhone:   owner,
 			AutoConfirm:  yes,
+
+			Group:         viper.GetString("WA_GROUP"),
+			ApprovalLimit: viper.GetFloat64("GROUP_APPROVAL_LIMIT"),
+		}
+		if days := viper.GetFloat64("DELIVERY_DAYS"); days > 0 {
+			cfg.DeliveryDelay = time.Duration(days * float64(24*time.Hour))
+		}
+		cfg.MaxRadius = viper.GetFloat64("MAX_RADIUS_KM")
+		cfg.EstimateFirst = estimate || viper.GetBool("ESTIMATE_FIRST")
+		if days := viper.GetFloat64("PRICE_MAX_AGE_DAYS"); days > 0 {
+			cfg.PriceMaxAge = time.Duration(days * float64(24*time.Hour))
 		}
+		cfg.MaxSuppliers = viper.GetInt("MAX_SUPPLIERS")
+		cfg.Strategy = viper.GetString("SUPPLIER_STRATEGY")
+		if err := comprador.CheckStrategy(cfg.Strategy); err != nil {
+			return nil, fmt.Errorf("SUPPLIER_STRATEGY: %w", err)
+		}
+		cfg.Explore = viper.GetFloat64("SUPPLIER_EXPLORE")
+		cfg.WeeklyLimit = viper.GetInt("SUPPLIER_WEEKLY_LIMIT")
 
 		agent := comprador.New(database, cl, cfg)
 
-		if !dryRun {
-			// Connect real WhatsApp (shows QR on first run)
-			realSender, err := whatsapp.NewRealSender(ctx, waDBPath)
+		if connect {
+			sender, err := connectSender(ctx, waDBPath)
 			if err != nil {
-				return nil, fmt.Errorf("whatsapp: %w", err)
+				return nil, err
 			}
-			agent.SetSender(realSender)
+			agent.SetSender(sender)
 		}
 
 		return agent, nil
 	}
 
+	buildAgent := func(cmd *cobra.Command, ctx context.Context) (*comprador.Agent, error) {
+		return openAgent(ctx, !dryRun)
+	}
+
+	// openAssistant builds the conversational assistant over both agents.
+	openAssistant := func(agent *comprador.Agent, quote assistente.QuoteFunc) (*assistente.Agent, error) {
+		database, err := db.Open(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("open db: %w", err)
+		}
+		cl, err := claude.New()
+		if err != nil {
+			return nil, err
+		}
+
+		path := patDBPath
+		if path == "" {
+			path = viper.GetString("PATRIMONIAL_DB")
+		}
+		if path == "" {
+			path = "data/patrimonial.db"
+		}
+		patDB, err := db.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open patrimonial db: %w", err)
+		}
+
+		return assistente.New(database, cl, agent, patrimonial.New(patDB, cl), quote), nil
+	}
+
 	// quote command
 	quoteCmd := &cobra.Command{
 		Use:   "quote [descrição]",
@@ -102,14 +169,236 @@ func rootCmd() *cobra.Command {
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			urgent, _ := cmd.Flags().GetBool("urgent")
+			foreground, _ := cmd.Flags().GetBool("foreground")
+			images, _ := cmd.Flags().GetStringArray("image")
+			simulate, _ := cmd.Flags().GetBool("simulate")
+			bypassHours, _ := cmd.Flags().GetBool("bypass-hours")
+			from, _ := cmd.Flags().GetString("from")
+			maxRadius, _ := cmd.Flags().GetFloat64("max-radius")
+			maxSuppliers, _ := cmd.Flags().GetInt("max-suppliers")
+			strategy, _ := cmd.Flags().GetString("strategy")
+			description := strings.Join(args, " ")
+			if simulate && !dryRun {
+				return fmt.Errorf("--simulate só funciona com --dry-run")
+			}
+			if bypassHours && !urgent {
+				return fmt.Errorf("--bypass-hours só vale para pedidos --urgent")
+			}
+			if maxSuppliers < 0 {
+				return fmt.Errorf("--max-suppliers negativo")
+			}
+			if err := comprador.CheckStrategy(strategy); err != nil {
+				return err
+			}
+
+			// Absolute paths, so a daemon running elsewhere can read the files
+			for i, img := range images {
+				if _, err := os.Stat(img); err != nil {
+					return fmt.Errorf("imagem: %w", err)
+				}
+				if abs, err := filepath.Abs(img); err == nil {
+					images[i] = abs
+				}
+			}
+
+			// A dry run stays in this process: the daemon sends for real
+			if !foreground && !simulate && !dryRun {
+				if client, err := comprador.DialControl(socketPath); err == nil {
+					// Daemon running: confirm items here, let the daemon send and wait
+					agent, err := openAgent(cmd.Context(), false)
+					if err != nil {
+						return err
+					}
+					req, err := agent.Prepare(cmd.Context(), description, urgent, images...)
+					if err != nil {
+						return err
+					}
+					req.BypassHours = bypassHours
+					req.MaxSuppliers, req.Strategy = maxSuppliers, strategy
+					if err := agent.SetPlace(req, from, maxRadius); err != nil {
+						return err
+					}
+					id, err := client.Submit(req)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Pedido entregue ao daemon (ID: %s).\n", id)
+					fmt.Println("Acompanhe com 'comprador status'.")
+					return nil
+				}
+			}
+
 			agent, err := buildAgent(cmd, cmd.Context())
 			if err != nil {
 				return err
 			}
-			return agent.Quote(cmd.Context(), strings.Join(args, " "), urgent)
+			defer agent.Close()
+			if simulate {
+				personas, _ := cmd.Flags().GetString("personas")
+				seed, _ := cmd.Flags().GetInt64("seed")
+				if err := setupSimulator(cmd.Context(), agent, personas, seed); err != nil {
+					return err
+				}
+			}
+			req, err := agent.Prepare(cmd.Context(), description, urgent, images...)
+			if err != nil {
+				return err
+			}
+			req.BypassHours = bypassHours
+			req.MaxSuppliers, req.Strategy = maxSuppliers, strategy
+			if err := agent.SetPlace(req, from, maxRadius); err != nil {
+				return err
+			}
+			return agent.Execute(cmd.Context(), req)
 		},
 	}
+	quoteCmd.Flags().Bool("simulate", false, "Simular respostas dos fornecedores em tempo virtual (requer --dry-run)")
+	quoteCmd.Flags().String("personas", "data/personas.yaml", "Personas dos fornecedores simulados (gerado pelo LLM se não existir)")
+	quoteCmd.Flags().Int64("seed", 0, "Semente da simulação (0 = aleatória)")
 	quoteCmd.Flags().Bool("urgent", false, "Cotação urgente (timeout 5 min)")
+	quoteCmd.Flags().Bool("bypass-hours", false, "Contactar fornecedores mesmo fora do horário de atendimento (requer --urgent)")
+	quoteCmd.Flags().StringArray("image", nil, "Foto do item a anexar ao pedido (repetível)")
+	quoteCmd.Flags().Bool("foreground", false, "Executar a cotação neste terminal mesmo com o daemon ativo")
+	quoteCmd.Flags().String("from", "", "Local de entrega, de 'comprador locations' (padrão: o local principal)")
+	quoteCmd.Flags().BoolVar(&estimate, "estimate", false, "Mostrar antes os preços já cotados e não consultar quem tem preço recente (padrão: env ESTIMATE_FIRST)")
+	quoteCmd.Flags().Float64("max-radius", 0, "Só cotar com fornecedores a até N km do local de entrega (padrão: env MAX_RADIUS_KM; 0 = qualquer distância)")
+	quoteCmd.Flags().Int("max-suppliers", 0, "Máximo de fornecedores consultados (padrão: env MAX_SUPPLIERS ou 5)")
+	quoteCmd.Flags().String("strategy", "", "Como escolher os fornecedores quando há mais que o máximo: "+strings.Join(comprador.Strategies, ", ")+" (padrão: env SUPPLIER_STRATEGY ou top-rated)")
+
+	// serve command
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Rodar como daemon: ouve o WhatsApp e gerencia todos os pedidos abertos",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			agent, err := buildAgent(cmd, ctx)
+			if err != nil {
+				return err
+			}
+			defer agent.Close()
+			asst, err := openAssistant(agent, agent.StartQuote)
+			if err != nil {
+				return err
+			}
+			agent.SetOwnerAssistant(func(ctx context.Context, text string) (string, error) {
+				return asst.Reply(ctx, "whatsapp", text)
+			}, func() bool { return asst.Awaiting("whatsapp") })
+			srv := comprador.NewServer(agent, socketPath)
+			if url := viper.GetString("ALERT_WEBHOOK"); url != "" {
+				srv.SetAlerter(alert.NewWebhook(url))
+			}
+			fmt.Printf("Daemon ativo (socket: %s). Ctrl+C para encerrar.\n", socketPath)
+			return srv.Run(ctx)
+		},
+	}
+
+	// chat command
+	chatCmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Conversar com o assistente (compras e patrimônio) em linguagem natural",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			yes = true // the chat itself is the confirmation step; no item prompt
+
+			agent, err := openAgent(ctx, false)
+			if err != nil {
+				return err
+			}
+
+			// Connected on the first quote sent from this process, and kept
+			// for the rest of the chat
+			var sender whatsapp.MessageSender
+			defer func() {
+				if sender != nil {
+					sender.Close()
+				}
+			}()
+
+			// Confirmed quotes go to the daemon when it is running; otherwise
+			// they are sent from this process. A dry run never reaches the
+			// daemon, which sends for real.
+			quote := func(ctx context.Context, description string, urgent bool, images ...string) (string, error) {
+				req, err := agent.Prepare(ctx, description, urgent, images...)
+				if err != nil {
+					return "", err
+				}
+				if !dryRun {
+					if client, err := comprador.DialControl(socketPath); err == nil {
+						id, err := client.Submit(req)
+						if err != nil {
+							return "", err
+						}
+						return fmt.Sprintf("Cotação entregue ao daemon (ID: %s).", id), nil
+					}
+				}
+				if !dryRun && sender == nil {
+					if sender, err = connectSender(ctx, waDBPath); err != nil {
+						return "", err
+					}
+					agent.SetSender(sender)
+				}
+				n, err := agent.Dispatch(ctx, req)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Cotação enviada para %d fornecedor(es). Rode 'comprador serve' para receber as respostas.", n), nil
+			}
+
+			asst, err := openAssistant(agent, quote)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Assistente pronto. Digite sua mensagem (Ctrl+D para sair).")
+			reader := bufio.NewReader(os.Stdin)
+			agent.SetInput(reader) // prompts of a quote read the same stdin
+			for {
+				fmt.Print("\n> ")
+				line, err := reader.ReadString('\n')
+				line = strings.TrimSpace(line)
+				if line != "" {
+					reply, rerr := asst.Reply(ctx, "cli", line)
+					if rerr != nil {
+						fmt.Printf("[erro] %v\n", rerr)
+					} else {
+						fmt.Println(reply)
+					}
+				}
+				if err != nil {
+					fmt.Println()
+					return nil
+				}
+			}
+		},
+	}
+
+	// status command
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Exibir pedidos de cotação em aberto",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if client, err := comprador.DialControl(socketPath); err == nil {
+				report, err := client.Status()
+				if err != nil {
+					return err
+				}
+				fmt.Println(report)
+				return nil
+			}
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			report, err := agent.StatusReport()
+			if err != nil {
+				return err
+			}
+			fmt.Println(report)
+			return nil
+		},
+	}
 
 	// suppliers commands
 	suppliersCmd := &cobra.Command{
@@ -119,32 +408,386 @@ func rootCmd() *cobra.Command {
 
 	suppliersAddCmd := &cobra.Command{
 		Use:   "add",
-		Short: "Cadastrar novo fornecedor",
+		Short: "Cadastrar novo fornecedor (assistente interativo, ou --name e demais flags)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			agent, err := buildAgent(cmd, cmd.Context())
+			agent, err := openAgent(cmd.Context(), false)
 			if err != nil {
 				return err
 			}
-			sup, err := promptSupplier()
-			if err != nil {
+			if !cmd.Flags().Changed("name") {
+				sup, err := promptSupplier()
+				if err != nil {
+					return err
+				}
+				return agent.AddSupplier(sup)
+			}
+			sup := suppliers.Supplier{City: city, Rating: 5, Active: true}
+			if _, err := applySupplierFlags(cmd, &sup); err != nil {
 				return err
 			}
 			return agent.AddSupplier(sup)
 		},
 	}
+	supplierFlags(suppliersAddCmd)
+
+	suppliersEditCmd := &cobra.Command{
+		Use:   "edit <fornecedor>",
+		Short: "Alterar dados de um fornecedor (só os campos informados)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			sup, err := agent.FindSupplier(args[0])
+			if err != nil {
+				return err
+			}
+			changed, err := applySupplierFlags(cmd, sup)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return fmt.Errorf("nada a alterar; veja 'comprador suppliers edit --help'")
+			}
+			if err := agent.UpdateSupplier(*sup); err != nil {
+				return err
+			}
+			fmt.Printf("Fornecedor atualizado: %s\n", sup.Name)
+			return nil
+		},
+	}
+	supplierFlags(suppliersEditCmd)
+
+	setActive := func(active bool) func(cmd *cobra.Command, args []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			sup, err := agent.SetSupplierActive(args[0], active)
+			if err != nil {
+				return err
+			}
+			if active {
+				fmt.Printf("%s ativado.\n", sup.Name)
+			} else {
+				fmt.Printf("%s desativado: não recebe novas cotações.\n", sup.Name)
+			}
+			return nil
+		}
+	}
+	suppliersActivateCmd := &cobra.Command{
+		Use:   "activate <fornecedor>",
+		Short: "Reativar um fornecedor",
+		Args:  cobra.ExactArgs(1),
+		RunE:  setActive(true),
+	}
+	suppliersDeactivateCmd := &cobra.Command{
+		Use:   "deactivate <fornecedor>",
+		Short: "Desativar um fornecedor (deixa de receber cotações)",
+		Args:  cobra.ExactArgs(1),
+		RunE:  setActive(false),
+	}
+
+	suppliersRemoveCmd := &cobra.Command{
+		Use:   "remove <fornecedor>",
+		Short: "Remover um fornecedor (o histórico de cotações é mantido)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			sup, err := agent.RemoveSupplier(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s removido; cotações e mensagens anteriores continuam no histórico.\n", sup.Name)
+			return nil
+		},
+	}
+
+	suppliersShowCmd := &cobra.Command{
+		Use:   "show <fornecedor>",
+		Short: "Exibir o cadastro e o histórico de um fornecedor",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			p, err := agent.Profile(args[0])
+			if err != nil {
+				return err
+			}
+			printProfile(p)
+			return nil
+		},
+	}
 
 	suppliersListCmd := &cobra.Command{
 		Use:   "list",
 		Short: "Listar fornecedores ativos",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
 			agent, err := buildAgent(cmd, cmd.Context())
 			if err != nil {
 				return err
 			}
-			return agent.ListSuppliers()
+			defer agent.Close()
+			return agent.ListSuppliers(all)
+		},
+	}
+	suppliersListCmd.Flags().Bool("all", false, "Incluir fornecedores inativos")
+	suppliersStatsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Entrega e leitura das mensagens enviadas a cada fornecedor",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			stats, err := agent.SupplierStats()
+			if err != nil {
+				return err
+			}
+			printSupplierStats(stats)
+			return nil
+		},
+	}
+	suppliersImportCmd := &cobra.Command{
+		Use:   "import <arquivo>",
+		Short: "Importar fornecedores de CSV, JSON ou vCard (atualiza os já cadastrados pelo telefone)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			cats, _ := cmd.Flags().GetStringSlice("categories")
+			country, _ := cmd.Flags().GetString("country-code")
+			format, err := suppliers.FormatOf(args[0], format)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			recs, err := suppliers.ReadRecords(f, format)
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			items, err := agent.ImportSuppliers(recs, suppliers.ImportOptions{
+				City: city, Categories: cats, CountryCode: country,
+			}, dryRun)
+			printImport(items, dryRun)
+			return err
+		},
+	}
+	suppliersImportCmd.Flags().String("format", "", "Formato: csv, json ou vcard (padrão: pela extensão do arquivo)")
+	suppliersImportCmd.Flags().Bool("dry-run", false, "Só mostrar o que mudaria, sem gravar")
+	suppliersImportCmd.Flags().StringSlice("categories", nil, "Categorias dos fornecedores novos que não trazem nenhuma (ex: contatos do celular)")
+	suppliersImportCmd.Flags().String("country-code", suppliers.DefaultCountryCode, "DDI acrescentado aos telefones sem ele")
+
+	suppliersExportCmd := &cobra.Command{
+		Use:   "export [arquivo]",
+		Short: "Exportar fornecedores em CSV, JSON ou vCard (sem arquivo, na saída padrão)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			all, _ := cmd.Flags().GetBool("all")
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if path == "" && format == "" {
+				format = suppliers.FormatCSV
+			}
+			format, err := suppliers.FormatOf(path, format)
+			if err != nil {
+				return err
+			}
+
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			recs, err := agent.ExportSuppliers(all)
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				return suppliers.WriteRecords(os.Stdout, format, recs)
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			if err := suppliers.WriteRecords(f, format, recs); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Printf("%d fornecedores exportados para %s\n", len(recs), path)
+			return nil
+		},
+	}
+	suppliersExportCmd.Flags().String("format", "", "Formato: csv, json ou vcard (padrão: pela extensão do arquivo, ou csv)")
+	suppliersExportCmd.Flags().Bool("all", false, "Incluir fornecedores inativos")
+
+	suppliersDedupeCmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Encontrar fornecedores duplicados (mesmo telefone ou nome parecido) e uni-los",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			groups, err := agent.Duplicates()
+			if err != nil {
+				return err
+			}
+			if len(groups) == 0 {
+				fmt.Println("Nenhum fornecedor duplicado.")
+				return nil
+			}
+			reader := bufio.NewReader(os.Stdin)
+			merged := 0
+			for i, g := range groups {
+				fmt.Printf("\n[%d/%d] %s\n", i+1, len(groups), strings.Join(g.Reasons, ", "))
+				for j, s := range g.Suppliers {
+					mark := "  "
+					if j == 0 {
+						mark = "* "
+					}
+					state := ""
+					if !s.Active {
+						state = " (inativo)"
+					}
+					fmt.Printf("  %s%-40s %-15s %s%s\n", mark, s.Name, s.Phone, s.City, state)
+				}
+				result := suppliers.Merged(g.Suppliers[0], g.Suppliers[1:])
+				fmt.Printf("  → %s (%s)", result.Name, result.Phone)
+				if len(result.Branches) > 0 {
+					fmt.Printf(", lojas: %s", strings.Join(result.Branches, "; "))
+				}
+				fmt.Println()
+				if !yes {
+					fmt.Print("  Unir (o * fica; cotações e compras dos outros passam para ele)? [s/N] ")
+					answer, _ := reader.ReadString('\n')
+					if a := strings.ToLower(strings.TrimSpace(answer)); a != "s" && a != "sim" {
+						continue
+					}
+				}
+				if _, err := agent.MergeSuppliers(g.Suppliers[0], g.Suppliers[1:]); err != nil {
+					fmt.Printf("  erro: %v\n", err)
+					continue
+				}
+				merged++
+			}
+			fmt.Printf("\n%d de %d grupos unidos.\n", merged, len(groups))
+			return nil
+		},
+	}
+
+	suppliersRateCmd := &cobra.Command{
+		Use:   "rate <fornecedor> <1-5> [comentário]",
+		Short: "Avaliar um fornecedor depois de uma compra (entra no cálculo da nota)",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rating, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("nota inválida %q: use de 1 a 5", args[1])
+			}
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			sup, err := agent.RateSupplier(args[0], rating, strings.Join(args[2:], " "))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Avaliação registrada para %s.\n", sup.Name)
+			return nil
+		},
+	}
+
+	suppliersRescoreCmd := &cobra.Command{
+		Use:   "rescore",
+		Short: "Recalcular as notas dos fornecedores pelo histórico (o daemon faz isso a cada 6h)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			scores, err := agent.Rescore()
+			if err != nil {
+				return err
+			}
+			if len(scores) == 0 {
+				fmt.Println("Nenhum fornecedor com histórico de cotações ou avaliações.")
+				return nil
+			}
+			for _, sc := range scores {
+				name := sc.SupplierID
+				if sup, err := agent.FindSupplier(sc.SupplierID); err == nil {
+					name = sup.Name
+				}
+				fmt.Printf("%.1f  %s\n", sc.Rating, name)
+				printScore(sc, "     ")
+			}
+			return nil
+		},
+	}
+
+	suppliersGeocodeCmd := &cobra.Command{
+		Use:   "geocode <arquivo>",
+		Short: "Localizar fornecedores pelo endereço, num arquivo offline de coordenadas (endereço;latitude;longitude)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overwrite, _ := cmd.Flags().GetBool("overwrite")
+			g, err := readGazetteer(args[0])
+			if err != nil {
+				return err
+			}
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			results, err := agent.GeocodeSuppliers(g, overwrite)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				fmt.Println("Nenhum fornecedor com endereço a localizar (cadastre com --street).")
+				return nil
+			}
+			found := 0
+			for _, r := range results {
+				if r.Found {
+					found++
+					fmt.Printf("✓ %-30s %s → %s\n", r.Supplier.Name, r.Supplier.Street, r.Supplier.Geo)
+				} else {
+					fmt.Printf("? %-30s %s: não encontrado\n", r.Supplier.Name, r.Supplier.Street)
+				}
+			}
+			fmt.Printf("\n%d de %d fornecedores localizados.\n", found, len(results))
+			return nil
 		},
 	}
-	suppliersCmd.AddCommand(suppliersAddCmd, suppliersListCmd)
+	suppliersGeocodeCmd.Flags().Bool("overwrite", false, "Refazer também os fornecedores que já têm coordenadas")
+
+	suppliersCmd.AddCommand(suppliersAddCmd, suppliersEditCmd, suppliersShowCmd, suppliersListCmd, suppliersStatsCmd,
+		suppliersActivateCmd, suppliersDeactivateCmd, suppliersRemoveCmd, suppliersImportCmd, suppliersExportCmd,
+		suppliersDedupeCmd, suppliersRateCmd, suppliersRescoreCmd, suppliersGeocodeCmd)
 
 	// history command
 	historyCmd := &cobra.Command{
@@ -156,11 +799,64 @@ func rootCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			defer agent.Close()
 			return agent.History(cmd.Context(), n)
 		},
 	}
 	historyCmd.Flags().Int("last", 10, "Número de compras a exibir")
 
+	// feedback command
+	feedbackCmd := &cobra.Command{
+		Use:   "feedback",
+		Short: "Avaliar as compras entregues (nota de 1 a 5 e comentário para o fornecedor)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			pending, err := agent.PendingFeedback(!all)
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				fmt.Println("Nenhuma compra aguardando avaliação.")
+				return nil
+			}
+			reader := bufio.NewReader(os.Stdin)
+			rated := 0
+			for i, p := range pending {
+				fmt.Printf("\n[%d/%d] %s\n", i+1, len(pending), p.Description)
+				fmt.Printf("  Fornecedor: %s | Total: R$ %.2f | Entrega prevista: %s\n",
+					p.ChosenSupplier, p.TotalPrice, p.DeliveryDue.Format("02/01/2006"))
+				fmt.Print("  Nota (1-5, Enter para pular): ")
+				line, err := reader.ReadString('\n')
+				line = strings.TrimSpace(line)
+				if line == "" {
+					if err != nil {
+						break
+					}
+					continue
+				}
+				rating, convErr := strconv.Atoi(line)
+				if convErr != nil || rating < 1 || rating > 5 {
+					fmt.Printf("  nota %q inválida: use de 1 a 5\n", line)
+					continue
+				}
+				fmt.Print("  Comentário (opcional): ")
+				comment, _ := reader.ReadString('\n')
+				if err := agent.RatePurchase(p, rating, strings.TrimSpace(comment)); err != nil {
+					fmt.Printf("  erro: %v\n", err)
+					continue
+				}
+				rated++
+			}
+			fmt.Printf("\n%d de %d compras avaliadas.\n", rated, len(pending))
+			return nil
+		},
+	}
+	feedbackCmd.Flags().Bool("all", false, "Incluir compras com entrega ainda não prevista para hoje")
+
 	// repeat command
 	repeatCmd := &cobra.Command{
 		Use:   "repeat",
@@ -170,12 +866,970 @@ func rootCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			defer agent.Close()
 			return agent.RepeatLast(cmd.Context())
 		},
 	}
 
-	root.AddCommand(quoteCmd, suppliersCmd, historyCmd, repeatCmd)
-	return root
+	// inbox commands
+	inboxCmd := &cobra.Command{
+		Use:   "inbox",
+		Short: "Conversas com fornecedores e contatos desconhecidos",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unknown, _ := cmd.Flags().GetBool("unknown")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			convs, err := agent.Inbox()
+			if err != nil {
+				return err
+			}
+			printInbox(convs, unknown)
+			return nil
+		},
+	}
+	inboxCmd.Flags().Bool("unknown", false, "Mostrar só remetentes que não são fornecedores")
+
+	inboxShowCmd := &cobra.Command{
+		Use:   "show <contato>",
+		Short: "Exibir a conversa com um contato (nome, telefone ou endereço)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, _ := cmd.Flags().GetInt("last")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			addr, msgs, err := agent.Thread(strings.Join(args, " "), n)
+			if err != nil {
+				return err
+			}
+			printThread(addr, msgs)
+			return nil
+		},
+	}
+	inboxShowCmd.Flags().Int("last", 30, "Número de mensagens a exibir")
+
+	inboxReplyCmd := &cobra.Command{
+		Use:   "reply <contato> <mensagem>",
+		Short: "Responder manualmente a um contato",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, text := args[0], strings.Join(args[1:], " ")
+			// The daemon owns the WhatsApp session; let it send, unless
+			// this is a dry run
+			if !dryRun {
+				if client, err := comprador.DialControl(socketPath); err == nil {
+					if err := client.Send(to, text); err != nil {
+						return err
+					}
+					fmt.Println("Mensagem enviada pelo daemon.")
+					return nil
+				}
+			}
+			agent, err := buildAgent(cmd, cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer agent.Close()
+			if err := agent.Reply(to, text); err != nil {
+				return err
+			}
+			fmt.Println("Mensagem enviada.")
+			return nil
+		},
+	}
+
+	inboxAssignCmd := &cobra.Command{
+		Use:   "assign <id-mensagem>",
+		Short: "Atribuir uma mensagem recebida a um fornecedor (existente ou novo) e pedido",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			msgID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+			if err != nil {
+				return fmt.Errorf("id de mensagem inválido: %s", args[0])
+			}
+			supplier, _ := cmd.Flags().GetString("supplier")
+			requestID, _ := cmd.Flags().GetString("request")
+			newName, _ := cmd.Flags().GetString("new")
+			if (supplier == "") == (newName == "") {
+				return fmt.Errorf("informe --supplier <id ou nome> ou --new <nome>")
+			}
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			if supplier != "" {
+				if err := agent.AssignMessage(msgID, supplier, requestID); err != nil {
+					return err
+				}
+				fmt.Println("Mensagem atribuída.")
+				return nil
+			}
+
+			supCity, _ := cmd.Flags().GetString("city")
+			cats, _ := cmd.Flags().GetStringSlice("categories")
+			if supCity == "" {
+				supCity = city
+			}
+			id, err := agent.SupplierFromMessage(msgID, suppliers.Supplier{Name: newName, City: supCity, Categories: cats, Rating: 5}, requestID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Fornecedor %s cadastrado (ID: %s) e mensagem atribuída.\n", newName, id)
+			return nil
+		},
+	}
+	inboxAssignCmd.Flags().String("supplier", "", "Fornecedor existente (ID ou nome)")
+	inboxAssignCmd.Flags().String("new", "", "Cadastrar o remetente como novo fornecedor com este nome")
+	inboxAssignCmd.Flags().String("request", "", "Pedido ao qual a mensagem responde (ID)")
+	inboxAssignCmd.Flags().String("city", "", "Cidade do novo fornecedor (padrão: --city)")
+	inboxAssignCmd.Flags().StringSlice("categories", nil, "Categorias do novo fornecedor (separadas por vírgula)")
+	inboxCmd.AddCommand(inboxShowCmd, inboxReplyCmd, inboxAssignCmd)
+
+	// owner places commands
+	locationsCmd := &cobra.Command{
+		Use:   "locations",
+		Short: "Locais de entrega do dono (casa, sítio...), de onde se mede a distância aos fornecedores",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			places, err := agent.Places()
+			if err != nil {
+				return err
+			}
+			if len(places) == 0 {
+				fmt.Println("Nenhum local cadastrado. Cadastre com 'comprador locations add casa --location <lat,lng>'.")
+				return nil
+			}
+			for _, p := range places {
+				mark := "  "
+				if p.Default {
+					mark = "* "
+				}
+				fmt.Printf("%s%-15s %-25s %s\n", mark, p.Name, p.Geo, p.Address)
+			}
+			return nil
+		},
+	}
+	locationsAddCmd := &cobra.Command{
+		Use:   "add <nome>",
+		Short: "Cadastrar (ou atualizar) um local, por coordenadas ou pelo endereço num arquivo de coordenadas",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			address, _ := cmd.Flags().GetString("address")
+			loc, _ := cmd.Flags().GetString("location")
+			geoFile, _ := cmd.Flags().GetString("geo-file")
+			def, _ := cmd.Flags().GetBool("default")
+			p := comprador.Place{Name: args[0], Address: strings.TrimSpace(address), Default: def}
+			switch {
+			case loc != "":
+				var err error
+				if p.Geo, err = suppliers.ParsePoint(loc); err != nil {
+					return err
+				}
+			case geoFile != "" && p.Address != "":
+				g, err := readGazetteer(geoFile)
+				if err != nil {
+					return err
+				}
+				pt, ok := g.Lookup(p.Address)
+				if !ok {
+					return fmt.Errorf("endereço %q não encontrado em %s; informe --location", p.Address, geoFile)
+				}
+				p.Geo = pt
+			default:
+				return fmt.Errorf("informe --location, ou --address com --geo-file")
+			}
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			if err := agent.SavePlace(p); err != nil {
+				return err
+			}
+			fmt.Printf("Local %s salvo (%s).\n", p.Name, p.Geo)
+			return nil
+		},
+	}
+	locationsAddCmd.Flags().String("address", "", "Endereço")
+	locationsAddCmd.Flags().String("location", "", "Coordenadas latitude,longitude (ex: -20.4697,-54.6201)")
+	locationsAddCmd.Flags().String("geo-file", "", "Arquivo de coordenadas onde procurar o --address")
+	locationsAddCmd.Flags().Bool("default", false, "Tornar o local principal (o primeiro cadastrado já é)")
+	locationsDefaultCmd := &cobra.Command{
+		Use:   "default <nome>",
+		Short: "Tornar um local o principal (usado nas cotações sem --from)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			return agent.SetDefaultPlace(args[0])
+		},
+	}
+	locationsRemoveCmd := &cobra.Command{
+		Use:   "remove <nome>",
+		Short: "Remover um local",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			return agent.RemovePlace(args[0])
+		},
+	}
+	locationsCmd.AddCommand(locationsAddCmd, locationsDefaultCmd, locationsRemoveCmd)
+
+	// household group commands
+	groupCmd := &cobra.Command{
+		Use:   "group",
+		Short: "Grupo de WhatsApp da casa: membros e permissões",
+	}
+	groupListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Listar os grupos em que o número do agente está (para configurar WA_GROUP)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+			defer agent.Close()
+			groups, err := agent.Groups()
+			if err != nil {
+				return err
+			}
+			if len(groups) == 0 {
+				fmt.Println("O número não está em nenhum grupo. Adicione-o ao grupo pelo celular.")
+				return nil
+			}
+			for _, g := range groups {
+				fmt.Printf("%-35s %s (%d participantes)\n", g.Address, g.Name, len(g.Members))
+			}
+			return nil
+		},
+	}
+	groupMembersCmd := &cobra.Command{
+		Use:   "members",
+		Short: "Listar membros que podem dar comandos no grupo",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			members, err := agent.Members()
+			if err != nil {
+				return err
+			}
+			if len(members) == 0 {
+				fmt.Println("Nenhum membro cadastrado; só o dono dá comandos no grupo.")
+				return nil
+			}
+			for _, m := range members {
+				role := "pede cotações"
+				if m.Approver {
+					role = "pede e aprova qualquer valor"
+				}
+				fmt.Printf("%-20s %-15s %s\n", m.Name, m.Phone, role)
+			}
+			return nil
+		},
+	}
+	groupAddCmd := &cobra.Command{
+		Use:   "add <telefone>",
+		Short: "Cadastrar (ou atualizar) um membro do grupo",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			approver, _ := cmd.Flags().GetBool("approver")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			if err := agent.SaveMember(comprador.Member{Phone: args[0], Name: name, Approver: approver}); err != nil {
+				return err
+			}
+			fmt.Printf("Membro %s salvo.\n", name)
+			return nil
+		},
+	}
+	groupAddCmd.Flags().String("name", "", "Nome do membro")
+	groupAddCmd.Flags().Bool("approver", false, "Pode aceitar opções acima de GROUP_APPROVAL_LIMIT")
+	_ = groupAddCmd.MarkFlagRequired("name")
+	groupRemoveCmd := &cobra.Command{
+		Use:   "remove <telefone>",
+		Short: "Remover um membro do grupo",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			return agent.RemoveMember(args[0])
+		},
+	}
+	groupMembersCmd.AddCommand(groupAddCmd, groupRemoveCmd)
+	groupCmd.AddCommand(groupListCmd, groupMembersCmd)
+
+	// do-not-contact commands
+	dncCmd := &cobra.Command{
+		Use:   "dnc",
+		Short: "Lista de contatos que não devem receber mensagens",
+	}
+	dncListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Listar contatos bloqueados",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			entries, err := agent.DoNotContact()
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Println("Nenhum contato bloqueado.")
+				return nil
+			}
+			for _, e := range entries {
+				fmt.Printf("%-30s %s  %s\n", e.Address, e.CreatedAt.Format("02/01/2006"), e.Reason)
+			}
+			return nil
+		},
+	}
+	dncAddCmd := &cobra.Command{
+		Use:   "add <contato>",
+		Short: "Bloquear um contato (fornecedor, telefone ou endereço) e desativar o fornecedor",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reason, _ := cmd.Flags().GetString("reason")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			addr, err := agent.BlockContact(args[0], reason)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s não receberá mais mensagens.\n", addr)
+			return nil
+		},
+	}
+	dncAddCmd.Flags().String("reason", "bloqueado manualmente", "Motivo")
+	dncRemoveCmd := &cobra.Command{
+		Use:   "remove <contato>",
+		Short: "Desbloquear um contato (o fornecedor volta a ficar ativo)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			addr, err := agent.UnblockContact(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s pode voltar a receber mensagens.\n", addr)
+			return nil
+		},
+	}
+	dncCmd.AddCommand(dncListCmd, dncAddCmd, dncRemoveCmd)
+
+	// categories commands
+	categoriesCmd := &cobra.Command{
+		Use:   "categories",
+		Short: "Categorias de fornecedores (hierarquia, sinônimos e gastos)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			tax, counts, err := agent.Taxonomy()
+			if err != nil {
+				return err
+			}
+			for _, c := range tax.List() {
+				indent := strings.Repeat("  ", len(tax.Ancestors(c.Slug)))
+				line := fmt.Sprintf("%s%s [%s]", indent, c.Name, c.Slug)
+				if n := counts[c.Slug]; n > 0 {
+					line += fmt.Sprintf(" — %d fornecedor(es)", n)
+				}
+				fmt.Println(line)
+			}
+			return nil
+		},
+	}
+	categoriesShowCmd := &cobra.Command{
+		Use:   "show <categoria>",
+		Short: "Exibir uma categoria (por identificador, nome ou sinônimo)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			tax, counts, err := agent.Taxonomy()
+			if err != nil {
+				return err
+			}
+			slug, ok := tax.Resolve(args[0])
+			if !ok {
+				return fmt.Errorf("%w: %q", suppliers.ErrUnknownCategory, args[0])
+			}
+			c := tax.Get(slug)
+			fmt.Printf("%s [%s]\n", tax.Path(slug), c.Slug)
+			if c.Description != "" {
+				fmt.Printf("  %s\n", c.Description)
+			}
+			if len(c.Synonyms) > 0 {
+				fmt.Printf("  Sinônimos:      %s\n", strings.Join(c.Synonyms, ", "))
+			}
+			if sub := tax.Children(slug); len(sub) > 0 {
+				fmt.Printf("  Subcategorias:  %s\n", strings.Join(sub, ", "))
+			}
+			fmt.Printf("  Fornecedores:   %d\n", counts[slug])
+			return nil
+		},
+	}
+	categoriesAddCmd := &cobra.Command{
+		Use:   "add <identificador>",
+		Short: "Criar uma categoria (ex: add bebidas --name Bebidas --parent alimentos)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			c := suppliers.Category{Slug: args[0]}
+			applyCategoryFlags(cmd, &c)
+			if c.Name == "" {
+				c.Name = args[0]
+			}
+			if err := agent.AddCategory(c); err != nil {
+				return err
+			}
+			fmt.Printf("Categoria criada: %s\n", c.Slug)
+			return nil
+		},
+	}
+	categoryFlags(categoriesAddCmd)
+	categoriesEditCmd := &cobra.Command{
+		Use:   "edit <categoria>",
+		Short: "Alterar nome, categoria mãe, descrição ou sinônimos de uma categoria",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			tax, _, err := agent.Taxonomy()
+			if err != nil {
+				return err
+			}
+			slug, ok := tax.Resolve(args[0])
+			if !ok {
+				return fmt.Errorf("%w: %q", suppliers.ErrUnknownCategory, args[0])
+			}
+			c := *tax.Get(slug)
+			applyCategoryFlags(cmd, &c)
+			if err := agent.UpdateCategory(c); err != nil {
+				return err
+			}
+			fmt.Printf("Categoria atualizada: %s\n", tax.Path(slug))
+			return nil
+		},
+	}
+	categoryFlags(categoriesEditCmd)
+	categoriesRemoveCmd := &cobra.Command{
+		Use:   "remove <identificador>",
+		Short: "Apagar uma categoria sem subcategorias nem fornecedores",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			if err := agent.RemoveCategory(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Categoria removida: %s\n", args[0])
+			return nil
+		},
+	}
+	categoriesClassifyCmd := &cobra.Command{
+		Use:   "classify <item> [item...]",
+		Short: "Mostrar em que categorias cada item cai (como na escolha de fornecedores)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			tax, _, err := agent.Taxonomy()
+			if err != nil {
+				return err
+			}
+			byItem, err := agent.ClassifyItems(cmd.Context(), args)
+			if err != nil {
+				return err
+			}
+			for _, item := range args {
+				var paths []string
+				for _, c := range byItem[item] {
+					paths = append(paths, tax.Path(c))
+				}
+				if len(paths) == 0 {
+					paths = []string{"(nenhuma)"}
+				}
+				fmt.Printf("%s: %s\n", item, strings.Join(paths, "; "))
+			}
+			return nil
+		},
+	}
+	categoriesSpendCmd := &cobra.Command{
+		Use:   "spend",
+		Short: "Gastos com compras aceitas, por categoria",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			days, _ := cmd.Flags().GetInt("days")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			tax, _, err := agent.Taxonomy()
+			if err != nil {
+				return err
+			}
+			spend, err := agent.SpendByCategory(cmd.Context(), time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return err
+			}
+			if spend.Purchases == 0 {
+				fmt.Printf("Nenhuma compra aceita nos últimos %d dias.\n", days)
+				return nil
+			}
+			fmt.Printf("Gastos desde %s: R$ %.2f em %d compra(s)\n\n", spend.Since.Format("02/01/2006"), spend.Total, spend.Purchases)
+			for _, c := range tax.List() {
+				total := spend.ByCategory[c.Slug]
+				if total == 0 {
+					continue
+				}
+				indent := strings.Repeat("  ", len(tax.Ancestors(c.Slug)))
+				label := indent + c.Name
+				fmt.Printf("%-36s R$ %10.2f  %3.0f%%  %d compra(s)\n", label, total, 100*total/spend.Total, spend.PurchasesBy[c.Slug])
+			}
+			if spend.Uncategorized > 0 {
+				fmt.Printf("%-36s R$ %10.2f  %3.0f%%\n", "Sem categoria", spend.Uncategorized, 100*spend.Uncategorized/spend.Total)
+			}
+			return nil
+		},
+	}
+	categoriesSpendCmd.Flags().Int("days", 90, "Período em dias")
+	categoriesCmd.AddCommand(categoriesShowCmd, categoriesAddCmd, categoriesEditCmd, categoriesRemoveCmd,
+		categoriesClassifyCmd, categoriesSpendCmd)
+
+	// price catalog command
+	pricesCmd := &cobra.Command{
+		Use:   "prices <item>",
+		Short: "Preços já cotados por fornecedores para um item",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			days, _ := cmd.Flags().GetInt("days")
+			query := strings.Join(args, " ")
+			agent, err := openAgent(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			prices, err := agent.Prices(query, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return err
+			}
+			if len(prices) == 0 {
+				fmt.Printf("Nenhum preço de %q nos últimos %d dias.\n", query, days)
+				return nil
+			}
+			fmt.Printf("%-10s %-30s %-30s %s\n", "Data", "Item", "Fornecedor", "Preço")
+			fmt.Println("---")
+			for _, p := range prices {
+				name := p.Supplier
+				if name == "" {
+					name = p.SupplierID
+				}
+				unit := p.Unit
+				if unit == "" {
+					unit = "un"
+				}
+				fmt.Printf("%-10s %-30s %-30s R$ %.2f/%s\n", p.QuotedAt.Format("02/01/2006"), p.Item, name, p.Price, unit)
+			}
+			return nil
+		},
+	}
+	pricesCmd.Flags().Int("days", 90, "Período em dias")
+
+	root.AddCommand(quoteCmd, serveCmd, statusCmd, chatCmd, suppliersCmd, inboxCmd, dncCmd, groupCmd, categoriesCmd, locationsCmd, pricesCmd, historyCmd,
+		feedbackCmd, repeatCmd)
+	return root
+}
+
+func printInbox(convs []comprador.Conversation, unknownOnly bool) {
+	shown := 0
+	for _, c := range convs {
+		if unknownOnly && !c.Unknown() {
+			continue
+		}
+		unread := ""
+		if c.Unread > 0 {
+			unread = fmt.Sprintf(" (%d não lida(s))", c.Unread)
+		}
+		arrow := "→"
+		if c.LastIn {
+			arrow = "←"
+		}
+		preview := strings.Join(strings.Fields(c.LastBody), " ")
+		if r := []rune(preview); len(r) > 60 {
+			preview = string(r[:60]) + "…"
+		}
+		fmt.Printf("%-30s %-28s %s%s\n    %s %s\n",
+			c.Contact(), c.Phone, c.LastAt.Format("02/01 15:04"), unread, arrow, preview)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("Nenhuma conversa.")
+		return
+	}
+	fmt.Println("\nUse 'comprador inbox show <contato>' para ler e 'inbox assign' para atribuir mensagens de desconhecidos.")
+}
+
+func printThread(addr string, msgs []messages.Message) {
+	if len(msgs) == 0 {
+		fmt.Printf("Nenhuma mensagem com %s.\n", addr)
+		return
+	}
+	fmt.Printf("Conversa com %s:\n\n", addr)
+	for _, m := range msgs {
+		who := "você"
+		if m.Direction == messages.DirectionIn {
+			who = "contato"
+		}
+		body := m.Body
+		if m.MediaPath != "" {
+			body = strings.TrimSpace("[arquivo: " + m.MediaPath + "] " + body)
+		}
+		fmt.Printf("#%d %s %s", m.ID, m.SentAt.Format("02/01 15:04"), who)
+		if m.Direction == messages.DirectionOut && m.Status != messages.StatusSent {
+			fmt.Printf(" (%s)", m.Status)
+		}
+		if m.RequestID != "" {
+			fmt.Printf(" [pedido %s]", m.RequestID)
+		}
+		fmt.Printf(":\n%s\n\n", body)
+	}
+}
+
+func printSupplierStats(stats []comprador.SupplierStat) {
+	if len(stats) == 0 {
+		fmt.Println("Nenhum fornecedor cadastrado.")
+		return
+	}
+	pct := func(n, total int) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.0f%%", 100*float64(n)/float64(total))
+	}
+	fmt.Printf("%-30s %8s %9s %6s %13s %s\n", "Nome", "Enviadas", "Entregues", "Lidas", "Sem WhatsApp", "Último envio")
+	fmt.Println("---")
+	for _, st := range stats {
+		last := "-"
+		if st.LastSent != nil {
+			last = st.LastSent.Format("02/01/2006")
+		}
+		fmt.Printf("%-30s %8d %9s %6s %13d %s\n",
+			st.Supplier.Name, st.Sent, pct(st.Delivered, st.Sent), pct(st.Read, st.Sent), st.Invalid, last)
+	}
+}
+
+// supplierFlags registers the supplier fields as flags; the city comes from
+// the global --city.
+func supplierFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "Nome do fornecedor")
+	cmd.Flags().String("phone", "", "Telefone (ex: 5567999990000)")
+	cmd.Flags().String("email", "", "E-mail")
+	cmd.Flags().String("telegram", "", "Chat ID do Telegram")
+	cmd.Flags().String("channel", "", "Canal preferido: whatsapp, telegram ou email")
+	cmd.Flags().StringSlice("categories", nil, "Categorias, por nome ou sinônimo (separadas por vírgula; veja 'comprador categories')")
+	cmd.Flags().Float64("rating", 5, "Nota (0-5)")
+	cmd.Flags().String("hours", "", "Horário de atendimento (ex: 'seg-sex 08:00-18:00; sab 08:00-12:00'; vazio = sempre)")
+	cmd.Flags().String("timezone", "", "Fuso horário (padrão "+suppliers.DefaultTimezone+")")
+	cmd.Flags().StringArray("branch", nil, "Loja atendida pelo mesmo telefone (repetível; em edit, substitui a lista)")
+	cmd.Flags().String("street", "", "Endereço (ex: 'Rua da Divisão, 120'); localize com 'comprador suppliers geocode'")
+	cmd.Flags().String("location", "", "Coordenadas latitude,longitude (ex: -20.4697,-54.6201; vazio apaga)")
+	cmd.Flags().Float64("delivery-radius", 0, "Raio de entrega em km (0 = não informado)")
+}
+
+// applySupplierFlags copies the flags given on the command line into sup and
+// reports whether any was.
+func applySupplierFlags(cmd *cobra.Command, sup *suppliers.Supplier) (bool, error) {
+	f := cmd.Flags()
+	changed := false
+	str := func(name string, dst *string) {
+		if f.Changed(name) {
+			*dst, _ = f.GetString(name)
+			*dst = strings.TrimSpace(*dst)
+			changed = true
+		}
+	}
+	str("name", &sup.Name)
+	str("phone", &sup.Phone)
+	str("city", &sup.City)
+	str("email", &sup.Email)
+	str("telegram", &sup.TelegramID)
+	str("channel", &sup.Channel)
+	str("hours", &sup.Hours)
+	str("timezone", &sup.Timezone)
+	sup.Channel = strings.ToLower(sup.Channel)
+	if f.Changed("categories") {
+		sup.Categories, _ = f.GetStringSlice("categories")
+		changed = true
+	}
+	if f.Changed("rating") {
+		sup.Rating, _ = f.GetFloat64("rating")
+		changed = true
+	}
+	if f.Changed("branch") {
+		sup.Branches, _ = f.GetStringArray("branch")
+		changed = true
+	}
+	str("street", &sup.Street)
+	if f.Changed("location") {
+		loc, _ := f.GetString("location")
+		sup.Geo = suppliers.Point{}
+		if strings.TrimSpace(loc) != "" {
+			p, err := suppliers.ParsePoint(loc)
+			if err != nil {
+				return false, err
+			}
+			sup.Geo = p
+		}
+		changed = true
+	}
+	if f.Changed("delivery-radius") {
+		sup.DeliveryRadius, _ = f.GetFloat64("delivery-radius")
+		changed = true
+	}
+	return changed, nil
+}
+
+// printImport shows what an import did (or, in a dry run, would do): new
+// suppliers, changed fields, branches and skipped records.
+func printImport(items []suppliers.ImportItem, dryRun bool) {
+	counts := make(map[string]int)
+	for _, it := range items {
+		counts[it.Action]++
+		name := it.Supplier.Name
+		if name == "" {
+			name = it.Record.Name
+		}
+		note := ""
+		if it.Note != "" {
+			note = " — " + it.Note
+		}
+		switch it.Action {
+		case suppliers.ImportAdd:
+			fmt.Printf("+ #%-4d %s (%s)%s\n", it.Record.Line, name, it.Supplier.Phone, note)
+		case suppliers.ImportUpdate:
+			fmt.Printf("~ #%-4d %s%s\n", it.Record.Line, name, note)
+			for _, c := range it.Changes {
+				fmt.Printf("           %s: %q → %q\n", c.Field, c.Old, c.New)
+			}
+		case suppliers.ImportBranch:
+			fmt.Printf("» #%-4d %s%s\n", it.Record.Line, name, note)
+		case suppliers.ImportSkip:
+			fmt.Printf("! #%-4d %s: ignorado%s\n", it.Record.Line, name, note)
+		}
+	}
+	fmt.Printf("\n%d novos, %d atualizados, %d sem alteração, %d ignorados.\n",
+		counts[suppliers.ImportAdd], counts[suppliers.ImportUpdate], counts[suppliers.ImportUnchanged], counts[suppliers.ImportSkip])
+	if n := counts[suppliers.ImportBranch]; n > 0 {
+		fmt.Printf("%d linhas viraram lojas de um fornecedor anterior (mesmo telefone).\n", n)
+	}
+	if dryRun {
+		fmt.Println("Simulação: nada foi gravado. Rode sem --dry-run para aplicar.")
+	}
+}
+
+// printScore shows the parts a supplier's rating is computed from.
+func printScore(sc suppliers.Score, indent string) {
+	pct := func(v float64) string { return fmt.Sprintf("%.0f%%", 100*v) }
+	if sc.Asked > 0 {
+		fmt.Printf("%sRespostas:   %d de %d pedidos (%s)", indent, sc.Answered, sc.Asked, pct(sc.ResponseRate()))
+		if sc.MedianResponse > 0 {
+			fmt.Printf(", mediana de %s para responder", replyTime(sc.MedianResponse))
+		}
+		fmt.Println()
+	}
+	if sc.Priced > 0 {
+		fmt.Printf("%sPreço:       %s (100%% = sempre o mais barato; %d cotações com concorrente)\n", indent, pct(sc.PriceIndex), sc.Priced)
+	}
+	if sc.Decided > 0 {
+		fmt.Printf("%sEscolhido:   %d de %d cotações decididas (%s)\n", indent, sc.Won, sc.Decided, pct(sc.WinRate()))
+	}
+	if sc.Feedbacks > 0 {
+		fmt.Printf("%sAvaliação:   %.1f de 5 (%d)\n", indent, sc.FeedbackAvg, sc.Feedbacks)
+	}
+}
+
+// replyTime writes a reply delay as "25min", "3h10" or "2 dias".
+func replyTime(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dmin", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%d dias", int(d.Hours()/24))
+}
+
+func printProfile(p *comprador.SupplierProfile) {
+	s := p.Supplier
+	status := "ativo"
+	switch {
+	case p.DoNotContact:
+		status = "não contactar"
+	case !s.Active:
+		status = "inativo"
+	}
+	hours := "sempre"
+	if s.Hours != "" {
+		hours = s.Hours
+	}
+	tz := s.Timezone
+	if tz == "" {
+		tz = suppliers.DefaultTimezone
+	}
+	fmt.Printf("%s (%s)\n", s.Name, status)
+	fmt.Printf("  ID:          %s\n", s.ID)
+	fmt.Printf("  Cidade:      %s\n", s.City)
+	fmt.Printf("  Categorias:  %s\n", strings.Join(s.Categories, ", "))
+	if p.Score != nil {
+		fmt.Printf("  Nota:        %.1f (calculada em %s)\n", s.Rating, p.Score.UpdatedAt.Format("02/01 15h04"))
+	} else {
+		fmt.Printf("  Nota:        %.1f (sem histórico; definida no cadastro)\n", s.Rating)
+	}
+	fmt.Printf("  Canal:       %s (%s)\n", s.Channel, s.Address())
+	if s.Phone != "" && s.Address() != s.Phone {
+		fmt.Printf("  Telefone:    %s\n", s.Phone)
+	}
+	if s.Email != "" {
+		fmt.Printf("  E-mail:      %s\n", s.Email)
+	}
+	if s.TelegramID != "" {
+		fmt.Printf("  Telegram:    %s\n", s.TelegramID)
+	}
+	fmt.Printf("  Horário:     %s (%s)\n", hours, tz)
+	if len(s.Branches) > 0 {
+		fmt.Printf("  Lojas:       %s\n", strings.Join(s.Branches, "; "))
+	}
+	if s.Street != "" {
+		fmt.Printf("  Endereço:    %s\n", s.Street)
+	}
+	if !s.Geo.IsZero() {
+		fmt.Printf("  Coordenadas: %s", s.Geo)
+		if home := p.Place; home != nil {
+			fmt.Printf(" (%s de %s)", suppliers.FormatDistance(s.DistanceFrom(home.Geo)), home.Name)
+		}
+		fmt.Println()
+	}
+	if s.DeliveryRadius > 0 {
+		fmt.Printf("  Entrega:     até %s\n", suppliers.FormatDistance(s.DeliveryRadius))
+	}
+
+	fmt.Println()
+	last := "-"
+	if p.LastQuote != nil {
+		last = p.LastQuote.Format("02/01/2006")
+	}
+	fmt.Printf("  Cotações:    %d pedidas, %d respondidas, %d aceitas (última: %s)\n", p.Quotes, p.Answered, p.Accepted, last)
+	m := p.Messages
+	fmt.Printf("  Mensagens:   %d enviadas, %d entregues, %d lidas", m.Sent, m.Delivered, m.Read)
+	if m.Invalid > 0 {
+		fmt.Printf(", %d sem WhatsApp", m.Invalid)
+	}
+	fmt.Println()
+	if p.Score != nil {
+		fmt.Println("\n  Como a nota é calculada:")
+		printScore(*p.Score, "    ")
+	}
+	if len(p.Feedback) > 0 {
+		fmt.Println("\n  Avaliações:")
+		for i, f := range p.Feedback {
+			if i == 5 {
+				break
+			}
+			fmt.Printf("    %s %s %s\n", f.CreatedAt.Format("02/01"), memory.Stars(f.Rating), f.Comment)
+		}
+	}
+
+	if len(p.Recent) > 0 {
+		fmt.Println("\n  Últimas respostas:")
+		for _, q := range p.Recent {
+			resp := strings.Join(strings.Fields(q.Response), " ")
+			if len([]rune(resp)) > 70 {
+				resp = string([]rune(resp)[:70]) + "…"
+			}
+			price := ""
+			if q.Price > 0 {
+				price = fmt.Sprintf(" R$ %.2f", q.Price)
+			}
+			fmt.Printf("    %s [%s]%s %s\n", q.CreatedAt.Format("02/01"), q.Status, price, resp)
+		}
+	}
+}
+
+// categoryFlags registers the fields of 'categories add' and 'categories edit'.
+func categoryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "Nome (ex: Carnes e açougue)")
+	cmd.Flags().String("parent", "", "Categoria mãe (identificador; vazio = nível de cima)")
+	cmd.Flags().String("description", "", "Descrição")
+	cmd.Flags().StringSlice("synonyms", nil, "Sinônimos e itens típicos, separados por vírgula (substitui os atuais)")
+}
+
+// applyCategoryFlags copies the flags set on the command line into c.
+func applyCategoryFlags(cmd *cobra.Command, c *suppliers.Category) {
+	f := cmd.Flags()
+	if f.Changed("name") {
+		c.Name, _ = f.GetString("name")
+	}
+	if f.Changed("parent") {
+		c.Parent, _ = f.GetString("parent")
+	}
+	if f.Changed("description") {
+		c.Description, _ = f.GetString("description")
+	}
+	if f.Changed("synonyms") {
+		c.Synonyms, _ = f.GetStringSlice("synonyms")
+	}
+}
+
+// readGazetteer opens a file of coordinates for geocoding.
+func readGazetteer(path string) (*suppliers.Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := suppliers.ReadGazetteer(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if g.Len() == 0 {
+		return nil, fmt.Errorf("%s: nenhum endereço", path)
+	}
+	return g, nil
 }
 
 func promptSupplier() (suppliers.Supplier, error) {
@@ -188,8 +1842,47 @@ func promptSupplier() (suppliers.Supplier, error) {
 
 	name := read("Nome do fornecedor: ")
 	phone := read("Telefone (ex: 5567999990000): ")
+	email := read("E-mail (opcional): ")
+	telegramID := read("Chat ID do Telegram (opcional): ")
+	channel := strings.ToLower(read("Canal preferido (whatsapp/telegram/email) [whatsapp]: "))
 	city := read("Cidade: ")
-	catsRaw := read("Categorias (vírgula): ")
+	catsRaw := read("Categorias (vírgula; veja 'comprador categories'): ")
+	hours := read("Horário de atendimento (ex: seg-sex 08:00-18:00; sab 08:00-12:00) [sempre]: ")
+	tz := read("Fuso horário [" + suppliers.DefaultTimezone + "]: ")
+	street := read("Endereço (opcional): ")
+	locRaw := read("Coordenadas latitude,longitude (opcional, ex: -20.4697,-54.6201): ")
+	radiusRaw := read("Raio de entrega em km (opcional): ")
+
+	if _, err := suppliers.ParseHours(hours); err != nil {
+		return suppliers.Supplier{}, err
+	}
+	if tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return suppliers.Supplier{}, fmt.Errorf("fuso horário %q inválido", tz)
+		}
+	}
+	var geo suppliers.Point
+	if locRaw != "" {
+		var err error
+		if geo, err = suppliers.ParsePoint(locRaw); err != nil {
+			return suppliers.Supplier{}, err
+		}
+	}
+	var radius float64
+	if radiusRaw != "" {
+		var err error
+		if radius, err = strconv.ParseFloat(strings.Replace(radiusRaw, ",", ".", 1), 64); err != nil || radius < 0 {
+			return suppliers.Supplier{}, fmt.Errorf("raio de entrega inválido: %q", radiusRaw)
+		}
+	}
+
+	switch channel {
+	case "":
+		channel = whatsapp.ChannelWhatsApp
+	case whatsapp.ChannelWhatsApp, whatsapp.ChannelTelegram, whatsapp.ChannelEmail:
+	default:
+		return suppliers.Supplier{}, fmt.Errorf("canal inválido: %q", channel)
+	}
 
 	var cats []string
 	for _, c := range strings.Split(catsRaw, ",") {
@@ -206,5 +1899,164 @@ func promptSupplier() (suppliers.Supplier, error) {
 		Categories: cats,
 		Rating:     5.0,
 		Active:     true,
+		Channel:    channel,
+		TelegramID: telegramID,
+		Email:      email,
+		Hours:      hours,
+		Timezone:   tz,
+
+		Street:         street,
+		Geo:            geo,
+		DeliveryRadius: radius,
 	}, nil
 }
+
+// setupSimulator attaches a supplier simulator to a dry-run agent. Personas
+// come from path; when the file does not exist they are generated by the LLM
+// for the registered suppliers and saved there for the next runs.
+func setupSimulator(ctx context.Context, agent *comprador.Agent, path string, seed int64) error {
+	mock := agent.MockSender()
+	if mock == nil {
+		return fmt.Errorf("simulação requer o WhatsApp simulado (--dry-run)")
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	cl, err := claude.New()
+	if err != nil {
+		return err
+	}
+	personas, err := simulator.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		sups, err := agent.Suppliers()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Gerando personas para %d fornecedor(es)...\n", len(sups))
+		personas, err = simulator.Generate(ctx, cl, sups, rng)
+		if err != nil {
+			return fmt.Errorf("gerar personas: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			if err := simulator.Save(path, personas); err == nil {
+				fmt.Printf("Personas salvas em %s (edite para ajustar).\n", path)
+			}
+		}
+	} else if err != nil {
+		return fmt.Errorf("personas: %w", err)
+	}
+
+	agent.SetSimulator(simulator.New(cl, mock, agent.SupplierByAddress, personas, seed))
+	fmt.Printf("Simulação ativa (seed %d).\n", seed)
+	return nil
+}
+
+// connectSender connects the real WhatsApp session (showing the QR code on
+// the first run) with the other channels configured.
+func connectSender(ctx context.Context, waDBPath string) (whatsapp.MessageSender, error) {
+	wa, err := connectWhatsApp(ctx, waDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("whatsapp: %w", err)
+	}
+	sender, err := withChannels(ctx, wa)
+	if err != nil {
+		wa.Close()
+		return nil, err
+	}
+	return sender, nil
+}
+
+// connectWhatsApp opens the WhatsApp backend chosen by WHATSAPP_BACKEND:
+// whatsmeow (default, a linked phone) or cloud (the official Business API).
+func connectWhatsApp(ctx context.Context, waDBPath string) (whatsapp.MessageSender, error) {
+	switch backend := viper.GetString("WHATSAPP_BACKEND"); backend {
+	case "", "whatsmeow":
+		return whatsapp.NewRealSender(ctx, waDBPath)
+	case "cloud":
+		return whatsapp.NewCloudSender(ctx, whatsapp.CloudConfig{
+			Token:         viper.GetString("WA_CLOUD_TOKEN"),
+			PhoneNumberID: viper.GetString("WA_CLOUD_PHONE_ID"),
+			AppSecret:     viper.GetString("WA_CLOUD_APP_SECRET"),
+			VerifyToken:   viper.GetString("WA_CLOUD_VERIFY_TOKEN"),
+			APIURL:        viper.GetString("WA_CLOUD_API_URL"),
+			WebhookAddr:   viper.GetString("WA_CLOUD_WEBHOOK_ADDR"),
+			WebhookPath:   viper.GetString("WA_CLOUD_WEBHOOK_PATH"),
+			Template:      viper.GetString("WA_CLOUD_TEMPLATE"),
+			TemplateLang:  viper.GetString("WA_CLOUD_TEMPLATE_LANG"),
+			MediaDir:      whatsapp.DefaultMediaDir,
+		})
+	default:
+		return nil, fmt.Errorf("WHATSAPP_BACKEND inválido: %q (use whatsmeow ou cloud)", backend)
+	}
+}
+
+// withChannels adds the Telegram and e-mail channels configured in the
+// environment, routing suppliers that prefer them; with none configured the
+// WhatsApp sender is used as is.
+func withChannels(ctx context.Context, wa whatsapp.MessageSender) (whatsapp.MessageSender, error) {
+	router := whatsapp.NewRouter(wa)
+	extra := false
+
+	// Suppliers that prefer Telegram are reached through the bot
+	if token := viper.GetString("TELEGRAM_BOT_TOKEN"); token != "" {
+		tg, err := whatsapp.NewTelegramSender(ctx, token, viper.GetString("TELEGRAM_API_URL"), whatsapp.DefaultMediaDir)
+		if err != nil {
+			return nil, err
+		}
+		router.Add(whatsapp.ChannelTelegram, tg)
+		extra = true
+	}
+
+	if from := viper.GetString("EMAIL_FROM"); from != "" {
+		user, pass := viper.GetString("SMTP_USER"), viper.GetString("SMTP_PASSWORD")
+		imapUser, imapPass := viper.GetString("IMAP_USER"), viper.GetString("IMAP_PASSWORD")
+		if imapUser == "" {
+			imapUser, imapPass = user, pass
+		}
+		poll := time.Minute
+		if v := viper.GetInt("EMAIL_POLL"); v > 0 {
+			poll = time.Duration(v) * time.Second
+		}
+		email := whatsapp.NewEmailSender(from,
+			whatsapp.SMTPMailer{Addr: viper.GetString("SMTP_ADDR"), Username: user, Password: pass},
+			whatsapp.IMAPMailbox{Addr: viper.GetString("IMAP_ADDR"), Username: imapUser, Password: imapPass},
+			whatsapp.DefaultMediaDir, poll)
+		router.Add(whatsapp.ChannelEmail, email)
+		extra = true
+	}
+
+	if !extra {
+		return wa, nil
+	}
+	return router, nil
+}
+
+// throttleConfig reads the WhatsApp pacing limits from the environment, on top
+// of the defaults. Dry-run keeps the caps but skips the delays.
+func throttleConfig(dryRun bool) whatsapp.ThrottleConfig {
+	cfg := whatsapp.DefaultThrottleConfig()
+	if v := viper.GetInt("WA_MAX_PER_MINUTE"); v > 0 {
+		cfg.PerMinute = v
+	}
+	if v := viper.GetInt("WA_MAX_PER_DAY"); v > 0 {
+		cfg.PerDay = v
+	}
+	if v := viper.GetInt("WA_MAX_FIRST_CONTACTS_PER_DAY"); v > 0 {
+		cfg.FirstContactPerDay = v
+	}
+	if viper.IsSet("WA_MIN_DELAY") {
+		cfg.MinDelay = time.Duration(viper.GetInt("WA_MIN_DELAY")) * time.Second
+	}
+	if viper.IsSet("WA_MAX_DELAY") {
+		cfg.MaxDelay = time.Duration(viper.GetInt("WA_MAX_DELAY")) * time.Second
+	}
+	if viper.IsSet("WA_TYPING") && !viper.GetBool("WA_TYPING") {
+		cfg.TypingPerChar = 0
+	}
+	if dryRun {
+		cfg.MinDelay, cfg.MaxDelay, cfg.TypingPerChar = 0, 0, 0
+	}
+	return cfg
+}
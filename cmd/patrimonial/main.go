@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -172,6 +173,7 @@ func promptAsset() (assets.Asset, error) {
 	location := read("Localização: ")
 	acquiredStr := read("Data de aquisição (MM/AAAA, ou vazio): ")
 	notes := read("Observações: ")
+	photosRaw := read("Fotos (caminhos separados por vírgula, ou vazio): ")
 
 	var acquiredAt *time.Time
 	if acquiredStr != "" {
@@ -181,6 +183,18 @@ func promptAsset() (assets.Asset, error) {
 		}
 	}
 
+	var photos []string
+	for _, p := range strings.Split(photosRaw, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		photos = append(photos, p)
+	}
+
 	return assets.Asset{
 		Name:       name,
 		Type:       assetType,
@@ -190,6 +204,7 @@ func promptAsset() (assets.Asset, error) {
 		AcquiredAt: acquiredAt,
 		Notes:      notes,
 		Metadata:   make(map[string]any),
+		Photos:     photos,
 	}, nil
 }
 
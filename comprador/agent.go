@@ -6,10 +6,13 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/user/agente/comprador/memory"
+	"github.com/user/agente/comprador/messages"
 	"github.com/user/agente/comprador/suppliers"
 	"github.com/user/agente/internal/claude"
 	"github.com/user/agente/internal/whatsapp"
@@ -23,6 +26,39 @@ type Config struct {
 	WhatsAppDB   string // path for whatsmeow session DB
 	OwnerPhone   string // if set, sends WhatsApp notification to owner when quotes are ready
 	AutoConfirm  bool   // skip interactive confirmation of parsed items before sending
+	MediaDir     string // where request photos and received media are stored
+	Throttle     whatsapp.ThrottleConfig
+
+	// Group is the household WhatsApp group ("<id>@g.us"). Members post
+	// commands there and it receives the notifications instead of OwnerPhone.
+	Group string
+	// ApprovalLimit is the total (R$) above which only approvers may accept
+	// an option; 0 means anyone can.
+	ApprovalLimit float64
+	// DeliveryDelay is how long after accepting an option the purchase is
+	// expected to arrive; the owner is then asked to rate it.
+	DeliveryDelay time.Duration
+	// MaxRadius is the distance (km) from the delivery place beyond which
+	// suppliers are not asked, for requests that set none; 0 means any.
+	MaxRadius float64
+	// EstimateFirst shows the prices suppliers quoted recently before a
+	// request is sent, and offers to skip those whose price is fresh.
+	EstimateFirst bool
+	// PriceMaxAge is how old a known price may be and still stand in for
+	// a new quote; 0 means defaultPriceMaxAge.
+	PriceMaxAge time.Duration
+	// MaxSuppliers caps the suppliers a request messages; 0 means
+	// defaultMaxSuppliers.
+	MaxSuppliers int
+	// Strategy chooses the suppliers asked when more match than
+	// MaxSuppliers (one of Strategies); empty means StrategyTopRated.
+	Strategy string
+	// Explore is the chance each place goes to a less known supplier under
+	// StrategyExplore; 0 means defaultExplore.
+	Explore float64
+	// WeeklyLimit is how many requests a supplier may get in a week
+	// before it rests; 0 means no limit.
+	WeeklyLimit int
 }
 
 // DefaultConfig returns sensible defaults.
@@ -32,6 +68,10 @@ func DefaultConfig() Config {
 		QuoteTimeout: 30 * time.Minute,
 		DryRun:       true,
 		WhatsAppDB:   "data/whatsapp.db",
+		MediaDir:     whatsapp.DefaultMediaDir,
+		Throttle:     whatsapp.DefaultThrottleConfig(),
+
+		DeliveryDelay: defaultDeliveryDelay,
 	}
 }
 
@@ -45,57 +85,291 @@ type Agent struct {
 	matcher  *suppliers.Matcher
 	qManager *QuoteManager
 	memStore *memory.Store
+	rStore   *RequestStore
+	msgStore *messages.Store
+	dnc      *suppliers.DNCStore
+	outbox   *OutboxStore
+	members  *MemberStore
+	scores   *suppliers.ScoreStore
+	feedback *suppliers.FeedbackStore
+	cats     *suppliers.CategoryStore
+	places   *PlaceStore
+	prices   *suppliers.PriceStore
+	sendLog  whatsapp.SendLog
+
+	input     *bufio.Reader   // answers to interactive prompts (see SetInput)
+	mu        sync.Mutex      // guards request status changes so a request is closed only once
+	finishing map[string]bool // requests whose replies are being compared; under mu
+	wake      chan struct{}   // signalled when a supplier reply arrives
+
+	assistant func(ctx context.Context, text string) (string, error) // optional, see SetOwnerAssistant
+	awaiting  func() bool                                            // optional, see SetOwnerAssistant
+	sim       Simulator                                              // optional, see SetSimulator
+}
+
+// Simulator plays supplier replies in dry-run on a virtual clock, so Quote
+// runs through to the comparison (see comprador/simulator).
+type Simulator interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	// Play answers the messages sent so far, delivering the replies that
+	// fall within window.
+	Play(ctx context.Context, window time.Duration) error
 }
 
 // New creates a new Comprador agent.
-// If cfg.DryRun is false, pass a real whatsapp.MessageSender via WithSender or
-// call NewWithWhatsApp to auto-connect via whatsmeow.
+// If cfg.DryRun is false, pass a real whatsapp.MessageSender via SetSender.
+// Every sender is wrapped in whatsapp.Throttled with cfg.Throttle.
 func New(db *sql.DB, cl *claude.Client, cfg Config) *Agent {
-	sender := whatsapp.MessageSender(whatsapp.NewMockSender())
+	// Dry-run sends must not count against (or mark contacts for) the real number
+	var sendLog whatsapp.SendLog = whatsapp.NewSQLSendLog(db)
+	if cfg.DryRun {
+		sendLog = whatsapp.NewMemorySendLog()
+	}
+	if cfg.OwnerPhone != "" {
+		cfg.Throttle.Exempt = append(cfg.Throttle.Exempt, cfg.OwnerPhone)
+	}
+	if cfg.Group != "" {
+		cfg.Throttle.Exempt = append(cfg.Throttle.Exempt, cfg.Group)
+	}
+	sender := whatsapp.MessageSender(whatsapp.NewThrottled(whatsapp.NewMockSender(), cfg.Throttle, sendLog))
 
 	supStore := suppliers.NewStore(db)
 	qStore := suppliers.NewQuoteStore(db)
 	matcher := suppliers.NewMatcher(cl, supStore)
-	qManager := NewQuoteManager(cl, sender, supStore, qStore)
+	msgStore := messages.NewStore(db)
+	dnc := suppliers.NewDNCStore(db)
+	outbox := NewOutboxStore(db)
+	qManager := NewQuoteManager(cl, sender, supStore, qStore, msgStore, dnc, dryRunOutbox(outbox, cfg.DryRun))
 	memStore := memory.NewStore(db)
+	rStore := NewRequestStore(db)
+
+	a := &Agent{
+		cfg:       cfg,
+		claude:    cl,
+		sender:    sender,
+		supStore:  supStore,
+		qStore:    qStore,
+		matcher:   matcher,
+		qManager:  qManager,
+		memStore:  memStore,
+		rStore:    rStore,
+		msgStore:  msgStore,
+		dnc:       dnc,
+		outbox:    outbox,
+		members:   NewMemberStore(db),
+		scores:    suppliers.NewScoreStore(db),
+		feedback:  suppliers.NewFeedbackStore(db),
+		cats:      suppliers.NewCategoryStore(db),
+		places:    NewPlaceStore(db),
+		prices:    suppliers.NewPriceStore(db),
+		sendLog:   sendLog,
+		input:     bufio.NewReader(os.Stdin),
+		finishing: make(map[string]bool),
+		wake:      make(chan struct{}, 1),
+	}
+	// Simulated replies go through the same handlers as real ones
+	_ = sender.Listen(a.handleIncoming)
+	_ = sender.ListenReceipts(a.handleReceipt)
+	return a
+}
+
+// dryRunOutbox hides the outbox in dry-run, so a rehearsal never queues
+// messages that a real daemon would later send.
+func dryRunOutbox(outbox *OutboxStore, dryRun bool) *OutboxStore {
+	if dryRun {
+		return nil
+	}
+	return outbox
+}
 
-	return &Agent{
-		cfg:      cfg,
-		claude:   cl,
-		sender:   sender,
-		supStore: supStore,
-		qStore:   qStore,
-		matcher:  matcher,
-		qManager: qManager,
-		memStore: memStore,
+// MockSender returns the dry-run sender, or nil once a real one is set.
+func (a *Agent) MockSender() *whatsapp.MockSender {
+	var s whatsapp.MessageSender = a.sender
+	for s != nil {
+		if m, ok := s.(*whatsapp.MockSender); ok {
+			return m
+		}
+		w, ok := s.(interface{ Unwrap() whatsapp.MessageSender })
+		if !ok {
+			return nil
+		}
+		s = w.Unwrap()
 	}
+	return nil
+}
+
+// SetSimulator makes dry-run quotes wait on sim instead of stopping after
+// sending; sends are paced on its virtual clock.
+func (a *Agent) SetSimulator(sim Simulator) {
+	a.sim = sim
+	if t, ok := a.sender.(*whatsapp.Throttled); ok {
+		t.Now, t.Sleep = sim.Now, sim.Sleep
+	}
+}
+
+// SetInput makes the interactive prompts read from r, for a caller that
+// already reads stdin through r: two readers would split its buffered input.
+func (a *Agent) SetInput(r *bufio.Reader) {
+	a.input = r
 }
 
 // SetSender swaps the WhatsApp sender (used to inject the real sender after QR login).
 func (a *Agent) SetSender(s whatsapp.MessageSender) {
+	if _, ok := s.(*whatsapp.Throttled); !ok {
+		s = whatsapp.NewThrottled(s, a.cfg.Throttle, a.sendLog)
+	}
 	a.sender = s
-	a.qManager = NewQuoteManager(a.claude, s, a.supStore, a.qStore)
+	a.qManager = NewQuoteManager(a.claude, s, a.supStore, a.qStore, a.msgStore, a.dnc, dryRunOutbox(a.outbox, a.cfg.DryRun))
 
 	// Register response handler: when a supplier replies, update the quote in the DB
-	_ = s.Listen(func(from, msg string) {
-		a.handleIncoming(from, msg)
-	})
+	_ = s.Listen(a.handleIncoming)
+	_ = s.ListenReceipts(a.handleReceipt)
+}
+
+// Close closes the sender, cutting short any send still waiting its turn.
+func (a *Agent) Close() error {
+	return a.sender.Close()
 }
 
-// handleIncoming matches an incoming WhatsApp message to a supplier quote and records it.
-func (a *Agent) handleIncoming(from, msg string) {
-	sup, err := a.supStore.ByPhone(from)
+// handleReceipt records that messages we sent were delivered or read.
+func (a *Agent) handleReceipt(r whatsapp.Receipt) {
+	if err := a.msgStore.MarkReceipt(r.MessageIDs, r.Status, r.Timestamp); err != nil {
+		fmt.Printf("[erro] registrar confirmação de %s: %v\n", r.From, err)
+	}
+}
+
+// handleIncoming routes an incoming WhatsApp message: owner messages are
+// commands, supplier messages are quote replies, anything else waits in the
+// inbox. Every message is recorded.
+func (a *Agent) handleIncoming(in whatsapp.IncomingMessage) {
+	if in.Group != "" {
+		a.handleGroup(in)
+		return
+	}
+	if a.isOwner(in.From) {
+		a.recordIncoming(in, "", "")
+		var images []string
+		if in.Media != nil && in.Media.Kind == whatsapp.MediaImage {
+			images = append(images, in.Media.Path)
+		}
+		if in.Body != "" || len(images) > 0 {
+			go a.handleCommand(context.Background(), a.ownerOrigin(), in.Body, images)
+		}
+		return
+	}
+
+	sup, err := a.supStore.ByAddress(in.From)
+	if IsOptOut(in.Body) {
+		a.recordIncoming(in, supplierID(sup), "")
+		a.optOut(in.From, sup)
+		return
+	}
 	if err != nil || sup == nil {
-		return // unknown sender — ignore
+		a.recordIncoming(in, "", "")
+		fmt.Printf("\n[mensagem de contato desconhecido] %s: %s\n(veja 'comprador inbox')\n\n", in.From, in.Body)
+		return
+	}
+
+	// Media is kept on disk and referenced from the quote; the text response
+	// carries a placeholder so the comparison knows a file was sent.
+	text := in.Body
+	var attachments []string
+	if in.Media != nil {
+		attachments = append(attachments, in.Media.Path)
+		text = strings.TrimSpace(in.Media.Describe() + " " + text)
 	}
-	fmt.Printf("\n[WhatsApp recebido] %s (%s):\n%s\n\n", sup.Name, from, msg)
-	if err := a.qStore.UpdateBySupplier(sup.ID, msg); err != nil {
+
+	fmt.Printf("\n[WhatsApp recebido] %s (%s):\n%s\n\n", sup.Name, in.From, text)
+
+	// A reply that names the message it answers goes to that request only
+	requestID, err := a.msgStore.RequestByRef(in.ThreadRefs)
+	if err != nil {
+		fmt.Printf("[erro] localizar pedido da resposta de %s: %v\n", sup.Name, err)
+	}
+	a.recordIncoming(in, sup.ID, requestID)
+	if requestID != "" {
+		err = a.qStore.UpdateForRequest(requestID, sup.ID, text, attachments)
+	} else {
+		err = a.qStore.UpdateBySupplier(sup.ID, text, attachments)
+	}
+	if err != nil {
 		fmt.Printf("[erro] ao registrar resposta de %s: %v\n", sup.Name, err)
+		return
+	}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Quote orchestrates the full buy quote flow in the foreground: it dispatches
+// the request, blocks until the deadline (or until every supplier replied) and
+// then compares the replies. When a daemon is running ('comprador serve'),
+// the CLI uses Prepare and hands the request to the daemon instead.
+func (a *Agent) Quote(ctx context.Context, description string, urgent bool, images ...string) error {
+	req, err := a.Prepare(ctx, description, urgent, images...)
+	if err != nil {
+		return err
+	}
+	return a.Execute(ctx, req)
+}
+
+// Execute runs a prepared request in the foreground, as Quote does; it lets
+// the caller adjust the request (e.g. BypassHours) between the two steps.
+func (a *Agent) Execute(ctx context.Context, req *QuoteRequest) error {
+	n, err := a.Dispatch(ctx, req)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	if a.cfg.DryRun && a.sim != nil {
+		fmt.Printf("\n[simulação] Respostas dos fornecedores em tempo virtual (prazo: %.0f min)\n\n", req.Timeout.Minutes())
+		if err := a.sim.Play(ctx, req.Timeout); err != nil {
+			return fmt.Errorf("simulação: %w", err)
+		}
+		return a.Finish(ctx, req)
+	}
+	if a.cfg.DryRun {
+		fmt.Printf("\n[dry-run] Aguardaria %.0f minutos por respostas.\n", req.Timeout.Minutes())
+		fmt.Println("Em produção, o agente fica ouvindo o WhatsApp e consolida as respostas automaticamente.")
+		return nil
+	}
+
+	// Wait for responses (handler updates DB on arrival)
+	fmt.Printf("Aguardando respostas (timeout: %.0f min)...\n", req.Timeout.Minutes())
+	fmt.Println("Pressione Ctrl+C para encerrar e ver cotações parciais.")
+	fmt.Println()
+
+	for time.Now().Before(req.Deadline) {
+		a.FlushOutbox(ctx)
+		received, total, err := a.progress(req.ID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\r%d/%d respostas recebidas...", received, total)
+		if received == total {
+			fmt.Println()
+			break
+		}
+		time.Sleep(10 * time.Second)
 	}
+	fmt.Println()
+
+	return a.Finish(ctx, req)
 }
 
-// Quote orchestrates the full buy quote flow.
-func (a *Agent) Quote(ctx context.Context, description string, urgent bool) error {
+// Prepare parses the description into items and lets the user confirm them.
+// No supplier is contacted; pass the result to Dispatch (or to a daemon).
+// images are photos of the item, sent to the vision model and to suppliers.
+func (a *Agent) Prepare(ctx context.Context, description string, urgent bool, images ...string) (*QuoteRequest, error) {
+	return a.prepare(ctx, description, urgent, true, images)
+}
+
+// prepare parses the request; confirm=false skips the terminal prompt, as
+// needed when the request came from the owner over WhatsApp.
+func (a *Agent) prepare(ctx context.Context, description string, urgent, confirm bool, images []string) (*QuoteRequest, error) {
 	timeout := a.cfg.QuoteTimeout
 	if urgent {
 		timeout = 5 * time.Minute
@@ -103,143 +377,479 @@ func (a *Agent) Quote(ctx context.Context, description string, urgent bool) erro
 
 	fmt.Printf("Analisando pedido: %q\n\n", description)
 
-	// Step 1: Parse items
-	req, err := a.qManager.ParseRequest(ctx, description)
+	req, err := a.qManager.ParseRequest(ctx, description, images)
 	if err != nil {
-		return fmt.Errorf("analisar pedido: %w", err)
+		return nil, fmt.Errorf("analisar pedido: %w", err)
 	}
 	req.Urgent = urgent
 	req.Timeout = timeout
 
-	// Step 1.5: Confirm items with user (skip if auto-confirm or non-interactive)
-	req, err = a.confirmItems(ctx, req)
+	// Confirm items with user (skip if auto-confirm or non-interactive)
+	req, err = a.confirmItems(ctx, req, confirm)
 	if err != nil {
-		return fmt.Errorf("confirmar itens: %w", err)
+		return nil, fmt.Errorf("confirmar itens: %w", err)
+	}
+	if confirm && a.cfg.EstimateFirst {
+		if err := a.estimateFirst(req); err != nil {
+			return nil, fmt.Errorf("preços conhecidos: %w", err)
+		}
 	}
+	return req, nil
+}
+
+// Dispatch finds matching suppliers, records the request and sends the quote
+// messages. It returns the number of suppliers contacted (0 when none match).
+// The request stays open until Finish is called for it.
+func (a *Agent) Dispatch(ctx context.Context, req *QuoteRequest) (int, error) {
+	itemNames := req.itemNames()
 
-	// Step 2: Find matching suppliers
-	itemNames := make([]string, len(req.Items))
-	for i, it := range req.Items {
-		itemNames[i] = it.Name
+	if req.Place == "" {
+		if err := a.SetPlace(req, "", 0); err != nil {
+			return 0, err
+		}
+	}
+	if req.MaxRadius == 0 && !req.Near.IsZero() {
+		req.MaxRadius = a.cfg.MaxRadius
+	}
+	sups, err := a.matcher.Match(ctx, itemNames, suppliers.MatchOptions{City: a.cfg.City, Origin: req.Near, MaxRadius: req.MaxRadius})
+	if err != nil {
+		return 0, fmt.Errorf("buscar fornecedores: %w", err)
 	}
 
-	sups, err := a.matcher.Match(ctx, itemNames, a.cfg.City)
+	if len(req.Skip) > 0 {
+		skip := make(map[string]bool, len(req.Skip))
+		for _, id := range req.Skip {
+			skip[id] = true
+		}
+		kept := sups[:0]
+		for _, s := range sups {
+			if !skip[s.Supplier.ID] {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 && len(sups) > 0 {
+			fmt.Println("Todos os fornecedores encontrados têm preço recente; nenhum pedido enviado.")
+			return 0, nil
+		}
+		sups = kept
+	}
+
+	sel, err := a.selectSuppliers(req, sups)
 	if err != nil {
-		return fmt.Errorf("buscar fornecedores: %w", err)
+		return 0, err
+	}
+	for _, s := range sel.resting {
+		fmt.Printf("Em descanso: %s (%d pedidos nos últimos 7 dias)\n", s.Supplier.Name, sel.asked[s.Supplier.ID].Recent)
+	}
+	if len(sel.chosen) == 0 && len(sel.resting) > 0 {
+		fmt.Println("Todos os fornecedores encontrados já receberam pedidos demais nesta semana; nenhum pedido enviado.")
+		return 0, nil
+	}
+	if sel.left > 0 {
+		fmt.Printf("%d fornecedor(es) ficaram de fora pelo limite de %d por pedido (estratégia %s).\n",
+			sel.left, a.maxSuppliers(req), sel.strategy)
 	}
+	sups = sel.chosen
 
 	if len(sups) == 0 {
 		fmt.Println("Nenhum fornecedor encontrado para esses itens.")
+		if req.MaxRadius > 0 {
+			fmt.Printf("Só foram considerados fornecedores com localização a até %s de %s.\n",
+				suppliers.FormatDistance(req.MaxRadius), req.Place)
+		}
 		fmt.Println("Dica: cadastre fornecedores com 'comprador suppliers add'")
-		return nil
+		return 0, nil
 	}
 
 	fmt.Printf("Enviando cotação para %d fornecedor(es):\n", len(sups))
 	for _, s := range sups {
-		fmt.Printf("  • %s (%s) — %s\n", s.Supplier.Name, s.Supplier.City, s.Reason)
+		where := s.Supplier.City
+		if s.Distance >= 0 {
+			where += ", " + suppliers.FormatDistance(s.Distance)
+			if s.Delivers {
+				where += ", entrega"
+			}
+		}
+		reason := s.Reason
+		if sel.explored[s.Supplier.ID] {
+			reason += " (exploração)"
+		}
+		fmt.Printf("  • %s (%s) — %s\n", s.Supplier.Name, where, reason)
 	}
 	fmt.Println()
 
-	// Step 3: Send quotes
+	// Record the request before sending so replies can always be attributed
+	if err := a.archiveImages(req); err != nil {
+		return 0, fmt.Errorf("guardar fotos: %w", err)
+	}
+	req.CreatedAt = time.Now()
+	req.Deadline = req.CreatedAt.Add(req.Timeout)
+	req.Status = StatusOpen
+	if err := a.rStore.Create(req); err != nil {
+		return 0, fmt.Errorf("registrar pedido: %w", err)
+	}
+
 	supList := make([]suppliers.Supplier, len(sups))
 	for i, s := range sups {
 		supList[i] = s.Supplier
 	}
-	if err := a.qManager.SendQuotes(ctx, req, supList); err != nil {
-		return fmt.Errorf("enviar cotações: %w", err)
+	deferred, err := a.qManager.SendQuotes(ctx, req, supList)
+	if err != nil {
+		return 0, fmt.Errorf("enviar cotações: %w", err)
+	}
+	// Suppliers contacted when they open get the full window to answer
+	if !deferred.IsZero() && deferred.Add(req.Timeout).After(req.Deadline) {
+		req.Deadline = deferred.Add(req.Timeout)
+		if err := a.rStore.SetDeadline(req.ID, req.Deadline); err != nil {
+			return 0, fmt.Errorf("adiar prazo: %w", err)
+		}
+		fmt.Printf("\nAlguns fornecedores estão fechados; prazo estendido até %s.\n", req.Deadline.Format("02/01 15h04"))
 	}
 
 	// Notify owner that quotes were sent
-	deadline := time.Now().Add(timeout)
 	supNames := make([]string, len(supList))
 	for i, s := range supList {
 		supNames[i] = s.Name
 	}
 	a.notify(fmt.Sprintf(
 		"✅ Cotação enviada!\n\nPedido: %q\nFornecedores: %s\nAguardando respostas até %s.",
-		description, strings.Join(supNames, ", "), deadline.Format("15h04"),
+		req.Description, strings.Join(supNames, ", "), req.Deadline.Format("02/01 15h04"),
 	))
 
-	if a.cfg.DryRun {
-		fmt.Printf("\n[dry-run] Aguardaria %.0f minutos por respostas.\n", timeout.Minutes())
-		fmt.Println("Em produção, o agente fica ouvindo o WhatsApp e consolida as respostas automaticamente.")
-		return nil
+	return len(sups), nil
+}
+
+// Finish compares the replies received for req, notifies the owner, saves the
+// purchase to memory and closes the request. A request is only finished once,
+// so the daemon loop and the foreground flow can both call it safely. The
+// comparison runs without holding a.mu; a failed one is retried later with
+// backoff, and after maxCompareFailures the request is closed with the raw
+// replies sent to the owner.
+func (a *Agent) Finish(ctx context.Context, req *QuoteRequest) error {
+	cur, ok, err := a.claimFinish(req.ID)
+	if err != nil || !ok {
+		return err
 	}
+	defer a.releaseFinish(req.ID)
 
-	// Step 4: Wait for responses (handler updates DB on arrival)
-	fmt.Printf("Aguardando respostas (timeout: %.0f min)...\n", timeout.Minutes())
-	fmt.Println("Pressione Ctrl+C para encerrar e ver cotações parciais.\n")
+	quotes, err := a.qStore.PendingByRequest(req.ID)
+	if err != nil {
+		return err
+	}
+	received, err := a.qStore.ReceivedByRequest(req.ID)
+	if err != nil {
+		return err
+	}
 
-	for time.Now().Before(deadline) {
-		quotes, err := a.qStore.PendingByRequest(req.ID)
-		if err != nil {
-			return err
-		}
+	if len(received) == 0 {
+		return a.closeOpen(req.ID, func() {
+			fmt.Printf("Nenhuma resposta recebida para %q.\n", req.Description)
+			a.notify(fmt.Sprintf(
+				"🛒 Cotação: %q\n\nNenhuma resposta recebida em %.0f minutos.\nFornecedores contactados: %d",
+				req.Description, req.Timeout.Minutes(), len(quotes),
+			))
+		})
+	}
+
+	comparison, err := a.qManager.CompareQuotes(ctx, req, received)
+	if err != nil {
+		return a.compareFailed(req, cur, received, err)
+	}
 
-		received := 0
-		for _, q := range quotes {
-			if q.Status == "received" {
-				received++
+	// Prices feed the supplier scores and the approval limit
+	for i, q := range received {
+		if price, ok := comparison.Prices[q.ID]; ok {
+			if err := a.qStore.SetPrice(q.ID, price); err != nil {
+				fmt.Printf("[erro] registrar preço da cotação %s: %v\n", q.ID, err)
 			}
+			received[i].Price = price
 		}
+	}
+	if err := a.recordPrices(req, received, comparison.UnitPrices); err != nil {
+		fmt.Printf("[erro] registrar preços por item: %v\n", err)
+	}
 
-		fmt.Printf("\r%d/%d respostas recebidas...", received, len(sups))
-		if received == len(sups) {
-			fmt.Println()
-			break
-		}
-		time.Sleep(10 * time.Second)
+	return a.closeOpen(req.ID, func() {
+		fmt.Printf("\n=== Comparação de Cotações: %s ===\n", req.Description)
+		fmt.Println(comparison.Table)
+		fmt.Printf("\nRecomendação: %s\n", comparison.Recommendation)
+		fmt.Printf("Melhor fornecedor: %s | Total estimado: R$ %.2f\n", comparison.BestSupplier, comparison.TotalPrice)
+
+		// Notify owner
+		a.notify(fmt.Sprintf(
+			"🛒 Cotação: %q\n\n%d/%d fornecedores responderam.\n\n%s\n\nMelhor fornecedor: %s\nTotal estimado: R$ %.2f\n\nRecomendação: %s\n\n%s",
+			req.Description, len(received), len(quotes), comparison.Table, comparison.BestSupplier, comparison.TotalPrice, comparison.Recommendation,
+			a.optionsText(received),
+		))
+
+		// Save the recommendation to memory; Accept replaces it with the owner's choice
+		_ = a.memStore.Save(memory.PurchaseRecord{
+			RequestID:      req.ID,
+			Description:    req.Description,
+			Items:          req.itemNames(),
+			ChosenSupplier: comparison.BestSupplier,
+			TotalPrice:     comparison.TotalPrice,
+		})
+	})
+}
+
+const (
+	// maxCompareFailures is how many failed comparisons close a request
+	// without one.
+	maxCompareFailures = 3
+	// compareBackoff is the wait after the first failed comparison; it
+	// doubles after each further failure.
+	compareBackoff = 2 * time.Minute
+)
+
+// claimFinish marks request id as being finished by this process. ok is
+// false when it is no longer open or another call is already finishing it.
+func (a *Agent) claimFinish(id string) (cur *QuoteRequest, ok bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur, err = a.rStore.Get(id)
+	if err != nil || (cur != nil && cur.Status != StatusOpen) || a.finishing[id] {
+		return nil, false, err
+	}
+	a.finishing[id] = true
+	return cur, true, nil
+}
+
+func (a *Agent) releaseFinish(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.finishing, id)
+}
+
+// closeOpen runs report and closes request id, unless it left "open" (say,
+// cancelled by the owner) while the replies were being compared.
+func (a *Agent) closeOpen(id string, report func()) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur, err := a.rStore.Get(id)
+	if err != nil {
+		return err
 	}
-	fmt.Println()
+	if cur != nil && cur.Status != StatusOpen {
+		return nil
+	}
+	report()
+	return a.rStore.SetStatus(id, StatusClosed)
+}
 
-	// Step 5: Compare quotes
-	quotes, _ := a.qStore.PendingByRequest(req.ID)
-	var received []suppliers.Quote
-	for _, q := range quotes {
-		if q.Status == "received" {
-			received = append(received, q)
+// compareFailed records a failed comparison of the replies to req. Until
+// maxCompareFailures it schedules a retry with backoff; then it closes the
+// request and sends the owner the replies as they came.
+func (a *Agent) compareFailed(req, cur *QuoteRequest, received []suppliers.Quote, cause error) error {
+	failures := 1
+	if cur != nil {
+		failures += cur.CompareFailures
+	}
+	if failures < maxCompareFailures {
+		retryAt := time.Now().Add(compareBackoff << (failures - 1))
+		if err := a.rStore.CompareFailed(req.ID, retryAt); err != nil {
+			return err
 		}
+		return fmt.Errorf("comparar cotações (tentativa %d de %d, nova tentativa às %s): %w",
+			failures, maxCompareFailures, retryAt.Format("15h04"), cause)
 	}
 
-	if len(received) == 0 {
-		fmt.Println("Nenhuma resposta recebida no período.")
+	err := a.closeOpen(req.ID, func() {
+		fmt.Printf("Comparação de %q falhou %d vezes; pedido fechado com as respostas recebidas.\n", req.Description, failures)
 		a.notify(fmt.Sprintf(
-			"🛒 Cotação: %q\n\nNenhuma resposta recebida em %.0f minutos.\nFornecedores contactados: %d",
-			description, timeout.Minutes(), len(sups),
+			"🛒 Cotação: %q\n\nNão consegui comparar as respostas (%v). Seguem como chegaram:\n\n%s\n\n%s",
+			req.Description, cause, a.repliesText(received), a.optionsText(received),
 		))
-		return nil
+	})
+	if err != nil {
+		return err
 	}
+	return fmt.Errorf("comparar cotações (desistindo após %d tentativas): %w", failures, cause)
+}
 
-	comparison, err := a.qManager.CompareQuotes(ctx, req, received)
+// repliesText lists each reply under its supplier's name.
+func (a *Agent) repliesText(quotes []suppliers.Quote) string {
+	var b strings.Builder
+	for i, q := range quotes {
+		name := q.SupplierID
+		if sup, err := a.supStore.Get(q.SupplierID); err == nil && sup != nil {
+			name = sup.Name
+		}
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "• %s: %s", name, q.Response)
+	}
+	return b.String()
+}
+
+// CloseDue finishes every open request whose deadline has passed or whose
+// suppliers have all replied. Errors are reported per request and do not stop
+// the others from being processed.
+func (a *Agent) CloseDue(ctx context.Context) {
+	open, err := a.rStore.Open()
 	if err != nil {
-		return fmt.Errorf("comparar cotações: %w", err)
+		fmt.Printf("[erro] listar pedidos abertos: %v\n", err)
+		return
+	}
+	for _, req := range open {
+		received, total, err := a.progress(req.ID)
+		if err != nil {
+			fmt.Printf("[erro] progresso de %s: %v\n", req.ID, err)
+			continue
+		}
+		if time.Now().Before(req.Deadline) && received < total {
+			continue
+		}
+		if time.Now().Before(req.RetryAt) {
+			continue // the last comparison failed; wait out the backoff
+		}
+		if err := a.Finish(ctx, &req); err != nil {
+			fmt.Printf("[erro] fechar pedido %q: %v\n", req.Description, err)
+		}
 	}
+}
 
-	fmt.Println("\n=== Comparação de Cotações ===")
-	fmt.Println(comparison.Table)
-	fmt.Printf("\nRecomendação: %s\n", comparison.Recommendation)
-	fmt.Printf("Melhor fornecedor: %s | Total estimado: R$ %.2f\n", comparison.BestSupplier, comparison.TotalPrice)
+// Health reports the state of the WhatsApp link.
+func (a *Agent) Health() whatsapp.Health {
+	return a.sender.Health()
+}
 
-	// Step 6: Notify owner
-	a.notify(fmt.Sprintf(
-		"🛒 Cotação: %q\n\n%d/%d fornecedores responderam.\n\n%s\n\nMelhor fornecedor: %s\nTotal estimado: R$ %.2f\n\nRecomendação: %s",
-		description, len(received), len(sups), comparison.Table, comparison.BestSupplier, comparison.TotalPrice, comparison.Recommendation,
-	))
+// StatusReport describes the open requests and their reply progress,
+// preceded by a warning when WhatsApp is not connected.
+func (a *Agent) StatusReport() (string, error) {
+	open, err := a.rStore.Open()
+	if err != nil {
+		return "", err
+	}
 
-	// Step 7: Save to memory
-	_ = a.memStore.Save(memory.PurchaseRecord{
-		Description:    description,
-		Items:          itemNames,
-		ChosenSupplier: comparison.BestSupplier,
-		TotalPrice:     comparison.TotalPrice,
-	})
+	var b strings.Builder
+	if h := a.Health(); !h.OK() {
+		fmt.Fprintf(&b, "⚠️ WhatsApp %s desde %s", healthLabel(h.State), h.Since.Format("02/01 15h04"))
+		if h.Detail != "" {
+			fmt.Fprintf(&b, " (%s)", h.Detail)
+		}
+		b.WriteString("\n\n")
+	}
+	if len(open) == 0 {
+		b.WriteString("Nenhum pedido em aberto.")
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(&b, "%d pedido(s) em aberto:\n", len(open))
+	for _, req := range open {
+		received, total, err := a.progress(req.ID)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n• %s\n  %d/%d respostas | prazo: %s | ID: %s\n",
+			req.Description, received, total, req.Deadline.Format("02/01 15h04"), req.ID)
+		if err := a.deliveryLines(&b, req.ID); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func healthLabel(state string) string {
+	switch state {
+	case whatsapp.HealthConnecting:
+		return "reconectando"
+	case whatsapp.HealthLoggedOut:
+		return "desconectado (parear de novo)"
+	default:
+		return "fora do ar"
+	}
+}
+
+// deliveryLines lists each supplier of a request with how far its quote got:
+// sent, delivered, read, answered — or not on WhatsApp at all.
+func (a *Agent) deliveryLines(b *strings.Builder, requestID string) error {
+	status, err := a.msgStore.StatusByRequest(requestID)
+	if err != nil {
+		return err
+	}
+	quotes, err := a.qStore.PendingByRequest(requestID)
+	if err != nil {
+		return err
+	}
+	answered := make(map[string]bool, len(quotes))
+	for _, q := range quotes {
+		if q.Status == "received" {
+			answered[q.SupplierID] = true
+		}
+	}
 
+	var lines []string
+	for supID, st := range status {
+		name := supID
+		if sup, err := a.supStore.Get(supID); err == nil && sup != nil {
+			name = sup.Name
+		}
+		label := map[string]string{
+			messages.StatusSent:      "✓ enviada",
+			messages.StatusDelivered: "✓✓ entregue",
+			messages.StatusRead:      "✓✓ lida",
+			messages.StatusInvalid:   "✗ sem WhatsApp",
+		}[st]
+		if answered[supID] {
+			label = "💬 respondeu"
+		}
+		lines = append(lines, fmt.Sprintf("    %s: %s\n", name, label))
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		b.WriteString(l)
+	}
 	return nil
 }
 
+// SupplierStat is the delivery and reply record of one supplier.
+type SupplierStat struct {
+	Supplier suppliers.Supplier
+	messages.Stats
+}
+
+// SupplierStats returns delivery statistics for every active supplier.
+func (a *Agent) SupplierStats() ([]SupplierStat, error) {
+	sups, err := a.supStore.List()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := a.msgStore.SupplierStats()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SupplierStat, len(sups))
+	for i, s := range sups {
+		out[i] = SupplierStat{Supplier: s, Stats: stats[s.ID]}
+	}
+	return out, nil
+}
+
+// Wake is signalled whenever a supplier reply is recorded, so a daemon can
+// close the request right away instead of waiting for its next tick.
+func (a *Agent) Wake() <-chan struct{} {
+	return a.wake
+}
+
+// progress returns how many quotes of a request were answered, out of how many sent.
+func (a *Agent) progress(requestID string) (received, total int, err error) {
+	quotes, err := a.qStore.PendingByRequest(requestID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, q := range quotes {
+		if q.Status == "received" {
+			received++
+		}
+	}
+	return received, len(quotes), nil
+}
+
 // confirmItems shows parsed items to the user and allows corrections before sending.
 // Skips if AutoConfirm is set or stdin is not a terminal.
-func (a *Agent) confirmItems(ctx context.Context, req *QuoteRequest) (*QuoteRequest, error) {
+func (a *Agent) confirmItems(ctx context.Context, req *QuoteRequest, confirm bool) (*QuoteRequest, error) {
 	printItems := func(items []ParsedItem) {
 		fmt.Println("Itens identificados:")
 		for _, it := range items {
@@ -254,14 +864,13 @@ func (a *Agent) confirmItems(ctx context.Context, req *QuoteRequest) (*QuoteRequ
 
 	printItems(req.Items)
 
-	if a.cfg.AutoConfirm || !isInteractive() {
+	if !confirm || a.cfg.AutoConfirm || !isInteractive() {
 		return req, nil
 	}
 
-	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Print("Confirma? (Enter para enviar, ou descreva a correção): ")
-		correction, _ := reader.ReadString('\n')
+		correction, _ := a.input.ReadString('\n')
 		correction = strings.TrimSpace(correction)
 
 		if correction == "" {
@@ -269,7 +878,7 @@ func (a *Agent) confirmItems(ctx context.Context, req *QuoteRequest) (*QuoteRequ
 		}
 
 		// Re-parse with the correction merged into the original description
-		corrected, err := a.qManager.ParseRequest(ctx, req.Description+" — correção: "+correction)
+		corrected, err := a.qManager.ParseRequest(ctx, req.Description+" — correção: "+correction, req.Images)
 		if err != nil {
 			return nil, err
 		}
@@ -292,15 +901,20 @@ func isInteractive() bool {
 	return (fi.Mode() & os.ModeCharDevice) != 0
 }
 
-// notify sends a WhatsApp message to the owner if configured.
+// notify sends a WhatsApp message to the household group, or to the owner
+// when no group is configured.
 func (a *Agent) notify(msg string) {
-	if a.cfg.OwnerPhone == "" || a.cfg.DryRun {
+	to := a.cfg.Group
+	if to == "" {
+		to = a.cfg.OwnerPhone
+	}
+	if to == "" || a.cfg.DryRun {
 		return
 	}
-	if err := a.sender.Send(a.cfg.OwnerPhone, msg); err != nil {
-		fmt.Printf("[notify] erro ao notificar dono: %v\n", err)
+	if err := a.send(to, msg, ""); err != nil {
+		fmt.Printf("[notify] erro ao notificar %s: %v\n", to, err)
 	} else {
-		fmt.Printf("[notify] resumo enviado para %s\n", a.cfg.OwnerPhone)
+		fmt.Printf("[notify] resumo enviado para %s\n", to)
 	}
 }
 
@@ -314,6 +928,11 @@ func (a *Agent) History(ctx context.Context, n int) error {
 	return nil
 }
 
+// HistoryText returns the recent purchases as text.
+func (a *Agent) HistoryText(n int) (string, error) {
+	return a.memStore.Format(n)
+}
+
 // RepeatLast repeats the last purchase.
 func (a *Agent) RepeatLast(ctx context.Context) error {
 	last, err := a.memStore.Last()
@@ -328,8 +947,11 @@ func (a *Agent) RepeatLast(ctx context.Context) error {
 	return a.Quote(ctx, last.Description, false)
 }
 
-// AddSupplier adds a new supplier.
+// AddSupplier validates and adds a new supplier.
 func (a *Agent) AddSupplier(sup suppliers.Supplier) error {
+	if err := sup.Validate(); err != nil {
+		return err
+	}
 	id, err := a.supStore.Add(sup)
 	if err != nil {
 		return fmt.Errorf("adicionar fornecedor: %w", err)
@@ -338,9 +960,24 @@ func (a *Agent) AddSupplier(sup suppliers.Supplier) error {
 	return nil
 }
 
-// ListSuppliers lists all active suppliers.
-func (a *Agent) ListSuppliers() error {
-	sups, err := a.supStore.List()
+// Suppliers returns all active suppliers.
+func (a *Agent) Suppliers() ([]suppliers.Supplier, error) {
+	return a.supStore.List()
+}
+
+// SupplierByAddress returns the supplier reached at addr, or nil.
+func (a *Agent) SupplierByAddress(addr string) (*suppliers.Supplier, error) {
+	return a.supStore.ByAddress(addr)
+}
+
+// ListSuppliers lists the active suppliers, or all of them (marking the
+// inactive ones) when all is set.
+func (a *Agent) ListSuppliers(all bool) error {
+	list := a.supStore.List
+	if all {
+		list = a.supStore.ListAll
+	}
+	sups, err := list()
 	if err != nil {
 		return err
 	}
@@ -358,7 +995,15 @@ func (a *Agent) ListSuppliers() error {
 			}
 			cats += c
 		}
-		fmt.Printf("%-30s %-15s %-15s %s\n", s.Name, s.City, s.Phone, cats)
+		contact := s.Phone
+		if s.Channel == whatsapp.ChannelTelegram {
+			contact = s.Address()
+		}
+		name := s.Name
+		if !s.Active {
+			name += " (inativo)"
+		}
+		fmt.Printf("%-30s %-15s %-15s %s\n", name, s.City, contact, cats)
 	}
 	return nil
 }
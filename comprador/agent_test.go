@@ -0,0 +1,143 @@
+package comprador
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/agente/comprador/messages"
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/claude"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// An e-mail reply goes to the request of the message it answers, not to
+// the supplier's latest open request.
+func TestEmailReplyThreadsOntoItsRequest(t *testing.T) {
+	a := newTestAgent(t, Config{})
+	mail := whatsapp.NewMemoryMailServer()
+	email := whatsapp.NewEmailSender("compras@casa.com.br", mail, mail, t.TempDir(), 10*time.Millisecond)
+	router := whatsapp.NewRouter(whatsapp.NewMockSender())
+	router.Add(whatsapp.ChannelEmail, email)
+	a.SetSender(router)
+	t.Cleanup(func() { email.Close() })
+
+	sup := suppliers.Supplier{Name: "Atacadão", Channel: whatsapp.ChannelEmail, Email: "vendas@atacadao.com.br", Active: true}
+	id, err := a.supStore.Add(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup.ID = id
+
+	now := time.Now()
+	for i, reqID := range []string{"older", "newer"} {
+		created := now.Add(time.Duration(i-2) * time.Hour)
+		req := &QuoteRequest{ID: reqID, Description: reqID, Status: StatusOpen, CreatedAt: created, Deadline: now.Add(time.Hour)}
+		if err := a.rStore.Create(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.qStore.CreateQuote(suppliers.Quote{ID: "q-" + reqID, RequestID: reqID, SupplierID: sup.ID, CreatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+		waID, err := a.sender.Send(sup.Address(), "Pedido "+reqID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.qManager.record(messages.Message{WAID: waID, Phone: sup.Address(), Body: "Pedido " + reqID, RequestID: reqID, SupplierID: sup.ID})
+	}
+
+	sent := mail.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d e-mails, want 2", len(sent))
+	}
+	mail.Reply(sent[0], sup.Email, "Arroz 5kg R$ 24,90")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		received, err := a.qStore.ReceivedByRequest("older")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(received) == 1 {
+			if received[0].ID != "q-older" {
+				t.Errorf("reply recorded on %s", received[0].ID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reply not recorded on the request it answers")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if received, _ := a.qStore.ReceivedByRequest("newer"); len(received) != 0 {
+		t.Errorf("newer request got the reply: %+v", received)
+	}
+}
+
+// A comparison that keeps failing is retried with backoff, then the request
+// is closed and the owner gets the replies as they came.
+func TestFinishGivesUpAfterFailedComparisons(t *testing.T) {
+	a := newTestAgent(t, Config{OwnerPhone: "5567999990000"})
+	a.cfg.DryRun = false // let the owner be notified (through the mock sender)
+	t.Setenv("OPENROUTER_API_KEY", "test")
+	cl, err := claude.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.qManager.claude = cl
+	// A cancelled context makes every comparison fail without a network call
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	id, err := a.supStore.Add(suppliers.Supplier{Name: "Atacadão", Phone: "5567911110000", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	req := &QuoteRequest{ID: "r", Description: "arroz", Status: StatusOpen, CreatedAt: now.Add(-2 * time.Hour), Deadline: now.Add(-time.Hour)}
+	if err := a.rStore.Create(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.qStore.CreateQuote(suppliers.Quote{ID: "q", RequestID: "r", SupplierID: id, CreatedAt: req.CreatedAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.qStore.UpdateForRequest("r", id, "Arroz 5kg R$ 24,90", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Finish(ctx, req); err == nil {
+		t.Fatal("first comparison: want error")
+	}
+	cur, err := a.rStore.Get("r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur.Status != StatusOpen || cur.CompareFailures != 1 || !cur.RetryAt.After(now) {
+		t.Fatalf("after one failure: status %s, failures %d, retry at %v", cur.Status, cur.CompareFailures, cur.RetryAt)
+	}
+
+	// CloseDue waits out the backoff instead of calling the model again
+	a.CloseDue(ctx)
+	if cur, _ := a.rStore.Get("r"); cur.CompareFailures != 1 {
+		t.Errorf("CloseDue retried during the backoff: %d failures", cur.CompareFailures)
+	}
+
+	for i := 1; i < maxCompareFailures; i++ {
+		if err := a.Finish(ctx, req); err == nil {
+			t.Fatalf("comparison %d: want error", i+1)
+		}
+	}
+	if cur, _ := a.rStore.Get("r"); cur.Status != StatusClosed {
+		t.Fatalf("after %d failures: status %s, want closed", maxCompareFailures, cur.Status)
+	}
+	sent := a.MockSender().Sent
+	if len(sent) != 1 || sent[0].Phone != "5567999990000" || !strings.Contains(sent[0].Message, "Arroz 5kg R$ 24,90") {
+		t.Errorf("owner notification: got %+v, want the raw reply", sent)
+	}
+
+	// A closed request is not finished again
+	if err := a.Finish(ctx, req); err != nil {
+		t.Errorf("finish after closing: %v", err)
+	}
+}
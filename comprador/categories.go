@@ -0,0 +1,111 @@
+package comprador
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+// Taxonomy returns the supplier categories and how many suppliers have each.
+func (a *Agent) Taxonomy() (*suppliers.Taxonomy, map[string]int, error) {
+	tax, err := a.cats.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	counts, err := a.cats.Counts()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tax, counts, nil
+}
+
+// AddCategory creates a category.
+func (a *Agent) AddCategory(c suppliers.Category) error {
+	return a.cats.Add(c)
+}
+
+// UpdateCategory saves a category.
+func (a *Agent) UpdateCategory(c suppliers.Category) error {
+	return a.cats.Update(c)
+}
+
+// RemoveCategory deletes a category nobody uses.
+func (a *Agent) RemoveCategory(slug string) error {
+	return a.cats.Remove(slug)
+}
+
+// ClassifyItems maps item names to categories as the matcher does.
+func (a *Agent) ClassifyItems(ctx context.Context, items []string) (map[string][]string, error) {
+	return a.matcher.Classify(ctx, items)
+}
+
+// Spend is what accepted purchases cost, per category.
+type Spend struct {
+	Since         time.Time
+	Total         float64
+	Purchases     int
+	ByCategory    map[string]float64 // a category's total includes its subcategories
+	PurchasesBy   map[string]int     // purchases with an item in the category or below
+	Uncategorized float64            // items no category fits
+}
+
+// SpendByCategory adds up the accepted purchases made since the given time.
+// A purchase's total is split evenly among its items, and each item counts
+// for its category and every category above it.
+func (a *Agent) SpendByCategory(ctx context.Context, since time.Time) (*Spend, error) {
+	records, err := a.memStore.Since(since)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := a.cats.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// One classification for every item bought, rather than one per purchase
+	var items []string
+	seen := make(map[string]bool)
+	for _, r := range records {
+		for _, it := range r.Items {
+			if r.SupplierID != "" && !seen[it] {
+				seen[it] = true
+				items = append(items, it)
+			}
+		}
+	}
+	byItem, err := a.matcher.Classify(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spend{Since: since, ByCategory: make(map[string]float64), PurchasesBy: make(map[string]int)}
+	for _, r := range records {
+		if r.SupplierID == "" {
+			continue // a recommendation the owner never accepted
+		}
+		s.Total += r.TotalPrice
+		s.Purchases++
+		if len(r.Items) == 0 {
+			s.Uncategorized += r.TotalPrice
+			continue
+		}
+		share := r.TotalPrice / float64(len(r.Items))
+		touched := make(map[string]bool)
+		for _, item := range r.Items {
+			cats := byItem[item]
+			if len(cats) == 0 {
+				s.Uncategorized += share
+				continue
+			}
+			for _, c := range append([]string{cats[0]}, tax.Ancestors(cats[0])...) {
+				s.ByCategory[c] += share
+				touched[c] = true
+			}
+		}
+		for c := range touched {
+			s.PurchasesBy[c]++
+		}
+	}
+	return s, nil
+}
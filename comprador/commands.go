@@ -0,0 +1,399 @@
+package comprador
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/claude"
+)
+
+// Owner command kinds.
+const (
+	CmdQuote   = "quote"
+	CmdStatus  = "status"
+	CmdAccept  = "accept"
+	CmdCancel  = "cancel"
+	CmdHistory = "history"
+	CmdRate    = "rate"
+	CmdHelp    = "help"
+)
+
+// OwnerCommand is an instruction the owner sent to the agent over WhatsApp.
+type OwnerCommand struct {
+	Kind   string
+	Arg    string // item description for quote, option number for accept
+	Urgent bool
+}
+
+const ownerHelp = "Comandos disponíveis:\n" +
+	"• cotar <itens> — ex: cotar 10 sacos de cimento\n" +
+	"• cotar urgente <itens>\n" +
+	"• status — pedidos em aberto\n" +
+	"• aceitar <n> — escolhe a opção n da última comparação\n" +
+	"• cancelar — cancela o último pedido\n" +
+	"• histórico — últimas compras\n" +
+	"• nota <1-5> [comentário] — avalia a última compra entregue"
+
+// ParseCommand recognises the fixed owner commands. It returns false when the
+// text does not start with a known keyword, so the caller can fall back to
+// intent classification.
+func ParseCommand(text string) (OwnerCommand, bool) {
+	text = strings.TrimSpace(text)
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return OwnerCommand{}, false
+	}
+	keyword := foldAccents(strings.ToLower(fields[0]))
+	rest := strings.TrimSpace(text[len(fields[0]):])
+
+	switch keyword {
+	case "cotar", "cota", "cotacao", "orcar":
+		cmd := OwnerCommand{Kind: CmdQuote, Arg: rest}
+		if lower := foldAccents(strings.ToLower(rest)); strings.HasPrefix(lower, "urgente") {
+			cmd.Urgent = true
+			cmd.Arg = strings.TrimSpace(rest[len("urgente"):])
+		}
+		if cmd.Arg == "" {
+			return OwnerCommand{}, false
+		}
+		return cmd, true
+	case "status", "situacao", "pedidos":
+		return OwnerCommand{Kind: CmdStatus}, true
+	case "aceitar", "aceito", "aceita":
+		if _, err := strconv.Atoi(rest); err != nil {
+			return OwnerCommand{}, false
+		}
+		return OwnerCommand{Kind: CmdAccept, Arg: rest}, true
+	case "cancelar", "cancela":
+		return OwnerCommand{Kind: CmdCancel}, true
+	case "historico", "compras":
+		return OwnerCommand{Kind: CmdHistory}, true
+	case "nota", "avaliar", "avaliacao":
+		if _, _, err := parseRating(rest); err != nil {
+			return OwnerCommand{}, false
+		}
+		return OwnerCommand{Kind: CmdRate, Arg: rest}, true
+	case "ajuda", "help", "?", "menu":
+		return OwnerCommand{Kind: CmdHelp}, true
+	}
+	return OwnerCommand{}, false
+}
+
+// classifyIntent asks the model to map free-form owner text to a command.
+func (a *Agent) classifyIntent(ctx context.Context, text string) (OwnerCommand, error) {
+	tools := []claude.ToolDef{
+		{
+			Name:        "classify_owner_intent",
+			Description: "Classifica a mensagem do dono em um dos comandos do agente de compras",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"kind": map[string]any{
+						"type": "string",
+						"enum": []string{CmdQuote, CmdStatus, CmdAccept, CmdCancel, CmdHistory, CmdRate, CmdHelp},
+					},
+					"arg": map[string]any{
+						"type":        "string",
+						"description": "Para quote: itens e quantidades. Para accept: número da opção. Para rate: nota de 1 a 5 seguida do comentário.",
+					},
+					"urgent": map[string]any{"type": "boolean"},
+				},
+				"required": []string{"kind"},
+			},
+		},
+	}
+
+	cmd := OwnerCommand{Kind: CmdHelp}
+	_, err := a.claude.ChatWithTools(ctx, claude.ChatRequest{
+		System: "Você interpreta mensagens de WhatsApp do dono de um agente de compras. " +
+			"Pedidos para comprar ou cotar algo são 'quote'; perguntas sobre andamento são 'status'; " +
+			"escolha de uma opção numerada é 'accept'; desistência é 'cancel'; compras passadas é 'history'; " +
+			"uma nota de 1 a 5 para uma compra entregue é 'rate'. " +
+			"Se não for nenhum desses, use 'help'.",
+		User:  fmt.Sprintf("Mensagem do dono: %q", text),
+		Tools: tools,
+	}, func(name string, input json.RawMessage) (string, error) {
+		var r struct {
+			Kind   string `json:"kind"`
+			Arg    string `json:"arg"`
+			Urgent bool   `json:"urgent"`
+		}
+		if err := json.Unmarshal(input, &r); err != nil {
+			return "", err
+		}
+		cmd = OwnerCommand{Kind: r.Kind, Arg: r.Arg, Urgent: r.Urgent}
+		return "ok", nil
+	})
+	if err != nil {
+		return OwnerCommand{}, fmt.Errorf("classificar intenção: %w", err)
+	}
+	return cmd, nil
+}
+
+// handleCommand runs a command sent by the owner (or a group member) and
+// replies with the result where it came from. A photo is always a quote
+// request for what it shows, with the caption (minus a leading "cotar") as
+// the description. Free-form text goes to the assistant or the intent
+// classifier only in the owner's private chat.
+func (a *Agent) handleCommand(ctx context.Context, o origin, text string, images []string) {
+	fmt.Printf("\n[%s] %s\n", o.name, text)
+
+	// "cancela" answers the assistant's "(sim/não)" rather than cancelling
+	// the latest request
+	if !o.group && len(images) == 0 && a.assistant != nil && a.awaiting != nil && a.awaiting() {
+		reply, err := a.assistant(ctx, text)
+		if err != nil {
+			reply = "⚠️ " + err.Error()
+		}
+		a.reply(o, reply)
+		return
+	}
+
+	if len(images) > 0 {
+		cmd, ok := ParseCommand(text)
+		desc := text
+		if ok && cmd.Kind == CmdQuote {
+			desc = cmd.Arg
+		}
+		if strings.TrimSpace(desc) == "" {
+			desc = "item da foto"
+		}
+		reply, err := a.startQuote(ctx, o, desc, cmd.Urgent, images)
+		if err != nil {
+			reply = "⚠️ " + err.Error()
+		}
+		a.reply(o, reply)
+		return
+	}
+
+	cmd, ok := ParseCommand(text)
+	if !ok && o.group {
+		return
+	}
+	if !ok && a.assistant != nil {
+		reply, err := a.assistant(ctx, text)
+		if err != nil {
+			reply = "⚠️ " + err.Error()
+		}
+		a.reply(o, reply)
+		return
+	}
+	if !ok {
+		var err error
+		cmd, err = a.classifyIntent(ctx, text)
+		if err != nil {
+			a.reply(o, "Não entendi. "+ownerHelp)
+			return
+		}
+	}
+
+	reply, err := a.runCommand(ctx, o, cmd)
+	if err != nil {
+		reply = "⚠️ " + err.Error()
+	}
+	if reply != "" {
+		a.reply(o, reply)
+	}
+}
+
+// SetOwnerAssistant routes owner messages that are not fixed commands to a
+// conversational assistant instead of the intent classifier. While awaiting
+// reports that the assistant asked the owner to confirm something, every
+// message goes to it, fixed commands included.
+func (a *Agent) SetOwnerAssistant(fn func(ctx context.Context, text string) (string, error), awaiting func() bool) {
+	a.assistant, a.awaiting = fn, awaiting
+}
+
+// RunCommand executes an owner command and returns the text to send back.
+func (a *Agent) RunCommand(ctx context.Context, cmd OwnerCommand) (string, error) {
+	return a.runCommand(ctx, a.ownerOrigin(), cmd)
+}
+
+func (a *Agent) runCommand(ctx context.Context, o origin, cmd OwnerCommand) (string, error) {
+	switch cmd.Kind {
+	case CmdQuote:
+		return a.startQuote(ctx, o, cmd.Arg, cmd.Urgent, nil)
+	case CmdStatus:
+		return a.StatusReport()
+	case CmdAccept:
+		n, err := strconv.Atoi(strings.TrimSpace(cmd.Arg))
+		if err != nil {
+			return "", fmt.Errorf("informe o número da opção, ex: aceitar 2")
+		}
+		return a.accept(o, n)
+	case CmdCancel:
+		return a.Cancel()
+	case CmdHistory:
+		return a.memStore.Format(5)
+	case CmdRate:
+		rating, comment, err := parseRating(cmd.Arg)
+		if err != nil {
+			return "", err
+		}
+		return a.ratePending(rating, comment)
+	default:
+		return ownerHelp, nil
+	}
+}
+
+// StartQuote parses the request without interactive confirmation, dispatches
+// it in the background and returns what was understood. Failures during
+// dispatch are reported to the owner over WhatsApp.
+func (a *Agent) StartQuote(ctx context.Context, description string, urgent bool, images ...string) (string, error) {
+	return a.startQuote(ctx, a.ownerOrigin(), description, urgent, images)
+}
+
+// startQuote is StartQuote answering o, e.g. in the group.
+func (a *Agent) startQuote(ctx context.Context, o origin, description string, urgent bool, images []string) (string, error) {
+	req, err := a.prepare(ctx, description, urgent, false, images)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		n, err := a.Dispatch(ctx, req)
+		switch {
+		case err != nil:
+			a.reply(o, fmt.Sprintf("⚠️ Falha ao enviar cotação: %v", err))
+		case n == 0:
+			a.reply(o, "Nenhum fornecedor cadastrado atende esses itens.")
+		}
+	}()
+
+	var b strings.Builder
+	b.WriteString("Entendido! Vou cotar:\n")
+	for _, it := range req.Items {
+		fmt.Fprintf(&b, "• %.0f %s de %s\n", it.Qty, it.Unit, it.Name)
+	}
+	return b.String(), nil
+}
+
+// Accept records the owner's choice of the n-th option (1-based, as numbered
+// in the comparison message) of the most recent request awaiting a decision.
+func (a *Agent) Accept(n int) (string, error) {
+	return a.accept(a.ownerOrigin(), n)
+}
+
+// accept is Accept by o, who must be allowed to approve the option's price.
+func (a *Agent) accept(o origin, n int) (string, error) {
+	req, err := a.rStore.Latest(StatusClosed)
+	if err != nil {
+		return "", err
+	}
+	if req == nil {
+		return "Nenhuma comparação aguardando decisão.", nil
+	}
+
+	options, err := a.qStore.ReceivedByRequest(req.ID)
+	if err != nil {
+		return "", err
+	}
+	if n < 1 || n > len(options) {
+		return "", fmt.Errorf("opção %d inválida: %q tem %d opção(ões)", n, req.Description, len(options))
+	}
+	chosen := options[n-1]
+	if err := a.checkApproval(o, chosen.Price); err != nil {
+		return "", err
+	}
+
+	sup, err := a.supStore.Get(chosen.SupplierID)
+	if err != nil || sup == nil {
+		return "", fmt.Errorf("fornecedor da opção %d não encontrado", n)
+	}
+
+	if err := a.qStore.SetStatus(chosen.ID, "accepted"); err != nil {
+		return "", err
+	}
+	if err := a.qStore.RejectOthers(req.ID, chosen.ID); err != nil {
+		return "", err
+	}
+	if err := a.memStore.UpdateChoice(req.ID, sup.ID, sup.Name, chosen.Price); err != nil {
+		return "", err
+	}
+	if err := a.memStore.SetDeliveryDue(req.ID, time.Now().Add(a.deliveryDelay())); err != nil {
+		return "", err
+	}
+	if err := a.rStore.SetStatus(req.ID, StatusAccepted); err != nil {
+		return "", err
+	}
+	by := ""
+	if o.group {
+		by = " por " + o.name
+	}
+	return fmt.Sprintf("✅ %q: opção %d aceita%s — %s (%s).", req.Description, n, by, sup.Name, sup.Phone), nil
+}
+
+// Cancel cancels the most recent request that is still open or awaiting a decision.
+func (a *Agent) Cancel() (string, error) {
+	req, err := a.rStore.Latest(StatusOpen, StatusClosed)
+	if err != nil {
+		return "", err
+	}
+	if req == nil {
+		return "Nenhum pedido para cancelar.", nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.qStore.RejectOthers(req.ID, ""); err != nil {
+		return "", err
+	}
+	if err := a.memStore.DeleteByRequest(req.ID); err != nil {
+		return "", err
+	}
+	if err := a.rStore.SetStatus(req.ID, StatusCancelled); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("❌ Pedido %q cancelado.", req.Description), nil
+}
+
+// optionsText numbers the received quotes so the owner can answer "aceitar <n>".
+func (a *Agent) optionsText(quotes []suppliers.Quote) string {
+	var b strings.Builder
+	b.WriteString("Para escolher, responda 'aceitar <n>':")
+	for i, q := range quotes {
+		name := q.SupplierID
+		if sup, err := a.supStore.Get(q.SupplierID); err == nil && sup != nil {
+			name = sup.Name
+		}
+		fmt.Fprintf(&b, "\n%d. %s", i+1, name)
+		if q.Price > 0 {
+			fmt.Fprintf(&b, " — R$ %.2f", q.Price)
+		}
+	}
+	return b.String()
+}
+
+// reply answers a command where it came from, regardless of dry-run, since
+// it answers something that was sent to the agent.
+func (a *Agent) reply(o origin, msg string) {
+	if o.replyTo == "" {
+		return
+	}
+	if err := a.send(o.replyTo, msg, ""); err != nil {
+		fmt.Printf("[%s] erro ao responder: %v\n", o.name, err)
+	}
+}
+
+func (a *Agent) isOwner(phone string) bool {
+	return a.cfg.OwnerPhone != "" &&
+		suppliers.NormalizePhone(phone) == suppliers.NormalizePhone(a.cfg.OwnerPhone)
+}
+
+// foldAccents maps the Portuguese accented letters to their base letter so
+// "histórico" and "historico" are the same keyword.
+func foldAccents(s string) string {
+	return strings.NewReplacer(
+		"á", "a", "à", "a", "â", "a", "ã", "a",
+		"é", "e", "ê", "e",
+		"í", "i",
+		"ó", "o", "ô", "o", "õ", "o",
+		"ú", "u", "ü", "u",
+		"ç", "c",
+	).Replace(s)
+}
@@ -0,0 +1,119 @@
+package comprador
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/memory"
+	"github.com/user/agente/comprador/suppliers"
+)
+
+const (
+	// defaultDeliveryDelay is when an accepted purchase is taken as delivered
+	// if Config.DeliveryDelay is not set.
+	defaultDeliveryDelay = 48 * time.Hour
+	// feedbackAskGap spaces out the daemon's questions, so an answer "nota 4"
+	// is not ambiguous between two purchases asked about together.
+	feedbackAskGap = 24 * time.Hour
+)
+
+func (a *Agent) deliveryDelay() time.Duration {
+	if a.cfg.DeliveryDelay > 0 {
+		return a.cfg.DeliveryDelay
+	}
+	return defaultDeliveryDelay
+}
+
+// PendingFeedback returns the accepted purchases the owner has not rated;
+// with dueOnly, only those past their expected delivery.
+func (a *Agent) PendingFeedback(dueOnly bool) ([]memory.PurchaseRecord, error) {
+	return a.memStore.AwaitingFeedback(time.Now(), dueOnly)
+}
+
+// RatePurchase records the owner's 1-5 rating of a purchase against its
+// supplier and request, and rescores the suppliers.
+func (a *Agent) RatePurchase(p memory.PurchaseRecord, rating int, comment string) error {
+	if p.SupplierID == "" {
+		return fmt.Errorf("compra %q sem fornecedor escolhido", p.Description)
+	}
+	err := a.feedback.Add(suppliers.Feedback{
+		SupplierID: p.SupplierID,
+		RequestID:  p.RequestID,
+		Rating:     rating,
+		Comment:    comment,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.Rescore()
+	return err
+}
+
+// ratePending rates the purchase the owner was last asked about or, if none
+// was asked, the last one accepted.
+func (a *Agent) ratePending(rating int, comment string) (string, error) {
+	pending, err := a.PendingFeedback(false)
+	if err != nil {
+		return "", err
+	}
+	if len(pending) == 0 {
+		return "Nenhuma compra aguardando avaliação.", nil
+	}
+	p := pending[0]
+	if err := a.RatePurchase(p, rating, comment); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("⭐ Avaliação registrada: %q com %s — %s.", p.Description, p.ChosenSupplier, memory.Stars(rating)), nil
+}
+
+// AskFeedbackDue asks the owner over WhatsApp to rate a purchase whose
+// expected delivery has passed. One purchase is asked about at a time, at
+// most every feedbackAskGap; the answer is the "nota" command.
+func (a *Agent) AskFeedbackDue() {
+	if a.cfg.DryRun || (a.cfg.Group == "" && a.cfg.OwnerPhone == "") {
+		return
+	}
+	pending, err := a.PendingFeedback(true)
+	if err != nil {
+		fmt.Printf("[erro] listar compras sem avaliação: %v\n", err)
+		return
+	}
+	now := time.Now()
+	var next *memory.PurchaseRecord
+	for i, p := range pending {
+		if !p.FeedbackAsked.IsZero() {
+			if now.Sub(p.FeedbackAsked) < feedbackAskGap {
+				return
+			}
+			continue
+		}
+		next = &pending[i]
+	}
+	if next == nil {
+		return
+	}
+	a.notify(fmt.Sprintf(
+		"📦 A compra %q com %s já deve ter sido entregue. Como foi?\n\nResponda 'nota <1-5> [comentário]', ex: nota 4 entregou com um dia de atraso",
+		next.Description, next.ChosenSupplier,
+	))
+	if err := a.memStore.MarkFeedbackAsked(next.ID, now); err != nil {
+		fmt.Printf("[erro] registrar pedido de avaliação: %v\n", err)
+	}
+}
+
+// parseRating splits "4 entregou atrasado" (or "4/5 ...") into the rating
+// and the comment.
+func parseRating(text string) (int, string, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0, "", fmt.Errorf("informe a nota de 1 a 5, ex: nota 4 chegou no prazo")
+	}
+	rating, err := strconv.Atoi(strings.TrimSuffix(fields[0], "/5"))
+	if err != nil || rating < 1 || rating > 5 {
+		return 0, "", fmt.Errorf("nota %q inválida: use de 1 a 5", fields[0])
+	}
+	comment := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), fields[0]))
+	return rating, strings.TrimLeft(comment, "-—:, "), nil
+}
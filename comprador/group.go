@@ -0,0 +1,193 @@
+package comprador
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// Member is someone allowed to give the agent commands in the household
+// group. Any member can ask for quotes; only approvers can accept an option
+// above Config.ApprovalLimit. The owner is always an approver.
+type Member struct {
+	Phone     string
+	Name      string
+	Approver  bool
+	CreatedAt time.Time
+}
+
+// MemberStore persists the group members and their permissions.
+type MemberStore struct {
+	db *sql.DB
+}
+
+// NewMemberStore creates a MemberStore.
+func NewMemberStore(db *sql.DB) *MemberStore {
+	return &MemberStore{db: db}
+}
+
+// Save adds a member or updates its name and permission.
+func (ms *MemberStore) Save(m Member) error {
+	_, err := ms.db.Exec(
+		`INSERT INTO group_members (phone, name, approver, created_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(phone) DO UPDATE SET name = excluded.name, approver = excluded.approver`,
+		suppliers.NormalizePhone(m.Phone), m.Name, m.Approver, time.Now(),
+	)
+	return err
+}
+
+// Remove deletes a member. It reports whether it existed.
+func (ms *MemberStore) Remove(phone string) (bool, error) {
+	res, err := ms.db.Exec(`DELETE FROM group_members WHERE phone = ?`, suppliers.NormalizePhone(phone))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Get returns the member with phone, or nil.
+func (ms *MemberStore) Get(phone string) (*Member, error) {
+	var m Member
+	err := ms.db.QueryRow(
+		`SELECT phone, name, approver, created_at FROM group_members WHERE phone = ?`, suppliers.NormalizePhone(phone),
+	).Scan(&m.Phone, &m.Name, &m.Approver, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// List returns every member, by name.
+func (ms *MemberStore) List() ([]Member, error) {
+	rows, err := ms.db.Query(`SELECT phone, name, approver, created_at FROM group_members ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.Phone, &m.Name, &m.Approver, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// origin is who sent a command and where the answer goes: the owner's
+// private chat, or the group when the command was posted there.
+type origin struct {
+	name     string
+	approver bool
+	replyTo  string
+	group    bool
+}
+
+// ownerOrigin is a command from the owner in private.
+func (a *Agent) ownerOrigin() origin {
+	return origin{name: "dono", approver: true, replyTo: a.cfg.OwnerPhone}
+}
+
+// isGroup reports whether addr is the configured household group.
+func (a *Agent) isGroup(addr string) bool {
+	return a.cfg.Group != "" && whatsapp.NormalizeAddress(addr) == whatsapp.NormalizeAddress(a.cfg.Group)
+}
+
+// handleGroup runs commands posted in the household group. Family chatter
+// is left alone: only messages that are commands ("cotar ...", "status",
+// "aceitar 2"...) or photos are acted on, and only from the owner or a
+// registered member. Other groups are ignored.
+func (a *Agent) handleGroup(in whatsapp.IncomingMessage) {
+	if !a.isGroup(in.Group) {
+		return
+	}
+	a.recordIncoming(in, "", "")
+
+	o := origin{replyTo: in.Group, group: true}
+	if a.isOwner(in.From) {
+		o.name, o.approver = "dono", true
+	} else {
+		m, err := a.members.Get(in.From)
+		if err != nil {
+			fmt.Printf("[erro] membro do grupo %s: %v\n", in.From, err)
+			return
+		}
+		if m == nil {
+			return
+		}
+		o.name, o.approver = m.Name, m.Approver
+	}
+
+	var images []string
+	if in.Media != nil && in.Media.Kind == whatsapp.MediaImage {
+		images = append(images, in.Media.Path)
+	}
+	if _, ok := ParseCommand(in.Body); !ok && len(images) == 0 {
+		return
+	}
+	go a.handleCommand(context.Background(), o, in.Body, images)
+}
+
+// checkApproval refuses an option above the approval limit, or of unknown
+// price (0) when there is a limit, unless o may approve it, naming who can.
+func (a *Agent) checkApproval(o origin, price float64) error {
+	if o.approver || a.cfg.ApprovalLimit <= 0 || (price > 0 && price <= a.cfg.ApprovalLimit) {
+		return nil
+	}
+	names := []string{"dono"}
+	if members, err := a.members.List(); err == nil {
+		for _, m := range members {
+			if m.Approver {
+				names = append(names, m.Name)
+			}
+		}
+	}
+	if price <= 0 {
+		return fmt.Errorf("opção sem preço conhecido e há limite de R$ %.2f; precisa ser aprovada por: %s",
+			a.cfg.ApprovalLimit, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("R$ %.2f passa do limite de R$ %.2f; precisa ser aprovado por: %s",
+		price, a.cfg.ApprovalLimit, strings.Join(names, ", "))
+}
+
+// Members returns the group members.
+func (a *Agent) Members() ([]Member, error) {
+	return a.members.List()
+}
+
+// SaveMember adds or updates a group member.
+func (a *Agent) SaveMember(m Member) error {
+	if suppliers.NormalizePhone(m.Phone) == "" {
+		return fmt.Errorf("telefone inválido: %q", m.Phone)
+	}
+	return a.members.Save(m)
+}
+
+// RemoveMember removes a group member.
+func (a *Agent) RemoveMember(phone string) error {
+	found, err := a.members.Remove(phone)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s não é membro do grupo", phone)
+	}
+	return nil
+}
+
+// Groups lists the WhatsApp groups the agent's number was added to, to find
+// the address to configure as the household group.
+func (a *Agent) Groups() ([]whatsapp.GroupInfo, error) {
+	return whatsapp.JoinedGroups(a.sender)
+}
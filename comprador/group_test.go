@@ -0,0 +1,34 @@
+package comprador
+
+import "testing"
+
+func TestCheckApproval(t *testing.T) {
+	a := newTestAgent(t, Config{ApprovalLimit: 500})
+	member := origin{name: "Ana"}
+	approver := origin{name: "dono", approver: true}
+
+	tests := []struct {
+		name   string
+		o      origin
+		price  float64
+		wantOK bool
+	}{
+		{"under the limit", member, 200, true},
+		{"at the limit", member, 500, true},
+		{"over the limit", member, 800, false},
+		{"unknown price", member, 0, false},
+		{"approver over the limit", approver, 800, true},
+		{"approver, unknown price", approver, 0, true},
+	}
+	for _, tt := range tests {
+		err := a.checkApproval(tt.o, tt.price)
+		if (err == nil) != tt.wantOK {
+			t.Errorf("%s: checkApproval(%.0f) = %v", tt.name, tt.price, err)
+		}
+	}
+
+	a.cfg.ApprovalLimit = 0
+	if err := a.checkApproval(member, 0); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+}
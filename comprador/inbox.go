@@ -0,0 +1,314 @@
+package comprador
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/messages"
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// Conversation is an inbox entry: the messages exchanged with one address
+// and who is behind it.
+type Conversation struct {
+	messages.Conversation
+	Supplier *suppliers.Supplier // nil for the owner, groups and unknown senders
+	Owner    bool
+	Group    bool // the household group (see Config.Group)
+}
+
+// Contact names the other side of the conversation.
+func (c Conversation) Contact() string {
+	switch {
+	case c.Owner:
+		return "dono"
+	case c.Group:
+		return "grupo"
+	case c.Supplier != nil:
+		return c.Supplier.Name
+	}
+	return "desconhecido"
+}
+
+// Unknown reports whether nobody on file is behind the address.
+func (c Conversation) Unknown() bool { return c.Supplier == nil && !c.Owner && !c.Group }
+
+// recordIncoming stores a received message. Failures are only logged, so a
+// bookkeeping error never loses a reply. Group messages are filed under the
+// group, prefixed with the member's phone.
+func (a *Agent) recordIncoming(in whatsapp.IncomingMessage, supplierID, requestID string) {
+	m := messages.Message{
+		Direction:  messages.DirectionIn,
+		Phone:      in.From,
+		Body:       in.Body,
+		RequestID:  requestID,
+		SupplierID: supplierID,
+		SentAt:     in.Timestamp,
+	}
+	if in.Group != "" {
+		m.Phone, m.Body = in.Group, in.From+": "+in.Body
+	}
+	if in.Media != nil {
+		m.MediaPath = in.Media.Path
+	}
+	if err := a.msgStore.Record(m); err != nil {
+		fmt.Printf("[erro] registrar mensagem de %s: %v\n", in.From, err)
+	}
+}
+
+// send sends text to addr and records it.
+func (a *Agent) send(addr, text, supplierID string) error {
+	waID, err := a.sender.Send(addr, text)
+	if err != nil {
+		return err
+	}
+	if err := a.msgStore.Record(messages.Message{WAID: waID, Phone: addr, Body: text, SupplierID: supplierID}); err != nil {
+		fmt.Printf("[erro] registrar mensagem para %s: %v\n", addr, err)
+	}
+	return nil
+}
+
+// Inbox lists the conversations, most recent first.
+func (a *Agent) Inbox() ([]Conversation, error) {
+	convs, err := a.msgStore.Conversations()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Conversation, len(convs))
+	for i, c := range convs {
+		out[i] = Conversation{Conversation: c, Owner: a.isOwner(c.Phone), Group: whatsapp.IsGroupAddress(c.Phone)}
+		if out[i].Group {
+			continue
+		}
+		var sup *suppliers.Supplier
+		if c.SupplierID != "" {
+			sup, err = a.supStore.Get(c.SupplierID)
+		} else {
+			// The supplier may have been registered after the messages
+			sup, err = a.supStore.ByAddress(c.Phone)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[i].Supplier = sup
+	}
+	return out, nil
+}
+
+// ResolveContact turns what the user typed (supplier name or part of it,
+// phone, e-mail or "<channel>:<id>" address) into an address and, if known,
+// its supplier.
+func (a *Agent) ResolveContact(contact string) (string, *suppliers.Supplier, error) {
+	contact = strings.TrimSpace(contact)
+	if contact == "" {
+		return "", nil, fmt.Errorf("informe o contato")
+	}
+
+	if looksLikeAddress(contact) {
+		addr := contact
+		if strings.Contains(addr, "@") && !strings.Contains(addr, ":") && !whatsapp.IsGroupAddress(addr) {
+			addr = whatsapp.ChannelEmail + ":" + addr
+		}
+		addr = whatsapp.NormalizeAddress(addr)
+		sup, err := a.supStore.ByAddress(addr)
+		if err != nil {
+			return "", nil, err
+		}
+		return addr, sup, nil
+	}
+
+	sups, err := a.supStore.List()
+	if err != nil {
+		return "", nil, err
+	}
+	var matches []suppliers.Supplier
+	for _, s := range sups {
+		if strings.EqualFold(s.Name, contact) {
+			return s.Address(), &s, nil
+		}
+		if strings.Contains(strings.ToLower(s.Name), strings.ToLower(contact)) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil, fmt.Errorf("contato %q não encontrado", contact)
+	case 1:
+		return matches[0].Address(), &matches[0], nil
+	}
+	return "", nil, fmt.Errorf("%q corresponde a %d fornecedores; seja mais específico", contact, len(matches))
+}
+
+// looksLikeAddress tells a phone, e-mail or channel address from a name.
+func looksLikeAddress(s string) bool {
+	if strings.ContainsAny(s, ":@") {
+		return true
+	}
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 8
+}
+
+// Thread returns the last limit messages with a contact and marks the
+// received ones as seen.
+func (a *Agent) Thread(contact string, limit int) (string, []messages.Message, error) {
+	addr, _, err := a.ResolveContact(contact)
+	if err != nil {
+		return "", nil, err
+	}
+	msgs, err := a.msgStore.Thread(addr, limit)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := a.msgStore.MarkSeen(addr); err != nil {
+		return "", nil, err
+	}
+	return addr, msgs, nil
+}
+
+// Reply sends a manual message to a contact, on the channel it is reached by.
+func (a *Agent) Reply(contact, text string) error {
+	addr, sup, err := a.ResolveContact(contact)
+	if err != nil {
+		return err
+	}
+	supplierID := ""
+	if sup != nil {
+		supplierID = sup.ID
+	}
+	if err := a.send(addr, text, supplierID); err != nil {
+		return fmt.Errorf("enviar para %s: %w", addr, err)
+	}
+	return a.msgStore.MarkSeen(addr)
+}
+
+// AssignMessage attributes a received message to a supplier (ID or name) and
+// optionally to a request, recording it as that supplier's reply the way
+// handleIncoming would have. A supplier that was not contacted for the
+// request is added to it.
+func (a *Agent) AssignMessage(msgID int64, supplier, requestID string) error {
+	m, err := a.msgStore.Get(msgID)
+	if err != nil {
+		return err
+	}
+	if m == nil || m.Direction != messages.DirectionIn {
+		return fmt.Errorf("mensagem recebida %d não encontrada", msgID)
+	}
+
+	sup, err := a.supStore.Get(supplier)
+	if err != nil {
+		return err
+	}
+	if sup == nil {
+		if _, sup, err = a.ResolveContact(supplier); err != nil {
+			return err
+		}
+		if sup == nil {
+			return fmt.Errorf("fornecedor %q não encontrado", supplier)
+		}
+	}
+
+	if requestID != "" {
+		req, err := a.rStore.Get(requestID)
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			return fmt.Errorf("pedido %s não encontrado", requestID)
+		}
+		if err := a.ensureQuote(req, sup.ID); err != nil {
+			return err
+		}
+	}
+	if err := a.msgStore.Assign(msgID, sup.ID, requestID); err != nil {
+		return err
+	}
+
+	text := m.Body
+	var attachments []string
+	if m.MediaPath != "" {
+		attachments = append(attachments, m.MediaPath)
+		text = strings.TrimSpace((&whatsapp.Media{Path: m.MediaPath}).Describe() + " " + text)
+	}
+	if requestID != "" {
+		err = a.qStore.UpdateForRequest(requestID, sup.ID, text, attachments)
+	} else {
+		err = a.qStore.UpdateBySupplier(sup.ID, text, attachments)
+	}
+	if err != nil {
+		return fmt.Errorf("registrar resposta: %w", err)
+	}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// ensureQuote adds a pending quote for supplierID to req if it has none.
+func (a *Agent) ensureQuote(req *QuoteRequest, supplierID string) error {
+	quotes, err := a.qStore.PendingByRequest(req.ID)
+	if err != nil {
+		return err
+	}
+	for _, q := range quotes {
+		if q.SupplierID == supplierID {
+			return nil
+		}
+	}
+	items := make([]suppliers.QuoteItem, len(req.Items))
+	for i, it := range req.Items {
+		items[i] = suppliers.QuoteItem{Name: it.Name, Qty: it.Qty, Unit: it.Unit}
+	}
+	return a.qStore.CreateQuote(suppliers.Quote{
+		RequestID:  req.ID,
+		SupplierID: supplierID,
+		Items:      items,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// SupplierFromMessage registers the sender of a received message as a new
+// supplier (sup carries name, city and categories; the address comes from
+// the message) and assigns the message to it.
+func (a *Agent) SupplierFromMessage(msgID int64, sup suppliers.Supplier, requestID string) (string, error) {
+	m, err := a.msgStore.Get(msgID)
+	if err != nil {
+		return "", err
+	}
+	if m == nil || m.Direction != messages.DirectionIn {
+		return "", fmt.Errorf("mensagem recebida %d não encontrada", msgID)
+	}
+	if existing, err := a.supStore.ByAddress(m.Phone); err != nil {
+		return "", err
+	} else if existing != nil {
+		return "", fmt.Errorf("%s já é o fornecedor %s", m.Phone, existing.Name)
+	}
+
+	ch, id := whatsapp.SplitAddress(m.Phone)
+	sup.Channel = ch
+	switch ch {
+	case whatsapp.ChannelTelegram:
+		sup.TelegramID = id
+	case whatsapp.ChannelEmail:
+		sup.Email = id
+	default:
+		sup.Phone = id
+	}
+	sup.Active = true
+
+	supID, err := a.supStore.Add(sup)
+	if err != nil {
+		return "", fmt.Errorf("adicionar fornecedor: %w", err)
+	}
+	if err := a.AssignMessage(msgID, supID, requestID); err != nil {
+		return supID, err
+	}
+	return supID, nil
+}
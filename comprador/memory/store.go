@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,11 +13,17 @@ import (
 // PurchaseRecord stores a completed purchase in memory.
 type PurchaseRecord struct {
 	ID              string
+	RequestID       string // quote request this purchase came from, if any
+	SupplierID      string
 	Description     string
 	Items           []string
 	ChosenSupplier  string
 	TotalPrice      float64
 	CreatedAt       time.Time
+	DeliveryDue     time.Time // expected delivery of an accepted purchase; zero until accepted
+	FeedbackAsked   time.Time // when the owner was asked to rate it; zero if not yet
+	Rating          int       // owner's 1-5 rating of the purchase; 0 if not rated
+	Comment         string    // owner's comment with the rating
 }
 
 // Store handles purchase memory persistence.
@@ -39,19 +46,102 @@ func (s *Store) Save(rec PurchaseRecord) error {
 	}
 	items, _ := json.Marshal(rec.Items)
 	_, err := s.db.Exec(
-		`INSERT INTO purchase_memory (id, description, items, chosen_supplier, total_price, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		rec.ID, rec.Description, string(items), rec.ChosenSupplier, rec.TotalPrice, rec.CreatedAt,
+		`INSERT INTO purchase_memory (id, request_id, supplier_id, description, items, chosen_supplier, total_price, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.RequestID, rec.SupplierID, rec.Description, string(items), rec.ChosenSupplier, rec.TotalPrice, rec.CreatedAt,
 	)
 	return err
 }
 
+// UpdateChoice records the supplier the owner actually accepted for a request,
+// replacing the recommendation saved when the comparison was made.
+// A zero total keeps the previously estimated price.
+func (s *Store) UpdateChoice(requestID, supplierID, supplierName string, total float64) error {
+	res, err := s.db.Exec(
+		`UPDATE purchase_memory SET supplier_id = ?, chosen_supplier = ?,
+		 total_price = CASE WHEN ? > 0 THEN ? ELSE total_price END
+		 WHERE request_id = ?`,
+		supplierID, supplierName, total, total, requestID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("nenhuma compra registrada para o pedido %s", requestID)
+	}
+	return nil
+}
+
+// SetDeliveryDue records when an accepted purchase is expected to arrive.
+func (s *Store) SetDeliveryDue(requestID string, due time.Time) error {
+	_, err := s.db.Exec(`UPDATE purchase_memory SET delivery_due = ? WHERE request_id = ?`, due, requestID)
+	return err
+}
+
+// MarkFeedbackAsked records that the owner was asked to rate a purchase.
+func (s *Store) MarkFeedbackAsked(id string, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE purchase_memory SET feedback_asked_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// DeleteByRequest removes the purchase recorded for a request (used on cancel).
+func (s *Store) DeleteByRequest(requestID string) error {
+	_, err := s.db.Exec(`DELETE FROM purchase_memory WHERE request_id = ?`, requestID)
+	return err
+}
+
+// purchaseColumns selects a PurchaseRecord; the rating is the owner's latest
+// for the purchase's request.
+const purchaseColumns = `p.id, COALESCE(p.request_id,''), COALESCE(p.supplier_id,''), p.description, p.items,
+	 COALESCE(p.chosen_supplier,''), COALESCE(p.total_price,0), p.created_at, p.delivery_due, p.feedback_asked_at,
+	 COALESCE(f.rating,0), COALESCE(f.comment,'')
+	 FROM purchase_memory p
+	 LEFT JOIN supplier_feedback f ON f.id = (SELECT MAX(id) FROM supplier_feedback WHERE request_id = p.request_id)`
+
 // Recent returns the most recent n purchases.
 func (s *Store) Recent(n int) ([]PurchaseRecord, error) {
-	rows, err := s.db.Query(
-		`SELECT id, description, items, COALESCE(chosen_supplier,''), COALESCE(total_price,0), created_at
-		 FROM purchase_memory ORDER BY created_at DESC LIMIT ?`, n,
-	)
+	return s.query(`SELECT `+purchaseColumns+` ORDER BY p.created_at DESC LIMIT ?`, n)
+}
+
+// Since returns the purchases made at or after t, most recent first.
+func (s *Store) Since(t time.Time) ([]PurchaseRecord, error) {
+	records, err := s.query(`SELECT ` + purchaseColumns + ` ORDER BY p.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	// Dates are compared in Go: SQLite holds them as text
+	var out []PurchaseRecord
+	for _, r := range records {
+		if !r.CreatedAt.Before(t) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// AwaitingFeedback returns the accepted purchases the owner has not rated,
+// most recently asked first, then by expected delivery. With dueOnly, only
+// those whose expected delivery is past now.
+func (s *Store) AwaitingFeedback(now time.Time, dueOnly bool) ([]PurchaseRecord, error) {
+	records, err := s.query(`SELECT ` + purchaseColumns + `
+		 WHERE p.delivery_due IS NOT NULL AND COALESCE(p.supplier_id,'') <> '' AND f.id IS NULL
+		 ORDER BY p.feedback_asked_at DESC, p.delivery_due DESC`)
+	if err != nil {
+		return nil, err
+	}
+	// Dates are compared in Go: SQLite holds them as text
+	var out []PurchaseRecord
+	for _, r := range records {
+		if dueOnly && r.DeliveryDue.After(now) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *Store) query(q string, args ...any) ([]PurchaseRecord, error) {
+	rows, err := s.db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,11 +151,14 @@ func (s *Store) Recent(n int) ([]PurchaseRecord, error) {
 	for rows.Next() {
 		var r PurchaseRecord
 		var itemsJSON string
-		err := rows.Scan(&r.ID, &r.Description, &itemsJSON, &r.ChosenSupplier, &r.TotalPrice, &r.CreatedAt)
+		var due, asked sql.NullTime
+		err := rows.Scan(&r.ID, &r.RequestID, &r.SupplierID, &r.Description, &itemsJSON,
+			&r.ChosenSupplier, &r.TotalPrice, &r.CreatedAt, &due, &asked, &r.Rating, &r.Comment)
 		if err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal([]byte(itemsJSON), &r.Items)
+		r.DeliveryDue, r.FeedbackAsked = due.Time, asked.Time
 		records = append(records, r)
 	}
 	return records, rows.Err()
@@ -98,6 +191,22 @@ func (s *Store) Format(n int) (string, error) {
 		out += fmt.Sprintf("%d. %s\n", i+1, r.Description)
 		out += fmt.Sprintf("   Fornecedor: %s | Total: R$ %.2f | Data: %s\n",
 			r.ChosenSupplier, r.TotalPrice, r.CreatedAt.Format("02/01/2006"))
+		switch {
+		case r.Rating > 0:
+			out += fmt.Sprintf("   Avaliação: %s", Stars(r.Rating))
+			if r.Comment != "" {
+				out += " — " + r.Comment
+			}
+			out += "\n"
+		case !r.DeliveryDue.IsZero():
+			out += fmt.Sprintf("   Avaliação pendente (entrega prevista: %s)\n", r.DeliveryDue.Format("02/01/2006"))
+		}
 	}
 	return out, nil
 }
+
+// Stars renders a 1-5 rating as "★★★★☆".
+func Stars(rating int) string {
+	rating = max(0, min(rating, 5))
+	return strings.Repeat("★", rating) + strings.Repeat("☆", 5-rating)
+}
@@ -0,0 +1,361 @@
+package messages
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// Message statuses. Sent, delivered and read come from whatsapp receipts;
+// invalid means the number has no WhatsApp account and nothing was sent.
+// Inbound messages are received.
+const (
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusInvalid   = "invalid"
+	StatusReceived  = "received"
+)
+
+// Message directions.
+const (
+	DirectionOut = "out"
+	DirectionIn  = "in"
+)
+
+// Message is one message sent or received and what we know about its delivery.
+type Message struct {
+	ID          int64
+	WAID        string // ID assigned by WhatsApp; empty for invalid numbers
+	Direction   string // DirectionOut (default) or DirectionIn
+	Phone       string // address: phone, or "<channel>:<id>" for other channels
+	Body        string
+	MediaPath   string
+	RequestID   string
+	SupplierID  string
+	Status      string
+	SentAt      time.Time // sent, or received for inbound messages
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
+	SeenAt      *time.Time // inbound: when it was shown in the inbox
+}
+
+// Store persists outbound messages and their receipts.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a message store.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Record saves a message just sent (or skipped as invalid) or received.
+func (s *Store) Record(m Message) error {
+	if m.SentAt.IsZero() {
+		m.SentAt = time.Now()
+	}
+	if m.Direction == "" {
+		m.Direction = DirectionOut
+	}
+	if m.Status == "" {
+		m.Status = StatusSent
+		if m.Direction == DirectionIn {
+			m.Status = StatusReceived
+		}
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO messages (wa_id, direction, phone, body, media_path, request_id, supplier_id, status, sent_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullable(m.WAID), m.Direction, whatsapp.NormalizeAddress(m.Phone), m.Body, m.MediaPath, nullable(m.RequestID), nullable(m.SupplierID), m.Status, m.SentAt,
+	)
+	return err
+}
+
+// Conversation summarizes the messages exchanged with one address.
+type Conversation struct {
+	Phone      string // address, as stored
+	SupplierID string // empty when no message was attributed to a supplier
+	Messages   int
+	Unread     int // inbound messages not yet seen in the inbox
+	LastAt     time.Time
+	LastBody   string
+	LastIn     bool // the last message was received, not sent
+}
+
+// Conversations lists every address with messages, most recent first.
+func (s *Store) Conversations() ([]Conversation, error) {
+	rows, err := s.db.Query(
+		`SELECT m.phone, COALESCE(MAX(m.supplier_id), ''), COUNT(*),
+		   SUM(m.direction = 'in' AND m.seen_at IS NULL), MAX(m.id)
+		 FROM messages m WHERE m.status != 'invalid'
+		 GROUP BY m.phone ORDER BY MAX(m.id) DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var out []Conversation
+	var lastIDs []int64
+	for rows.Next() {
+		var c Conversation
+		var lastID int64
+		if err := rows.Scan(&c.Phone, &c.SupplierID, &c.Messages, &c.Unread, &lastID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, c)
+		lastIDs = append(lastIDs, lastID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, id := range lastIDs {
+		m, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			out[i].LastAt, out[i].LastBody, out[i].LastIn = m.SentAt, m.Body, m.Direction == DirectionIn
+			if out[i].LastBody == "" && m.MediaPath != "" {
+				out[i].LastBody = "[mídia]"
+			}
+		}
+	}
+	return out, nil
+}
+
+// Thread returns the last limit messages exchanged with addr, oldest first.
+func (s *Store) Thread(addr string, limit int) ([]Message, error) {
+	rows, err := s.db.Query(
+		`SELECT `+messageColumns+` FROM messages WHERE phone = ? ORDER BY id DESC LIMIT ?`,
+		whatsapp.NormalizeAddress(addr), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append([]Message{*m}, out...)
+	}
+	return out, rows.Err()
+}
+
+// Get returns a message by its row ID, or nil.
+func (s *Store) Get(id int64) (*Message, error) {
+	m, err := scanMessage(s.db.QueryRow(`SELECT `+messageColumns+` FROM messages WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// MarkSeen marks every inbound message from addr as seen.
+func (s *Store) MarkSeen(addr string) error {
+	_, err := s.db.Exec(
+		`UPDATE messages SET seen_at = ? WHERE phone = ? AND direction = 'in' AND seen_at IS NULL`,
+		time.Now(), whatsapp.NormalizeAddress(addr),
+	)
+	return err
+}
+
+// Assign attributes a message to a supplier and, if requestID is set, to a
+// request. The rest of the conversation with that address that had no
+// supplier is attributed to it too.
+func (s *Store) Assign(id int64, supplierID, requestID string) error {
+	m, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("mensagem %d não encontrada", id)
+	}
+	if _, err := s.db.Exec(
+		`UPDATE messages SET supplier_id = ?, request_id = COALESCE(?, request_id) WHERE id = ?`,
+		supplierID, nullable(requestID), id,
+	); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE messages SET supplier_id = ? WHERE phone = ? AND supplier_id IS NULL`, supplierID, m.Phone)
+	return err
+}
+
+const messageColumns = `id, COALESCE(wa_id, ''), direction, phone, body, media_path, COALESCE(request_id, ''),
+  COALESCE(supplier_id, ''), status, sent_at, delivered_at, read_at, seen_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row rowScanner) (*Message, error) {
+	var m Message
+	var delivered, read, seen sql.NullTime
+	if err := row.Scan(&m.ID, &m.WAID, &m.Direction, &m.Phone, &m.Body, &m.MediaPath, &m.RequestID,
+		&m.SupplierID, &m.Status, &m.SentAt, &delivered, &read, &seen); err != nil {
+		return nil, err
+	}
+	if delivered.Valid {
+		m.DeliveredAt = &delivered.Time
+	}
+	if read.Valid {
+		m.ReadAt = &read.Time
+	}
+	if seen.Valid {
+		m.SeenAt = &seen.Time
+	}
+	return &m, nil
+}
+
+// MarkReceipt records a delivered or read receipt. Status only moves forward,
+// so a late "delivered" never overwrites "read".
+func (s *Store) MarkReceipt(waIDs []string, status string, at time.Time) error {
+	for _, id := range waIDs {
+		var err error
+		switch status {
+		case StatusDelivered:
+			_, err = s.db.Exec(
+				`UPDATE messages SET delivered_at = COALESCE(delivered_at, ?),
+				 status = CASE WHEN status = 'sent' THEN 'delivered' ELSE status END
+				 WHERE wa_id = ?`, at, id,
+			)
+		case StatusRead:
+			_, err = s.db.Exec(
+				`UPDATE messages SET delivered_at = COALESCE(delivered_at, ?), read_at = COALESCE(read_at, ?),
+				 status = 'read' WHERE wa_id = ?`, at, at, id,
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequestByRef returns the request of the first sent message whose ID is in
+// refs, or "" — used to attach an e-mail reply to the request it answers.
+func (s *Store) RequestByRef(refs []string) (string, error) {
+	for _, ref := range refs {
+		var requestID sql.NullString
+		err := s.db.QueryRow(
+			`SELECT request_id FROM messages WHERE direction = 'out' AND wa_id = ? AND request_id IS NOT NULL LIMIT 1`, ref,
+		).Scan(&requestID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return requestID.String, nil
+	}
+	return "", nil
+}
+
+// Contacted reports whether any message was ever sent to phone.
+func (s *Store) Contacted(phone string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM messages WHERE direction = 'out' AND phone = ? AND status != 'invalid'`,
+		whatsapp.NormalizeAddress(phone),
+	).Scan(&n)
+	return n > 0, err
+}
+
+// StatusByRequest returns, per supplier, the furthest status reached by the
+// messages sent for a request.
+func (s *Store) StatusByRequest(requestID string) (map[string]string, error) {
+	rows, err := s.db.Query(
+		`SELECT supplier_id, MAX(CASE status WHEN 'read' THEN 3 WHEN 'delivered' THEN 2 WHEN 'sent' THEN 1 ELSE 0 END)
+		 FROM messages WHERE direction = 'out' AND request_id = ? AND supplier_id IS NOT NULL
+		 GROUP BY supplier_id`, requestID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{StatusInvalid, StatusSent, StatusDelivered, StatusRead}
+	out := make(map[string]string)
+	for rows.Next() {
+		var supID string
+		var rank int
+		if err := rows.Scan(&supID, &rank); err != nil {
+			return nil, err
+		}
+		out[supID] = names[rank]
+	}
+	return out, rows.Err()
+}
+
+// Stats summarizes delivery of the messages sent to one supplier.
+type Stats struct {
+	Sent      int
+	Delivered int
+	Read      int
+	Invalid   int
+	LastSent  *time.Time
+}
+
+// SupplierStats returns delivery counts for every supplier ever messaged,
+// keyed by supplier ID.
+func (s *Store) SupplierStats() (map[string]Stats, error) {
+	rows, err := s.db.Query(
+		`SELECT supplier_id,
+		   SUM(status != 'invalid'),
+		   SUM(delivered_at IS NOT NULL),
+		   SUM(read_at IS NOT NULL),
+		   SUM(status = 'invalid'),
+		   MAX(sent_at)
+		 FROM messages WHERE direction = 'out' AND supplier_id IS NOT NULL
+		 GROUP BY supplier_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]Stats)
+	for rows.Next() {
+		var supID string
+		var st Stats
+		var last sql.NullString
+		if err := rows.Scan(&supID, &st.Sent, &st.Delivered, &st.Read, &st.Invalid, &last); err != nil {
+			return nil, err
+		}
+		if t, ok := parseTime(last.String); ok {
+			st.LastSent = &t
+		}
+		out[supID] = st
+	}
+	return out, rows.Err()
+}
+
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// parseTime reads a DATETIME returned by an aggregate, which SQLite hands
+// back as text (time.Time.String, possibly with a monotonic clock suffix)
+// rather than as the time.Time stored.
+func parseTime(s string) (time.Time, bool) {
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999 -0700 MST", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,135 @@
+package comprador
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// optOutPhrases opt out wherever they appear in a message.
+var optOutPhrases = []string{
+	"pare de mandar", "pare de me mandar", "para de mandar", "para de me mandar",
+	"parar de mandar", "pare de enviar", "para de enviar", "nao me mande", "nao mande mais",
+	"nao quero receber", "nao quero mais receber", "nao envie mais", "nao me envie",
+	"sair da lista", "me tire da lista", "me tira da lista", "remover meu numero",
+	"remova meu numero", "remove meu numero", "descadastrar", "descadastre",
+}
+
+// optOutWords opt out only as the whole message, since on their own they are
+// common words in a quote reply.
+var optOutWords = map[string]bool{
+	"pare": true, "parar": true, "para": true, "sair": true, "stop": true, "chega": true,
+}
+
+// IsOptOut reports whether text asks us to stop sending messages.
+func IsOptOut(text string) bool {
+	t := strings.Join(strings.Fields(foldAccents(strings.ToLower(text))), " ")
+	if optOutWords[strings.Trim(t, ".!")] {
+		return true
+	}
+	for _, p := range optOutPhrases {
+		if strings.Contains(t, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// optOut honours an opt-out from addr: the address goes on the do-not-contact
+// list, the supplier behind it (if any) is deactivated, queued messages stop
+// and one confirmation is sent. It is never counted as a quote reply.
+func (a *Agent) optOut(addr string, sup *suppliers.Supplier) {
+	// Only the first request is confirmed, so a repeated "pare" gets no answer
+	if blocked, err := a.dnc.Contains(addr); err == nil && blocked {
+		return
+	}
+	name := addr
+	if sup != nil {
+		name = sup.Name
+		if err := a.supStore.SetActive(sup.ID, false); err != nil {
+			fmt.Printf("[erro] desativar %s: %v\n", sup.Name, err)
+		}
+	}
+	if err := a.dnc.Add(addr, "pediu para sair"); err != nil {
+		fmt.Printf("[erro] incluir %s na lista de não contactar: %v\n", addr, err)
+	}
+	fmt.Printf("\n[descadastro] %s pediu para não receber mais mensagens; removido dos envios.\n\n", name)
+
+	if err := a.send(addr, "Tudo bem, não enviaremos mais mensagens para este número. Obrigado!", supplierID(sup)); err != nil {
+		fmt.Printf("[erro] confirmar descadastro para %s: %v\n", addr, err)
+	}
+	if sup != nil {
+		a.notify(fmt.Sprintf("🚫 %s pediu para não receber mais cotações e foi desativado.", name))
+	}
+}
+
+func supplierID(sup *suppliers.Supplier) string {
+	if sup == nil {
+		return ""
+	}
+	return sup.ID
+}
+
+// DoNotContact returns the do-not-contact list.
+func (a *Agent) DoNotContact() ([]suppliers.DNCEntry, error) {
+	return a.dnc.List()
+}
+
+// BlockContact puts a contact (supplier name, phone or address) on the
+// do-not-contact list and deactivates the supplier behind it. It returns the
+// blocked address.
+func (a *Agent) BlockContact(contact, reason string) (string, error) {
+	addr, sup, err := a.ResolveContact(contact)
+	if err != nil {
+		return "", err
+	}
+	if err := a.dnc.Add(addr, reason); err != nil {
+		return "", err
+	}
+	if sup != nil {
+		if err := a.supStore.SetActive(sup.ID, false); err != nil {
+			return "", err
+		}
+	}
+	return addr, nil
+}
+
+// UnblockContact takes a contact off the do-not-contact list and reactivates
+// the supplier behind it, which opting out had deactivated.
+func (a *Agent) UnblockContact(contact string) (string, error) {
+	all, err := a.supStore.ListAll()
+	if err != nil {
+		return "", err
+	}
+	addr, _, err := a.ResolveContact(contact)
+	if err != nil {
+		// Inactive suppliers are not found by name; look among them too
+		for _, s := range all {
+			if !s.Active && strings.EqualFold(s.Name, strings.TrimSpace(contact)) {
+				addr, err = whatsapp.NormalizeAddress(s.Address()), nil
+				break
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	found, err := a.dnc.Remove(addr)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("%s não está na lista de não contactar", addr)
+	}
+	for _, s := range all {
+		if !s.Active && whatsapp.NormalizeAddress(s.Address()) == addr {
+			if err := a.supStore.SetActive(s.ID, true); err != nil {
+				return "", err
+			}
+		}
+	}
+	return addr, nil
+}
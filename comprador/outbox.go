@@ -0,0 +1,177 @@
+package comprador
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/agente/comprador/messages"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// Outbox statuses.
+const (
+	OutboxPending   = "pending"
+	OutboxSent      = "sent"
+	OutboxCancelled = "cancelled" // request closed, or supplier opted out, before the shop opened
+	OutboxFailed    = "failed"
+)
+
+// OutboxMessage is a message held back until NotBefore, typically because
+// the supplier's shop was closed when the quote was dispatched.
+type OutboxMessage struct {
+	ID         int64
+	RequestID  string
+	SupplierID string
+	Address    string
+	Body       string
+	MediaPath  string // set for an image; Body is then its caption
+	NotBefore  time.Time
+	Status     string
+	CreatedAt  time.Time
+}
+
+// OutboxStore persists deferred messages so they survive restarts and any
+// process (CLI or daemon) can send them once due.
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// NewOutboxStore creates an OutboxStore.
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// Enqueue holds a message until m.NotBefore.
+func (ob *OutboxStore) Enqueue(m OutboxMessage) error {
+	_, err := ob.db.Exec(
+		`INSERT INTO outbox (request_id, supplier_id, address, body, media_path, not_before, status, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.RequestID, m.SupplierID, m.Address, m.Body, m.MediaPath, m.NotBefore, OutboxPending, time.Now(),
+	)
+	return err
+}
+
+// Due returns the pending messages whose time has come, oldest first.
+func (ob *OutboxStore) Due(now time.Time) ([]OutboxMessage, error) {
+	pending, err := ob.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var due []OutboxMessage
+	for _, m := range pending {
+		if !m.NotBefore.After(now) {
+			due = append(due, m)
+		}
+	}
+	return due, nil
+}
+
+// Pending returns every message not yet sent, oldest first.
+func (ob *OutboxStore) Pending() ([]OutboxMessage, error) {
+	rows, err := ob.db.Query(
+		`SELECT id, COALESCE(request_id, ''), COALESCE(supplier_id, ''), address, body, media_path, not_before, status, created_at
+		 FROM outbox WHERE status = ? ORDER BY id`, OutboxPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.RequestID, &m.SupplierID, &m.Address, &m.Body, &m.MediaPath, &m.NotBefore, &m.Status, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// MarkSent records that a message went out.
+func (ob *OutboxStore) MarkSent(id int64) error {
+	_, err := ob.db.Exec(`UPDATE outbox SET status = ?, sent_at = ? WHERE id = ?`, OutboxSent, time.Now(), id)
+	return err
+}
+
+// MarkFailed records why a message could not be sent; it is not retried.
+func (ob *OutboxStore) MarkFailed(id int64, cause error) error {
+	_, err := ob.db.Exec(`UPDATE outbox SET status = ?, error = ? WHERE id = ?`, OutboxFailed, cause.Error(), id)
+	return err
+}
+
+// Cancel drops a pending message.
+func (ob *OutboxStore) Cancel(id int64) error {
+	_, err := ob.db.Exec(`UPDATE outbox SET status = ? WHERE id = ? AND status = ?`, OutboxCancelled, id, OutboxPending)
+	return err
+}
+
+// FlushOutbox sends the deferred messages that are due. Messages for requests
+// no longer open, or to addresses that opted out meanwhile, are cancelled; a
+// message stopped by the send limit stays queued for the next flush.
+func (a *Agent) FlushOutbox(ctx context.Context) {
+	due, err := a.outbox.Due(time.Now())
+	if err != nil {
+		fmt.Printf("[erro] ler fila de envio: %v\n", err)
+		return
+	}
+	for _, m := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if skip, why := a.outboxStale(m); skip {
+			fmt.Printf("[fila] mensagem para %s cancelada: %s\n", m.Address, why)
+			if err := a.outbox.Cancel(m.ID); err != nil {
+				fmt.Printf("[erro] cancelar mensagem %d da fila: %v\n", m.ID, err)
+			}
+			continue
+		}
+
+		var waID string
+		if m.MediaPath != "" {
+			waID, err = a.sender.SendImage(m.Address, m.MediaPath, m.Body)
+		} else {
+			waID, err = a.sender.Send(m.Address, m.Body)
+		}
+		if errors.Is(err, whatsapp.ErrRateLimited) {
+			fmt.Printf("[fila] %s: %v; nova tentativa depois\n", m.Address, err)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("[erro] enviar mensagem da fila para %s: %v\n", m.Address, err)
+			if err := a.outbox.MarkFailed(m.ID, err); err != nil {
+				fmt.Printf("[erro] registrar falha da mensagem %d: %v\n", m.ID, err)
+			}
+			continue
+		}
+		fmt.Printf("[fila] mensagem enviada para %s\n", m.Address)
+		a.qManager.record(messages.Message{
+			WAID: waID, Phone: m.Address, Body: m.Body, MediaPath: m.MediaPath,
+			RequestID: m.RequestID, SupplierID: m.SupplierID,
+		})
+		if err := a.outbox.MarkSent(m.ID); err != nil {
+			fmt.Printf("[erro] marcar mensagem %d como enviada: %v\n", m.ID, err)
+		}
+	}
+}
+
+// outboxStale reports whether a queued message should no longer be sent.
+func (a *Agent) outboxStale(m OutboxMessage) (bool, string) {
+	if blocked, err := a.dnc.Contains(m.Address); err == nil && blocked {
+		return true, "contato na lista de não contactar"
+	}
+	if m.RequestID == "" {
+		return false, ""
+	}
+	req, err := a.rStore.Get(m.RequestID)
+	if err != nil || req == nil {
+		return false, ""
+	}
+	if req.Status != StatusOpen {
+		return true, "pedido já encerrado"
+	}
+	return false, ""
+}
@@ -0,0 +1,212 @@
+package comprador
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+// Place is an address of the owner's that purchases are delivered to, such
+// as home or a second house. Quotes go to the default place unless they
+// name another.
+type Place struct {
+	Name    string
+	Address string
+	Geo     suppliers.Point
+	Default bool
+}
+
+// PlaceStore persists the owner's places.
+type PlaceStore struct {
+	db *sql.DB
+}
+
+// NewPlaceStore creates a PlaceStore.
+func NewPlaceStore(db *sql.DB) *PlaceStore {
+	return &PlaceStore{db: db}
+}
+
+// Save adds a place or updates the one with the same name (compared
+// case-insensitively). The first place saved becomes the default, as does
+// one saved with Default set.
+func (ps *PlaceStore) Save(p Place) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("informe o nome do local")
+	}
+	if p.Geo.IsZero() {
+		return fmt.Errorf("local %q sem coordenadas", p.Name)
+	}
+	if err := p.Geo.Check(); err != nil {
+		return err
+	}
+	var others int
+	if err := ps.db.QueryRow(`SELECT COUNT(*) FROM places WHERE name <> ?`, p.Name).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		p.Default = true
+	}
+
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if p.Default {
+		if _, err := tx.Exec(`UPDATE places SET is_default = 0`); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(
+		`INSERT INTO places (name, address, lat, lng, is_default, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET address = excluded.address, lat = excluded.lat, lng = excluded.lng,
+		   is_default = excluded.is_default OR places.is_default`,
+		p.Name, p.Address, p.Geo.Lat, p.Geo.Lng, p.Default, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("save place: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetDefault makes the named place the default. It reports whether it
+// exists.
+func (ps *PlaceStore) SetDefault(name string) (bool, error) {
+	p, err := ps.Get(name)
+	if err != nil || p == nil {
+		return false, err
+	}
+	p.Default = true
+	return true, ps.Save(*p)
+}
+
+// Remove deletes a place. It reports whether it existed. When the default
+// is removed, the oldest place left takes its place.
+func (ps *PlaceStore) Remove(name string) (bool, error) {
+	res, err := ps.db.Exec(`DELETE FROM places WHERE name = ?`, strings.TrimSpace(name))
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	_, err = ps.db.Exec(
+		`UPDATE places SET is_default = 1 WHERE NOT EXISTS (SELECT 1 FROM places WHERE is_default = 1)
+		 AND name = (SELECT name FROM places ORDER BY created_at LIMIT 1)`,
+	)
+	return true, err
+}
+
+// Get returns the place with name, or nil.
+func (ps *PlaceStore) Get(name string) (*Place, error) {
+	places, err := ps.query(`WHERE name = ?`, strings.TrimSpace(name))
+	if err != nil || len(places) == 0 {
+		return nil, err
+	}
+	return &places[0], nil
+}
+
+// Default returns the default place, or nil when there is none.
+func (ps *PlaceStore) Default() (*Place, error) {
+	places, err := ps.query(`WHERE is_default = 1`)
+	if err != nil || len(places) == 0 {
+		return nil, err
+	}
+	return &places[0], nil
+}
+
+// List returns every place, the default first.
+func (ps *PlaceStore) List() ([]Place, error) {
+	return ps.query(`ORDER BY is_default DESC, name`)
+}
+
+func (ps *PlaceStore) query(where string, args ...any) ([]Place, error) {
+	rows, err := ps.db.Query(`SELECT name, address, lat, lng, is_default FROM places `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Place
+	for rows.Next() {
+		var p Place
+		if err := rows.Scan(&p.Name, &p.Address, &p.Geo.Lat, &p.Geo.Lng, &p.Default); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Places returns the owner's places, the default first.
+func (a *Agent) Places() ([]Place, error) {
+	return a.places.List()
+}
+
+// SavePlace adds or updates one of the owner's places.
+func (a *Agent) SavePlace(p Place) error {
+	return a.places.Save(p)
+}
+
+// RemovePlace deletes one of the owner's places.
+func (a *Agent) RemovePlace(name string) error {
+	ok, err := a.places.Remove(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("local %q não encontrado; veja 'comprador locations'", name)
+	}
+	return nil
+}
+
+// SetDefaultPlace makes a place the one quotes are delivered to when they
+// name none.
+func (a *Agent) SetDefaultPlace(name string) error {
+	ok, err := a.places.SetDefault(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("local %q não encontrado; veja 'comprador locations'", name)
+	}
+	return nil
+}
+
+// SetPlace sets where req is delivered: the place with name or, when name
+// is empty, the default place, if any. A maximum radius needs a place.
+func (a *Agent) SetPlace(req *QuoteRequest, name string, maxRadius float64) error {
+	var p *Place
+	var err error
+	if name != "" {
+		if p, err = a.places.Get(name); err != nil {
+			return err
+		}
+		if p == nil {
+			return fmt.Errorf("local %q não encontrado; cadastre com 'comprador locations add'", name)
+		}
+	} else if p, err = a.places.Default(); err != nil {
+		return err
+	}
+	if maxRadius < 0 {
+		return fmt.Errorf("raio máximo negativo")
+	}
+	if p == nil {
+		if maxRadius > 0 {
+			return fmt.Errorf("o raio máximo é medido a partir de um local; cadastre a casa com 'comprador locations add'")
+		}
+		return nil
+	}
+	req.Place, req.Near, req.MaxRadius = p.Name, p.Geo, maxRadius
+	return nil
+}
+
+// GeocodeSuppliers fills in the coordinates of suppliers from their street
+// address, looked up in g.
+func (a *Agent) GeocodeSuppliers(g *suppliers.Gazetteer, overwrite bool) ([]suppliers.GeocodeResult, error) {
+	return a.supStore.Geocode(g, overwrite)
+}
@@ -0,0 +1,204 @@
+package comprador
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+const (
+	// defaultPriceMaxAge is how old a known price may be to stand in for a
+	// new quote if Config.PriceMaxAge is not set.
+	defaultPriceMaxAge = 7 * 24 * time.Hour
+	// estimateWindow is how far back the estimate looks for known prices.
+	estimateWindow = 90 * 24 * time.Hour
+)
+
+func (a *Agent) priceMaxAge() time.Duration {
+	if a.cfg.PriceMaxAge > 0 {
+		return a.cfg.PriceMaxAge
+	}
+	return defaultPriceMaxAge
+}
+
+// recordPrices adds the unit prices read from the replies to req to the
+// price catalog. Items are stored under their name in the request when the
+// model's name matches one.
+func (a *Agent) recordPrices(req *QuoteRequest, received []suppliers.Quote, unitPrices []UnitPrice) error {
+	quotes := make(map[string]suppliers.Quote, len(received))
+	for _, q := range received {
+		quotes[q.ID] = q
+	}
+	items := make(map[string]ParsedItem, len(req.Items))
+	for _, it := range req.Items {
+		items[itemKey(it.Name)] = it
+	}
+
+	var prices []suppliers.Price
+	for _, up := range unitPrices {
+		q, ok := quotes[up.QuoteID]
+		if !ok {
+			continue
+		}
+		p := suppliers.Price{
+			SupplierID: q.SupplierID,
+			RequestID:  req.ID,
+			QuoteID:    q.ID,
+			Item:       up.Item,
+			Unit:       up.Unit,
+			Price:      up.Price,
+			QuotedAt:   time.Now(),
+		}
+		if it, ok := items[itemKey(up.Item)]; ok {
+			p.Item = it.Name
+			if p.Unit == "" {
+				p.Unit = it.Unit
+			}
+		}
+		if q.RespondedAt != nil {
+			p.QuotedAt = *q.RespondedAt
+		}
+		prices = append(prices, p)
+	}
+	return a.prices.Record(prices)
+}
+
+// itemKey compares item names ignoring case and surrounding spaces.
+func itemKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Prices returns the prices quoted since the given time for items matching
+// query, newest first.
+func (a *Agent) Prices(query string, since time.Time) ([]suppliers.Price, error) {
+	return a.prices.Search(query, since)
+}
+
+// Estimate is what a request would cost by the prices suppliers quoted
+// recently.
+type Estimate struct {
+	Known  map[string][]suppliers.Price // request item → newest price of each supplier, newest first
+	Fresh  []SupplierEstimate           // suppliers with a fresh price for every item, cheapest first
+	MaxAge time.Duration                // how old a fresh price may be
+}
+
+// SupplierEstimate is a supplier's total for a request by its known prices.
+type SupplierEstimate struct {
+	SupplierID string
+	Supplier   string
+	Total      float64   // unit prices times the quantities asked
+	Oldest     time.Time // when the oldest of the prices was quoted
+}
+
+// Estimate looks up the prices known for the items of req: those quoted in
+// the last estimateWindow, and the suppliers whose prices for all the items
+// are at most PriceMaxAge old.
+func (a *Agent) Estimate(req *QuoteRequest) (*Estimate, error) {
+	now := time.Now()
+	e := &Estimate{Known: make(map[string][]suppliers.Price), MaxAge: a.priceMaxAge()}
+	fresh := make(map[string]*SupplierEstimate)
+	for i, it := range req.Items {
+		known, err := a.prices.Latest(it.Name, now.Add(-estimateWindow))
+		if err != nil {
+			return nil, err
+		}
+		e.Known[it.Name] = known
+		qty := it.Qty
+		if qty <= 0 {
+			qty = 1
+		}
+		// A supplier stays fresh only if it priced every item so far
+		next := make(map[string]*SupplierEstimate)
+		for _, p := range known {
+			if now.Sub(p.QuotedAt) > e.MaxAge {
+				continue
+			}
+			prev, ok := fresh[p.SupplierID]
+			if i > 0 && !ok {
+				continue
+			}
+			if !ok {
+				prev = &SupplierEstimate{SupplierID: p.SupplierID, Supplier: p.Supplier, Oldest: p.QuotedAt}
+			}
+			prev.Total += p.Price * qty
+			if p.QuotedAt.Before(prev.Oldest) {
+				prev.Oldest = p.QuotedAt
+			}
+			next[p.SupplierID] = prev
+		}
+		fresh = next
+	}
+	for _, se := range fresh {
+		e.Fresh = append(e.Fresh, *se)
+	}
+	sort.Slice(e.Fresh, func(i, j int) bool { return e.Fresh[i].Total < e.Fresh[j].Total })
+	return e, nil
+}
+
+// estimateFirst shows the known prices for req and lets the owner skip the
+// suppliers whose prices are fresh; with AutoConfirm, or without a
+// terminal, they are skipped.
+func (a *Agent) estimateFirst(req *QuoteRequest) error {
+	e, err := a.Estimate(req)
+	if err != nil {
+		return err
+	}
+	shown := false
+	for _, it := range req.Items {
+		known := e.Known[it.Name]
+		if len(known) == 0 {
+			continue
+		}
+		if !shown {
+			fmt.Printf("Preços conhecidos (últimos %d dias):\n", int(estimateWindow.Hours()/24))
+			shown = true
+		}
+		fmt.Printf("  • %s:\n", it.Name)
+		for _, p := range known {
+			fmt.Printf("      R$ %.2f/%s — %s (%s)\n", p.Price, unitOr(p.Unit, it.Unit), p.Supplier, p.QuotedAt.Format("02/01"))
+		}
+	}
+	if !shown {
+		fmt.Println("Nenhum preço conhecido para esses itens.")
+		fmt.Println()
+		return nil
+	}
+	fmt.Println()
+	days := int(e.MaxAge.Hours() / 24)
+	if len(e.Fresh) == 0 {
+		fmt.Printf("Nenhum fornecedor cotou todos os itens nos últimos %d dias; todos serão consultados.\n\n", days)
+		return nil
+	}
+	fmt.Printf("Com preço de todos os itens nos últimos %d dias:\n", days)
+	for _, se := range e.Fresh {
+		fmt.Printf("  • %s: R$ %.2f (cotações desde %s)\n", se.Supplier, se.Total, se.Oldest.Format("02/01"))
+	}
+
+	skip := true
+	if !a.cfg.AutoConfirm && isInteractive() {
+		fmt.Print("Não pedir cotação de novo a esses fornecedores? [S/n] ")
+		answer, _ := a.input.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		skip = answer == "" || answer == "s" || answer == "sim"
+	}
+	fmt.Println()
+	if skip {
+		for _, se := range e.Fresh {
+			req.Skip = append(req.Skip, se.SupplierID)
+		}
+	}
+	return nil
+}
+
+func unitOr(unit, fallback string) string {
+	if unit != "" {
+		return unit
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "un"
+}
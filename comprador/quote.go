@@ -3,11 +3,13 @@ package comprador
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/user/agente/comprador/messages"
 	"github.com/user/agente/comprador/suppliers"
 	"github.com/user/agente/internal/claude"
 	"github.com/user/agente/internal/whatsapp"
@@ -18,8 +20,33 @@ type QuoteRequest struct {
 	ID          string
 	Description string
 	Items       []ParsedItem
+	Images      []string // photos of the item, sent with the quote message
 	Urgent      bool
+	BypassHours bool // urgent only: message suppliers even outside their hours
 	Timeout     time.Duration
+	Status      string // open/closed; set once dispatched
+	Deadline    time.Time
+	CreatedAt   time.Time
+
+	Place     string          // owner place delivered to (see Agent.SetPlace); empty when none
+	Near      suppliers.Point // coordinates of Place
+	MaxRadius float64         // km from Near suppliers may be; 0 means any distance
+
+	Skip []string // IDs of suppliers not to message, as their recent prices stand (see Agent.Estimate)
+
+	MaxSuppliers int    // suppliers to message at most; 0 means Config.MaxSuppliers
+	Strategy     string // how to choose them when more match; empty means Config.Strategy
+
+	CompareFailures int       // comparisons of the replies that failed (see Agent.Finish)
+	RetryAt         time.Time // when the comparison may be tried again; zero if it never failed
+}
+
+func (r *QuoteRequest) itemNames() []string {
+	names := make([]string, len(r.Items))
+	for i, it := range r.Items {
+		names[i] = it.Name
+	}
+	return names
 }
 
 // ParsedItem is an item extracted from the user's description.
@@ -35,7 +62,17 @@ type QuoteComparison struct {
 	Recommendation string
 	BestSupplier   string
 	TotalPrice     float64
-	Table          string // text table for display
+	Table          string             // text table for display
+	Prices         map[string]float64 // quote ID → total quoted, for the quotes that gave a price
+	UnitPrices     []UnitPrice        // per item, for the supplier price catalog
+}
+
+// UnitPrice is what a quote asks for one unit of a requested item.
+type UnitPrice struct {
+	QuoteID string
+	Item    string
+	Unit    string
+	Price   float64
 }
 
 // QuoteManager orchestrates the quoting flow.
@@ -44,6 +81,9 @@ type QuoteManager struct {
 	sender     whatsapp.MessageSender
 	supStore   *suppliers.Store
 	quoteStore *suppliers.QuoteStore
+	msgStore   *messages.Store
+	dnc        *suppliers.DNCStore
+	outbox     *OutboxStore // nil in dry-run: deferred messages are only reported
 }
 
 // NewQuoteManager creates a QuoteManager.
@@ -52,17 +92,25 @@ func NewQuoteManager(
 	sender whatsapp.MessageSender,
 	supStore *suppliers.Store,
 	quoteStore *suppliers.QuoteStore,
+	msgStore *messages.Store,
+	dnc *suppliers.DNCStore,
+	outbox *OutboxStore,
 ) *QuoteManager {
 	return &QuoteManager{
 		claude:     cl,
 		sender:     sender,
 		supStore:   supStore,
 		quoteStore: quoteStore,
+		msgStore:   msgStore,
+		dnc:        dnc,
+		outbox:     outbox,
 	}
 }
 
 // ParseRequest uses Claude to extract structured items from free-form text.
-func (qm *QuoteManager) ParseRequest(ctx context.Context, description string) (*QuoteRequest, error) {
+// When images are given they go to the vision model, so the item list can be
+// derived from a photo (e.g. of a broken part) as well as from the text.
+func (qm *QuoteManager) ParseRequest(ctx context.Context, description string, images []string) (*QuoteRequest, error) {
 	tools := []claude.ToolDef{
 		{
 			Name:        "parse_purchase_request",
@@ -89,10 +137,16 @@ func (qm *QuoteManager) ParseRequest(ctx context.Context, description string) (*
 		},
 	}
 
+	user := fmt.Sprintf("Analise esta solicitação de compra e extraia os itens: %q", description)
+	if len(images) > 0 {
+		user += "\nAs fotos anexas mostram o(s) item(ns): identifique tipo, marca, modelo e especificações visíveis."
+	}
+
 	var items []ParsedItem
 	_, err := qm.claude.ChatWithTools(ctx, claude.ChatRequest{
 		System: "Você é um assistente de compras. Interprete pedidos de compra e extraia itens com quantidades precisas.",
-		User:   fmt.Sprintf("Analise esta solicitação de compra e extraia os itens: %q", description),
+		User:   user,
+		Images: images,
 		Tools:  tools,
 	}, func(name string, input json.RawMessage) (string, error) {
 		var result struct {
@@ -112,39 +166,185 @@ func (qm *QuoteManager) ParseRequest(ctx context.Context, description string) (*
 		ID:          uuid.New().String(),
 		Description: description,
 		Items:       items,
+		Images:      images,
 	}, nil
 }
 
 // SendQuotes sends a quote request message to each supplier.
-func (qm *QuoteManager) SendQuotes(ctx context.Context, req *QuoteRequest, sups []suppliers.Supplier) error {
+// Suppliers that cannot be messaged because a send limit was reached, or
+// whose number turns out not to be on WhatsApp, are skipped and reported;
+// any other send error aborts. Every message is recorded so that delivery
+// and read receipts can be matched to it.
+//
+// Addresses on the do-not-contact list are skipped. Suppliers outside their
+// business hours get their messages queued in the outbox until they open,
+// unless the request is urgent and BypassHours is set; their quote is still
+// created, so a late reply counts. It returns the latest time a queued
+// message will go out, or the zero time if none was deferred.
+func (qm *QuoteManager) SendQuotes(ctx context.Context, req *QuoteRequest, sups []suppliers.Supplier) (time.Time, error) {
+	var deferred time.Time
+	now := time.Now()
 	for _, sup := range sups {
-		msg, err := qm.composeMessage(ctx, req, sup)
+		blocked, err := qm.dnc.Contains(sup.Address())
 		if err != nil {
-			return fmt.Errorf("compose message for %s: %w", sup.Name, err)
+			return deferred, err
 		}
-
-		if err := qm.sender.Send(sup.Phone, msg); err != nil {
-			return fmt.Errorf("send to %s: %w", sup.Name, err)
+		if blocked {
+			fmt.Printf("  [não contactar] %s pediu para não receber mensagens\n", sup.Name)
+			continue
 		}
 
-		// Record the pending quote
-		itemsRaw := make([]suppliers.QuoteItem, len(req.Items))
-		for i, it := range req.Items {
-			itemsRaw[i] = suppliers.QuoteItem{Name: it.Name, Qty: it.Qty, Unit: it.Unit}
+		opens := sup.NextOpen(now)
+		if opens.After(now) && !(req.Urgent && req.BypassHours) {
+			queued, err := qm.deferQuote(ctx, req, sup, opens)
+			if err != nil {
+				return deferred, err
+			}
+			if !queued {
+				continue
+			}
+			if opens.After(deferred) {
+				deferred = opens
+			}
+		} else {
+			sent, err := qm.sendQuote(ctx, req, sup)
+			if !sent {
+				if err != nil {
+					return deferred, err
+				}
+				continue
+			}
+			// A reply to a text already sent must find its quote, even when
+			// a photo after it failed
+			if serr := qm.saveQuote(req, sup); serr != nil {
+				return deferred, serr
+			}
+			if err != nil {
+				return deferred, err
+			}
+			continue
 		}
-		if err := qm.quoteStore.CreateQuote(suppliers.Quote{
-			ID:         uuid.New().String(),
-			RequestID:  req.ID,
-			SupplierID: sup.ID,
-			Items:      itemsRaw,
-			CreatedAt:  time.Now(),
-		}); err != nil {
-			return fmt.Errorf("save quote: %w", err)
+		if err := qm.saveQuote(req, sup); err != nil {
+			return deferred, err
 		}
 	}
+	return deferred, nil
+}
+
+// saveQuote records the pending quote of sup for req.
+func (qm *QuoteManager) saveQuote(req *QuoteRequest, sup suppliers.Supplier) error {
+	itemsRaw := make([]suppliers.QuoteItem, len(req.Items))
+	for i, it := range req.Items {
+		itemsRaw[i] = suppliers.QuoteItem{Name: it.Name, Qty: it.Qty, Unit: it.Unit}
+	}
+	if err := qm.quoteStore.CreateQuote(suppliers.Quote{
+		ID:         uuid.New().String(),
+		RequestID:  req.ID,
+		SupplierID: sup.ID,
+		Items:      itemsRaw,
+		CreatedAt:  time.Now(),
+	}); err != nil {
+		return fmt.Errorf("save quote: %w", err)
+	}
 	return nil
 }
 
+// sendQuote messages sup right away. It returns false when sup was skipped,
+// and true once the text went out, even if a photo then failed. Photos the
+// sender cannot deliver (outside the Cloud API window, or over the send
+// limit) are skipped.
+func (qm *QuoteManager) sendQuote(ctx context.Context, req *QuoteRequest, sup suppliers.Supplier) (bool, error) {
+	ok, err := qm.checkNumber(req, sup)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	msg, err := qm.composeMessage(ctx, req, sup)
+	if err != nil {
+		return false, fmt.Errorf("compose message for %s: %w", sup.Name, err)
+	}
+
+	waID, err := qm.sender.Send(sup.Address(), msg)
+	if err != nil {
+		if errors.Is(err, whatsapp.ErrRateLimited) {
+			fmt.Printf("  [limite] %s não contactado: %v\n", sup.Name, err)
+			return false, nil
+		}
+		return false, fmt.Errorf("send to %s: %w", sup.Name, err)
+	}
+	qm.record(messages.Message{WAID: waID, Phone: sup.Address(), Body: msg, RequestID: req.ID, SupplierID: sup.ID})
+	// The text is out, so from here on the quote counts as sent
+	for _, img := range req.Images {
+		waID, err := qm.sender.SendImage(sup.Address(), img, "")
+		if errors.Is(err, whatsapp.ErrOutsideWindow) || errors.Is(err, whatsapp.ErrRateLimited) {
+			fmt.Printf("  [aviso] foto não enviada para %s: %v\n", sup.Name, err)
+			continue
+		}
+		if err != nil {
+			return true, fmt.Errorf("send image to %s: %w", sup.Name, err)
+		}
+		qm.record(messages.Message{WAID: waID, Phone: sup.Address(), MediaPath: img, RequestID: req.ID, SupplierID: sup.ID})
+	}
+	return true, nil
+}
+
+// deferQuote queues the messages for sup until opens. In dry-run (no
+// outbox) it only reports when they would go out and returns false.
+func (qm *QuoteManager) deferQuote(ctx context.Context, req *QuoteRequest, sup suppliers.Supplier, opens time.Time) (bool, error) {
+	when := opens.Format("02/01 15h04")
+	if qm.outbox == nil {
+		fmt.Printf("  [fora do horário] %s: seria contactado em %s\n", sup.Name, when)
+		return false, nil
+	}
+
+	msg, err := qm.composeMessage(ctx, req, sup)
+	if err != nil {
+		return false, fmt.Errorf("compose message for %s: %w", sup.Name, err)
+	}
+	queue := []OutboxMessage{{Body: msg}}
+	for _, img := range req.Images {
+		queue = append(queue, OutboxMessage{MediaPath: img})
+	}
+	for _, m := range queue {
+		m.RequestID, m.SupplierID, m.Address, m.NotBefore = req.ID, sup.ID, sup.Address(), opens
+		if err := qm.outbox.Enqueue(m); err != nil {
+			return false, fmt.Errorf("enfileirar mensagem para %s: %w", sup.Name, err)
+		}
+	}
+	fmt.Printf("  [fora do horário] %s: mensagem na fila até %s\n", sup.Name, when)
+	return true, nil
+}
+
+// checkNumber verifies, before the first message to a supplier, that its
+// number is on WhatsApp. Invalid numbers are recorded so they show up in the
+// supplier stats. Failing to check is not fatal: the send is attempted.
+func (qm *QuoteManager) checkNumber(req *QuoteRequest, sup suppliers.Supplier) (bool, error) {
+	contacted, err := qm.msgStore.Contacted(sup.Address())
+	if err != nil {
+		return false, err
+	}
+	if contacted {
+		return true, nil
+	}
+	ok, err := whatsapp.IsOnWhatsApp(qm.sender, sup.Address())
+	if err != nil {
+		fmt.Printf("  [aviso] não foi possível verificar %s: %v\n", sup.Name, err)
+		return true, nil
+	}
+	if !ok {
+		fmt.Printf("  [sem WhatsApp] %s (%s) não contactado\n", sup.Name, sup.Phone)
+		qm.record(messages.Message{Phone: sup.Address(), RequestID: req.ID, SupplierID: sup.ID, Status: messages.StatusInvalid})
+	}
+	return ok, nil
+}
+
+// record saves an outbound message; a failure only loses receipt tracking.
+func (qm *QuoteManager) record(m messages.Message) {
+	if err := qm.msgStore.Record(m); err != nil {
+		fmt.Printf("  [erro] registrar mensagem para %s: %v\n", m.Phone, err)
+	}
+}
+
 func (qm *QuoteManager) composeMessage(ctx context.Context, req *QuoteRequest, sup suppliers.Supplier) (string, error) {
 	tools := []claude.ToolDef{
 		{
@@ -166,6 +366,9 @@ func (qm *QuoteManager) composeMessage(ctx context.Context, req *QuoteRequest, s
 			"A mensagem deve ser clara, incluir os itens e quantidades, e pedir preço unitário e prazo de entrega.",
 		sup.Name, itemsJSON,
 	)
+	if len(req.Images) > 0 {
+		prompt += fmt.Sprintf("\nFoto(s) do item (%d) serão enviadas logo após a mensagem; mencione isso.", len(req.Images))
+	}
 
 	var message string
 	_, err := qm.claude.ChatWithTools(ctx, claude.ChatRequest{
@@ -217,6 +420,32 @@ func (qm *QuoteManager) CompareQuotes(ctx context.Context, req *QuoteRequest, qu
 					"best_supplier":   map[string]any{"type": "string"},
 					"total_price":     map[string]any{"type": "number"},
 					"comparison_table": map[string]any{"type": "string", "description": "Tabela texto comparando fornecedores"},
+					"prices": map[string]any{
+						"type":        "array",
+						"description": "Total cotado por cada cotação que informou preço (omita as que não informaram)",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"quote_id": map[string]any{"type": "string", "description": "ID da cotação"},
+								"total":    map[string]any{"type": "number", "description": "Total em R$ para os itens pedidos"},
+							},
+							"required": []string{"quote_id", "total"},
+						},
+					},
+					"item_prices": map[string]any{
+						"type":        "array",
+						"description": "Preço unitário de cada item do pedido em cada cotação que o informou",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"quote_id":   map[string]any{"type": "string", "description": "ID da cotação"},
+								"item":       map[string]any{"type": "string", "description": "Nome do item como no pedido"},
+								"unit":       map[string]any{"type": "string", "description": "Unidade do preço (ex: saco, kg, un)"},
+								"unit_price": map[string]any{"type": "number", "description": "Preço em R$ por unidade"},
+							},
+							"required": []string{"quote_id", "item", "unit_price"},
+						},
+					},
 				},
 				"required": []string{"recommendation", "best_supplier", "comparison_table"},
 			},
@@ -224,11 +453,18 @@ func (qm *QuoteManager) CompareQuotes(ctx context.Context, req *QuoteRequest, qu
 	}
 
 	quotesJSON, _ := json.Marshal(quotes)
+	itemsJSON, _ := json.Marshal(req.Items)
 	prompt := fmt.Sprintf(
-		"Analise as cotações recebidas para: %q\n\nCotações:\n%s\n\n"+
-			"Use compare_quotes para recomendar a melhor opção, considerando preço, prazo e qualidade.",
-		req.Description, quotesJSON,
+		"Analise as cotações recebidas para: %q\n\nItens do pedido: %s\n\nCotações:\n%s\n\n"+
+			"Use compare_quotes para recomendar a melhor opção, considerando preço, prazo e qualidade, "+
+			"informe em prices o total de cada cotação que deu preço (quote_id = campo ID) "+
+			"e em item_prices o preço unitário de cada item que cada cotação informou.",
+		req.Description, itemsJSON, quotesJSON,
 	)
+	if where := qm.distances(req, quotes); where != "" {
+		prompt += "\n\nDistância de cada fornecedor até o local de entrega (" + req.Place + "):\n" + where +
+			"Considere também a distância e se o fornecedor entrega no local: quem não entrega exige buscar a compra."
+	}
 
 	var result QuoteComparison
 	_, err := qm.claude.ChatWithTools(ctx, claude.ChatRequest{
@@ -241,6 +477,16 @@ func (qm *QuoteManager) CompareQuotes(ctx context.Context, req *QuoteRequest, qu
 			BestSupplier    string  `json:"best_supplier"`
 			TotalPrice      float64 `json:"total_price"`
 			ComparisonTable string  `json:"comparison_table"`
+			Prices          []struct {
+				QuoteID string  `json:"quote_id"`
+				Total   float64 `json:"total"`
+			} `json:"prices"`
+			ItemPrices []struct {
+				QuoteID   string  `json:"quote_id"`
+				Item      string  `json:"item"`
+				Unit      string  `json:"unit"`
+				UnitPrice float64 `json:"unit_price"`
+			} `json:"item_prices"`
 		}
 		if err := json.Unmarshal(input, &r); err != nil {
 			return "", err
@@ -250,6 +496,17 @@ func (qm *QuoteManager) CompareQuotes(ctx context.Context, req *QuoteRequest, qu
 			BestSupplier:   r.BestSupplier,
 			TotalPrice:     r.TotalPrice,
 			Table:          r.ComparisonTable,
+			Prices:         make(map[string]float64),
+		}
+		for _, p := range r.Prices {
+			if p.Total > 0 {
+				result.Prices[p.QuoteID] = p.Total
+			}
+		}
+		for _, p := range r.ItemPrices {
+			if p.UnitPrice > 0 {
+				result.UnitPrices = append(result.UnitPrices, UnitPrice{QuoteID: p.QuoteID, Item: p.Item, Unit: p.Unit, Price: p.UnitPrice})
+			}
 		}
 		return "ok", nil
 	})
@@ -259,3 +516,27 @@ func (qm *QuoteManager) CompareQuotes(ctx context.Context, req *QuoteRequest, qu
 
 	return &result, nil
 }
+
+// distances lists how far each quoting supplier is from where req is
+// delivered, one per line, or returns "" when req has no place.
+func (qm *QuoteManager) distances(req *QuoteRequest, quotes []suppliers.Quote) string {
+	if req.Near.IsZero() {
+		return ""
+	}
+	var b strings.Builder
+	for _, q := range quotes {
+		sup, err := qm.supStore.Get(q.SupplierID)
+		if err != nil || sup == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s (SupplierID %s): %s", sup.Name, sup.ID, suppliers.FormatDistance(sup.DistanceFrom(req.Near)))
+		switch {
+		case sup.DeliversTo(req.Near):
+			fmt.Fprintf(&b, ", entrega no local (raio de %s)", suppliers.FormatDistance(sup.DeliveryRadius))
+		case sup.DeliveryRadius > 0 && !sup.Geo.IsZero():
+			fmt.Fprintf(&b, ", fora do raio de entrega (%s)", suppliers.FormatDistance(sup.DeliveryRadius))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,226 @@
+package comprador
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Request statuses.
+const (
+	StatusOpen      = "open"      // quotes sent, waiting for replies
+	StatusClosed    = "closed"    // deadline reached or all suppliers replied; comparison done
+	StatusAccepted  = "accepted"  // owner chose a supplier
+	StatusCancelled = "cancelled" // owner gave up on the request
+)
+
+// RequestStore persists quote requests so that any process (CLI or daemon)
+// can pick up open requests and close them when their deadline passes.
+type RequestStore struct {
+	db *sql.DB
+}
+
+// NewRequestStore creates a RequestStore.
+func NewRequestStore(db *sql.DB) *RequestStore {
+	return &RequestStore{db: db}
+}
+
+// Create records a newly dispatched request.
+func (rs *RequestStore) Create(req *QuoteRequest) error {
+	items, err := json.Marshal(req.Items)
+	if err != nil {
+		return fmt.Errorf("marshal items: %w", err)
+	}
+	images, err := json.Marshal(append([]string{}, req.Images...))
+	if err != nil {
+		return fmt.Errorf("marshal images: %w", err)
+	}
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now()
+	}
+	if req.Status == "" {
+		req.Status = StatusOpen
+	}
+	_, err = rs.db.Exec(
+		`INSERT INTO quote_requests (id, description, items, images, urgent, status, deadline, created_at, place, lat, lng, max_radius)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.ID, req.Description, string(items), string(images), req.Urgent, req.Status, req.Deadline, req.CreatedAt,
+		req.Place, req.Near.Lat, req.Near.Lng, req.MaxRadius,
+	)
+	if err != nil {
+		return fmt.Errorf("insert request: %w", err)
+	}
+	return nil
+}
+
+// Get returns a request by ID, or nil if not found.
+func (rs *RequestStore) Get(id string) (*QuoteRequest, error) {
+	row := rs.db.QueryRow(
+		`SELECT id, description, items, images, urgent, status, deadline, created_at, place, lat, lng, max_radius,
+		        compare_failures, compare_retry_at
+		 FROM quote_requests WHERE id = ?`, id,
+	)
+	req, err := scanRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return req, err
+}
+
+// Open returns all requests still waiting for replies, oldest first.
+func (rs *RequestStore) Open() ([]QuoteRequest, error) {
+	return rs.query(
+		`SELECT id, description, items, images, urgent, status, deadline, created_at, place, lat, lng, max_radius,
+		        compare_failures, compare_retry_at
+		 FROM quote_requests WHERE status = ? ORDER BY created_at`, StatusOpen,
+	)
+}
+
+// Recent returns the most recent n requests regardless of status.
+func (rs *RequestStore) Recent(n int) ([]QuoteRequest, error) {
+	return rs.query(
+		`SELECT id, description, items, images, urgent, status, deadline, created_at, place, lat, lng, max_radius,
+		        compare_failures, compare_retry_at
+		 FROM quote_requests ORDER BY created_at DESC LIMIT ?`, n,
+	)
+}
+
+// Latest returns the most recent request in one of the given statuses, or nil.
+func (rs *RequestStore) Latest(statuses ...string) (*QuoteRequest, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(statuses))
+	marks := make([]string, len(statuses))
+	for i, st := range statuses {
+		args[i] = st
+		marks[i] = "?"
+	}
+	reqs, err := rs.query(
+		`SELECT id, description, items, images, urgent, status, deadline, created_at, place, lat, lng, max_radius,
+		        compare_failures, compare_retry_at
+		 FROM quote_requests WHERE status IN (`+strings.Join(marks, ",")+`)
+		 ORDER BY created_at DESC LIMIT 1`, args...,
+	)
+	if err != nil || len(reqs) == 0 {
+		return nil, err
+	}
+	return &reqs[0], nil
+}
+
+// SetStatus updates the status of a request, stamping closed_at when it leaves "open".
+func (rs *RequestStore) SetStatus(id, status string) error {
+	var closedAt any
+	if status != StatusOpen {
+		closedAt = time.Now()
+	}
+	_, err := rs.db.Exec(
+		`UPDATE quote_requests SET status = ?, closed_at = COALESCE(closed_at, ?) WHERE id = ?`,
+		status, closedAt, id,
+	)
+	return err
+}
+
+// SetDeadline moves the deadline of a request.
+func (rs *RequestStore) SetDeadline(id string, deadline time.Time) error {
+	_, err := rs.db.Exec(`UPDATE quote_requests SET deadline = ? WHERE id = ?`, deadline, id)
+	return err
+}
+
+// CompareFailed counts a failed comparison of the replies to a request and
+// sets when the next one may be tried.
+func (rs *RequestStore) CompareFailed(id string, retryAt time.Time) error {
+	_, err := rs.db.Exec(
+		`UPDATE quote_requests SET compare_failures = compare_failures + 1, compare_retry_at = ? WHERE id = ?`,
+		retryAt, id,
+	)
+	return err
+}
+
+func (rs *RequestStore) query(q string, args ...any) ([]QuoteRequest, error) {
+	rows, err := rs.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []QuoteRequest
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *req)
+	}
+	return result, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRequest(row rowScanner) (*QuoteRequest, error) {
+	var req QuoteRequest
+	var itemsJSON, imagesJSON string
+	var retryAt sql.NullTime
+	err := row.Scan(&req.ID, &req.Description, &itemsJSON, &imagesJSON, &req.Urgent, &req.Status, &req.Deadline, &req.CreatedAt,
+		&req.Place, &req.Near.Lat, &req.Near.Lng, &req.MaxRadius, &req.CompareFailures, &retryAt)
+	if err != nil {
+		return nil, err
+	}
+	req.RetryAt = retryAt.Time
+	_ = json.Unmarshal([]byte(itemsJSON), &req.Items)
+	_ = json.Unmarshal([]byte(imagesJSON), &req.Images)
+	req.Timeout = req.Deadline.Sub(req.CreatedAt)
+	return &req, nil
+}
+
+// archiveImages copies the request photos to MediaDir/requests/<id>/ so the
+// quote keeps them even if the originals move, and points req.Images there.
+func (a *Agent) archiveImages(req *QuoteRequest) error {
+	if len(req.Images) == 0 {
+		return nil
+	}
+	base := a.cfg.MediaDir
+	if base == "" {
+		base = DefaultConfig().MediaDir
+	}
+	dir := filepath.Join(base, "requests", req.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	archived := make([]string, len(req.Images))
+	for i, src := range req.Images {
+		dst := filepath.Join(dir, fmt.Sprintf("%d-%s", i+1, filepath.Base(src)))
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		archived[i] = dst
+	}
+	req.Images = archived
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,51 @@
+package comprador
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+// scoreInterval is how often the daemon recomputes supplier ratings.
+const scoreInterval = 6 * time.Hour
+
+// Rescore recomputes the rating of every supplier with a record from how it
+// answered, priced, won and was rated (see suppliers.ScoreStore).
+func (a *Agent) Rescore() ([]suppliers.Score, error) {
+	scores, err := a.scores.Compute(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return scores, a.scores.Save(scores)
+}
+
+// RescoreDue rescores when the last run is older than scoreInterval.
+func (a *Agent) RescoreDue() {
+	last, err := a.scores.LastUpdate()
+	if err != nil {
+		fmt.Printf("[erro] ler notas dos fornecedores: %v\n", err)
+		return
+	}
+	if time.Since(last) < scoreInterval {
+		return
+	}
+	if _, err := a.Rescore(); err != nil {
+		fmt.Printf("[erro] recalcular notas dos fornecedores: %v\n", err)
+	}
+}
+
+// RateSupplier records the owner's 1-5 rating of a supplier and rescores.
+func (a *Agent) RateSupplier(ref string, rating int, comment string) (*suppliers.Supplier, error) {
+	sup, err := a.FindSupplier(ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.feedback.Add(suppliers.Feedback{SupplierID: sup.ID, Rating: rating, Comment: comment}); err != nil {
+		return nil, err
+	}
+	if _, err := a.Rescore(); err != nil {
+		return nil, err
+	}
+	return sup, nil
+}
@@ -0,0 +1,266 @@
+package comprador
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+// Strategies for choosing which of the matched suppliers are asked when
+// more match than a request may message.
+const (
+	StrategyTopRated   = "top-rated"   // best rated first
+	StrategyCheapest   = "cheapest"    // cheapest by the prices they quoted before first
+	StrategyRoundRobin = "round-robin" // least recently asked first, so new suppliers get a turn
+	StrategyExplore    = "explore"     // best rated, but each place may go to a less known supplier
+)
+
+// Strategies lists the supplier selection strategies.
+var Strategies = []string{StrategyTopRated, StrategyCheapest, StrategyRoundRobin, StrategyExplore}
+
+const (
+	// defaultMaxSuppliers is how many suppliers a request messages if
+	// Config.MaxSuppliers is not set.
+	defaultMaxSuppliers = 5
+	// defaultExplore is the chance a place goes to a random supplier under
+	// StrategyExplore if Config.Explore is not set.
+	defaultExplore = 0.2
+	// cooldownWindow is the period Config.WeeklyLimit counts requests in.
+	cooldownWindow = 7 * 24 * time.Hour
+)
+
+// CheckStrategy fails unless s is empty (the default) or a known strategy.
+func CheckStrategy(s string) error {
+	if s == "" {
+		return nil
+	}
+	for _, known := range Strategies {
+		if s == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("estratégia %q desconhecida; use %s", s, strings.Join(Strategies, ", "))
+}
+
+func (a *Agent) maxSuppliers(req *QuoteRequest) int {
+	switch {
+	case req.MaxSuppliers > 0:
+		return req.MaxSuppliers
+	case a.cfg.MaxSuppliers > 0:
+		return a.cfg.MaxSuppliers
+	}
+	return defaultMaxSuppliers
+}
+
+func (a *Agent) strategy(req *QuoteRequest) string {
+	switch {
+	case req.Strategy != "":
+		return req.Strategy
+	case a.cfg.Strategy != "":
+		return a.cfg.Strategy
+	}
+	return StrategyTopRated
+}
+
+func (a *Agent) explore() float64 {
+	if a.cfg.Explore > 0 {
+		return math.Min(a.cfg.Explore, 1)
+	}
+	return defaultExplore
+}
+
+// selection is the outcome of choosing who gets a request.
+type selection struct {
+	chosen   []suppliers.MatchResult
+	resting  []suppliers.MatchResult // asked WeeklyLimit times in the last week
+	left     int                     // matched, not resting, but over the maximum
+	strategy string
+	explored map[string]bool // IDs chosen by exploration
+	asked    map[string]suppliers.Asked
+}
+
+// selectSuppliers picks who of the matched suppliers gets req: those not
+// resting after WeeklyLimit requests in the last week, at most
+// maxSuppliers of them. Past the limit they are taken in the order of the
+// strategy, first one for each item no supplier chosen so far serves, so
+// every item is still asked of someone.
+func (a *Agent) selectSuppliers(req *QuoteRequest, matches []suppliers.MatchResult) (*selection, error) {
+	now := time.Now()
+	sel := &selection{strategy: a.strategy(req), explored: make(map[string]bool)}
+	asked, err := a.qStore.AskedCounts(now.Add(-cooldownWindow))
+	if err != nil {
+		return nil, fmt.Errorf("histórico de pedidos: %w", err)
+	}
+	sel.asked = asked
+	var open []suppliers.MatchResult
+	for _, m := range matches {
+		if a.cfg.WeeklyLimit > 0 && asked[m.Supplier.ID].Recent >= a.cfg.WeeklyLimit {
+			sel.resting = append(sel.resting, m)
+			continue
+		}
+		open = append(open, m)
+	}
+
+	limit := a.maxSuppliers(req)
+	if len(open) <= limit {
+		sel.chosen = open
+		return sel, nil
+	}
+	ordered, err := a.orderMatches(sel.strategy, req, open, sel.asked)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool)
+	take := func(m suppliers.MatchResult) {
+		taken[m.Supplier.ID] = true
+		sel.chosen = append(sel.chosen, m)
+	}
+	served := make(map[string]bool)
+	for _, item := range req.itemNames() {
+		if served[item] || len(sel.chosen) == limit {
+			continue
+		}
+		for _, m := range ordered {
+			if !taken[m.Supplier.ID] && serves(m, item) {
+				take(m)
+				for _, it := range m.Items {
+					served[it] = true
+				}
+				break
+			}
+		}
+	}
+	for len(sel.chosen) < limit {
+		var rest []suppliers.MatchResult
+		for _, m := range ordered {
+			if !taken[m.Supplier.ID] {
+				rest = append(rest, m)
+			}
+		}
+		if sel.strategy == StrategyExplore && len(rest) > 1 && rand.Float64() < a.explore() {
+			m := pickLessKnown(rest, sel.asked)
+			sel.explored[m.Supplier.ID] = true
+			take(m)
+			continue
+		}
+		take(rest[0])
+	}
+	sel.left = len(open) - len(sel.chosen)
+	return sel, nil
+}
+
+func serves(m suppliers.MatchResult, item string) bool {
+	for _, it := range m.Items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
+
+// pickLessKnown chooses one of ms at random, a supplier asked n times
+// before weighing 1/(n+1), so those rarely asked come up more often.
+func pickLessKnown(ms []suppliers.MatchResult, asked map[string]suppliers.Asked) suppliers.MatchResult {
+	weights := make([]float64, len(ms))
+	total := 0.0
+	for i, m := range ms {
+		weights[i] = 1 / float64(asked[m.Supplier.ID].Count+1)
+		total += weights[i]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return ms[i]
+		}
+		r -= w
+	}
+	return ms[len(ms)-1]
+}
+
+// orderMatches sorts a copy of ms by strategy, ties kept in the matcher's
+// order (which weighs distance).
+func (a *Agent) orderMatches(strategy string, req *QuoteRequest, ms []suppliers.MatchResult, asked map[string]suppliers.Asked) ([]suppliers.MatchResult, error) {
+	out := append([]suppliers.MatchResult(nil), ms...)
+	byRating := func(i, j int) bool { return out[i].Supplier.Rating > out[j].Supplier.Rating }
+	switch strategy {
+	case StrategyCheapest:
+		rel, err := a.relativePrices(req, out)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			ri, okI := rel[out[i].Supplier.ID]
+			rj, okJ := rel[out[j].Supplier.ID]
+			switch {
+			case okI && okJ && ri != rj:
+				return ri < rj
+			case okI != okJ:
+				return okI // known prices first
+			}
+			return byRating(i, j)
+		})
+	case StrategyRoundRobin:
+		sort.SliceStable(out, func(i, j int) bool {
+			li, lj := asked[out[i].Supplier.ID].Last, asked[out[j].Supplier.ID].Last
+			if !li.Equal(lj) {
+				return li.Before(lj) // never asked (zero) first
+			}
+			return byRating(i, j)
+		})
+	default:
+		sort.SliceStable(out, byRating)
+	}
+	return out, nil
+}
+
+// relativePrices returns how each supplier's prices compare with the
+// cheapest known: the mean, over the items of req it quoted in the last
+// estimateWindow, of its price over the lowest; 1 is always the cheapest.
+// Suppliers that quoted none of the items fall back to their score's price
+// index, and are absent when that is unknown too.
+func (a *Agent) relativePrices(req *QuoteRequest, ms []suppliers.MatchResult) (map[string]float64, error) {
+	since := time.Now().Add(-estimateWindow)
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, it := range req.Items {
+		known, err := a.prices.Latest(it.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		low := math.Inf(1)
+		for _, p := range known {
+			if p.Price > 0 {
+				low = math.Min(low, p.Price)
+			}
+		}
+		for _, p := range known {
+			if p.Price > 0 {
+				sums[p.SupplierID] += p.Price / low
+				counts[p.SupplierID]++
+			}
+		}
+	}
+
+	rel := make(map[string]float64)
+	for _, m := range ms {
+		id := m.Supplier.ID
+		if counts[id] > 0 {
+			rel[id] = sums[id] / float64(counts[id])
+			continue
+		}
+		s, err := a.scores.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil && s.Priced > 0 && s.PriceIndex > 0 {
+			rel[id] = 1 / s.PriceIndex
+		}
+	}
+	return rel, nil
+}
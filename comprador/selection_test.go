@@ -0,0 +1,170 @@
+package comprador
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+)
+
+func match(id string, rating float64, items ...string) suppliers.MatchResult {
+	return suppliers.MatchResult{Supplier: suppliers.Supplier{ID: id, Name: id, Rating: rating}, Items: items}
+}
+
+func request(items ...string) *QuoteRequest {
+	req := &QuoteRequest{ID: "r"}
+	for _, it := range items {
+		req.Items = append(req.Items, ParsedItem{Name: it, Qty: 1})
+	}
+	return req
+}
+
+// ask records that supplierID was asked for a quote at the given time.
+func ask(t *testing.T, a *Agent, supplierID string, at time.Time) {
+	t.Helper()
+	id := fmt.Sprintf("q-%s-%d", supplierID, at.UnixNano())
+	if err := a.qStore.CreateQuote(suppliers.Quote{ID: id, RequestID: "antigo", SupplierID: supplierID, CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func chosenIDs(sel *selection) []string {
+	var ids []string
+	for _, m := range sel.chosen {
+		ids = append(ids, m.Supplier.ID)
+	}
+	return ids
+}
+
+func TestSelectSuppliersUnderTheCapTakesAll(t *testing.T) {
+	a := newTestAgent(t, Config{MaxSuppliers: 3})
+	sel, err := a.selectSuppliers(request("arroz"), []suppliers.MatchResult{
+		match("a", 3, "arroz"), match("b", 5, "arroz"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chosenIDs(sel); !reflect.DeepEqual(got, []string{"a", "b"}) || sel.left != 0 {
+		t.Errorf("got %v (left %d), want both in match order", got, sel.left)
+	}
+}
+
+func TestSelectSuppliersCapStillCoversEveryItem(t *testing.T) {
+	a := newTestAgent(t, Config{})
+	req := request("arroz", "feijão", "óleo")
+	req.MaxSuppliers = 2
+	sel, err := a.selectSuppliers(req, []suppliers.MatchResult{
+		match("a", 5, "arroz", "feijão"),
+		match("b", 4.5, "arroz"),
+		match("c", 4, "feijão"),
+		match("d", 3, "óleo"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b is rated above d, but only d sells óleo
+	if got := chosenIDs(sel); !reflect.DeepEqual(got, []string{"a", "d"}) {
+		t.Errorf("got %v, want [a d]", got)
+	}
+	if sel.left != 2 {
+		t.Errorf("left = %d, want 2", sel.left)
+	}
+}
+
+func TestSelectSuppliersStrategies(t *testing.T) {
+	now := time.Now()
+	matches := []suppliers.MatchResult{match("a", 5, "arroz"), match("b", 4, "arroz"), match("c", 3, "arroz")}
+
+	tests := []struct {
+		strategy string
+		setup    func(t *testing.T, a *Agent)
+		want     []string
+	}{
+		{StrategyTopRated, nil, []string{"a", "b"}},
+		{StrategyCheapest, func(t *testing.T, a *Agent) {
+			// c quoted nothing, so it comes after those with known prices
+			err := a.prices.Record([]suppliers.Price{
+				{SupplierID: "a", QuoteID: "q-a", Item: "arroz", Price: 12},
+				{SupplierID: "b", QuoteID: "q-b", Item: "arroz", Price: 10},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"b", "a"}},
+		{StrategyRoundRobin, func(t *testing.T, a *Agent) {
+			ask(t, a, "a", now.Add(-time.Hour))
+			ask(t, a, "b", now.Add(-48*time.Hour))
+		}, []string{"c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			a := newTestAgent(t, Config{MaxSuppliers: 2, Strategy: tt.strategy})
+			if tt.setup != nil {
+				tt.setup(t, a)
+			}
+			sel, err := a.selectSuppliers(request("arroz"), matches)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := chosenIDs(sel); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if len(sel.explored) != 0 {
+				t.Errorf("explored %v without StrategyExplore", sel.explored)
+			}
+		})
+	}
+}
+
+func TestSelectSuppliersExplore(t *testing.T) {
+	a := newTestAgent(t, Config{MaxSuppliers: 2, Strategy: StrategyExplore, Explore: 1})
+	for i := 0; i < 5; i++ {
+		ask(t, a, "b", time.Now().Add(-time.Duration(i+1)*time.Hour))
+	}
+	sel, err := a.selectSuppliers(request("arroz"), []suppliers.MatchResult{
+		match("a", 5, "arroz"), match("b", 4, "arroz"), match("c", 3, "arroz"), match("d", 2, "arroz"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := chosenIDs(sel)
+	// The item's first supplier is always the best rated; the other place
+	// goes to exploration
+	if len(got) != 2 || got[0] != "a" || sel.explored["a"] {
+		t.Fatalf("got %v (explored %v), want a first and not explored", got, sel.explored)
+	}
+	if !sel.explored[got[1]] || len(sel.explored) != 1 {
+		t.Errorf("explored %v, want only %s", sel.explored, got[1])
+	}
+}
+
+func TestSelectSuppliersRestsAfterWeeklyLimit(t *testing.T) {
+	a := newTestAgent(t, Config{WeeklyLimit: 2})
+	now := time.Now()
+	ask(t, a, "a", now.Add(-time.Hour))
+	ask(t, a, "a", now.Add(-48*time.Hour))
+	for i := 0; i < 3; i++ {
+		ask(t, a, "b", now.Add(-time.Duration(10+i)*24*time.Hour))
+	}
+
+	sel, err := a.selectSuppliers(request("arroz"), []suppliers.MatchResult{
+		match("a", 5, "arroz"), match("b", 4, "arroz"), match("c", 3, "arroz"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.resting) != 1 || sel.resting[0].Supplier.ID != "a" {
+		t.Errorf("resting %v, want a only", sel.resting)
+	}
+	if got := chosenIDs(sel); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("got %v, want [b c]", got)
+	}
+	if got := sel.asked["b"]; got.Count != 3 || got.Recent != 0 {
+		t.Errorf("b asked %+v, want 3 ever and none this week", got)
+	}
+	if got := sel.asked["a"]; got.Count != 2 || got.Recent != 2 {
+		t.Errorf("a asked %+v, want 2 this week", got)
+	}
+}
@@ -0,0 +1,288 @@
+package comprador
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/agente/internal/alert"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// healthGrace is how long the WhatsApp link may be down before the owner is
+// alerted; short drops are reconnected without bothering anyone.
+const healthGrace = 2 * time.Minute
+
+// DefaultSocketPath is where the daemon listens for control commands.
+const DefaultSocketPath = "data/comprador.sock"
+
+// ControlRequest is a command sent to the daemon over its control socket.
+// The wire format is one JSON object per connection, answered by a ControlResponse.
+type ControlRequest struct {
+	Op      string        `json:"op"` // "quote", "status" or "send"
+	Request *QuoteRequest `json:"request,omitempty"`
+	To      string        `json:"to,omitempty"`   // send: contact (name, phone or address)
+	Text    string        `json:"text,omitempty"` // send: message
+}
+
+// ControlResponse is the daemon's answer to a ControlRequest.
+type ControlResponse struct {
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Server is the long-running daemon: it keeps the agent's sender connected,
+// closes requests when their deadline passes and accepts new work over a
+// local unix socket.
+type Server struct {
+	agent      *Agent
+	socketPath string
+	interval   time.Duration
+
+	alerter     alert.Alerter
+	outageAlert bool // an outage alert was sent and not yet resolved
+}
+
+// NewServer creates a daemon around an agent whose sender is already connected.
+// Outages are reported on stdout until SetAlerter sets another channel.
+func NewServer(agent *Agent, socketPath string) *Server {
+	if socketPath == "" {
+		socketPath = DefaultSocketPath
+	}
+	return &Server{agent: agent, socketPath: socketPath, interval: 30 * time.Second, alerter: alert.Stdout{}}
+}
+
+// SetAlerter sets the channel used to tell the owner that WhatsApp is down.
+// It must not depend on WhatsApp.
+func (s *Server) SetAlerter(a alert.Alerter) {
+	s.alerter = a
+}
+
+// Run serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := listenControl(s.socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(s.socketPath)
+	defer ln.Close()
+
+	go s.accept(ctx, ln)
+
+	// Requests may have expired, and queued messages fallen due, while no
+	// daemon was running
+	s.agent.FlushOutbox(ctx)
+	s.agent.CloseDue(ctx)
+	s.agent.RescoreDue()
+	s.agent.AskFeedbackDue()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.checkHealth(ctx)
+			s.agent.FlushOutbox(ctx)
+			s.agent.RescoreDue()
+			s.agent.AskFeedbackDue()
+		case <-s.agent.Wake():
+		}
+		s.agent.CloseDue(ctx)
+	}
+}
+
+// checkHealth alerts the owner when the WhatsApp link has been down for
+// longer than healthGrace while quotes are open (replies would be lost), or
+// as soon as the session is logged out, which needs the owner to re-pair.
+// A second alert follows when the link is back.
+func (s *Server) checkHealth(ctx context.Context) {
+	h := s.agent.Health()
+	if h.OK() {
+		if s.outageAlert {
+			s.outageAlert = false
+			s.sendAlert(ctx, "WhatsApp reconectado", "O agente de compras voltou a receber mensagens.")
+		}
+		return
+	}
+	if s.outageAlert {
+		return
+	}
+
+	open, err := s.agent.rStore.Open()
+	if err != nil {
+		fmt.Printf("[daemon] listar pedidos abertos: %v\n", err)
+		return
+	}
+	switch {
+	case h.State == whatsapp.HealthLoggedOut:
+		s.sendAlert(ctx, "WhatsApp desconectado",
+			fmt.Sprintf("A sessão do agente de compras foi encerrada (%s). Pareie de novo com o QR code no terminal do daemon. Pedidos abertos: %d.",
+				h.Detail, len(open)))
+	case len(open) > 0 && time.Since(h.Since) > healthGrace:
+		s.sendAlert(ctx, "WhatsApp fora do ar",
+			fmt.Sprintf("Sem conexão desde %s (%s, %d tentativas). %d pedido(s) aguardando respostas de fornecedores.",
+				h.Since.Format("15h04"), h.Detail, h.Attempts, len(open)))
+	default:
+		return
+	}
+	s.outageAlert = true
+}
+
+func (s *Server) sendAlert(ctx context.Context, title, message string) {
+	if err := s.alerter.Alert(ctx, title, message); err != nil {
+		fmt.Printf("[daemon] %v\n", err)
+	}
+}
+
+func (s *Server) accept(ctx context.Context, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("[daemon] accept: %v\n", err)
+			continue
+		}
+		go s.handleConn(ctx, conn)
+	}
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	var req ControlRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		_ = json.NewEncoder(conn).Encode(ControlResponse{Error: "requisição inválida: " + err.Error()})
+		return
+	}
+	_ = json.NewEncoder(conn).Encode(s.handle(ctx, req))
+}
+
+func (s *Server) handle(ctx context.Context, req ControlRequest) ControlResponse {
+	switch req.Op {
+	case "quote":
+		if req.Request == nil || len(req.Request.Items) == 0 {
+			return ControlResponse{Error: "pedido sem itens"}
+		}
+		qr := req.Request
+		// Matching and sending can take a while (LLM calls, paced sends);
+		// answer the client right away and report failures in the daemon log.
+		go func() {
+			if _, err := s.agent.Dispatch(ctx, qr); err != nil {
+				fmt.Printf("[daemon] pedido %q: %v\n", qr.Description, err)
+				s.agent.notify(fmt.Sprintf("⚠️ Falha ao enviar cotação %q: %v", qr.Description, err))
+			}
+		}()
+		return ControlResponse{OK: true, RequestID: qr.ID, Message: "pedido recebido pelo daemon"}
+	case "status":
+		report, err := s.agent.StatusReport()
+		if err != nil {
+			return ControlResponse{Error: err.Error()}
+		}
+		return ControlResponse{OK: true, Message: report}
+	case "send":
+		if req.To == "" || strings.TrimSpace(req.Text) == "" {
+			return ControlResponse{Error: "informe o contato e a mensagem"}
+		}
+		if err := s.agent.Reply(req.To, req.Text); err != nil {
+			return ControlResponse{Error: err.Error()}
+		}
+		return ControlResponse{OK: true, Message: "mensagem enviada"}
+	default:
+		return ControlResponse{Error: fmt.Sprintf("operação desconhecida: %q", req.Op)}
+	}
+}
+
+// listenControl opens the unix socket, removing a stale socket file left by a
+// daemon that did not shut down cleanly.
+func listenControl(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create socket dir: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("daemon já está rodando (socket %s)", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// ControlClient submits work to a running daemon.
+type ControlClient struct {
+	socketPath string
+}
+
+// DialControl returns a client if a daemon is listening on socketPath.
+func DialControl(socketPath string) (*ControlClient, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return &ControlClient{socketPath: socketPath}, nil
+}
+
+// Submit hands a prepared request to the daemon, which matches suppliers and
+// sends the quotes. It returns as soon as the daemon accepted the request.
+func (c *ControlClient) Submit(req *QuoteRequest) (string, error) {
+	resp, err := c.call(ControlRequest{Op: "quote", Request: req})
+	if err != nil {
+		return "", err
+	}
+	return resp.RequestID, nil
+}
+
+// Status returns the daemon's report of open requests.
+func (c *ControlClient) Status() (string, error) {
+	resp, err := c.call(ControlRequest{Op: "status"})
+	if err != nil {
+		return "", err
+	}
+	return resp.Message, nil
+}
+
+// Send has the daemon send a manual message to a contact over its
+// connected channels.
+func (c *ControlClient) Send(to, text string) error {
+	_, err := c.call(ControlRequest{Op: "send", To: to, Text: text})
+	return err
+}
+
+func (c *ControlClient) call(req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("conectar ao daemon: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("enviar ao daemon: %w", err)
+	}
+	var resp ControlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("resposta do daemon: %w", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("daemon: %s", resp.Error)
+	}
+	return &resp, nil
+}
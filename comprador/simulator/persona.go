@@ -0,0 +1,163 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/claude"
+)
+
+// Persona describes how a simulated supplier answers quote requests.
+type Persona struct {
+	Supplier     string        `yaml:"supplier" json:"supplier"`           // supplier name or phone
+	PriceLevel   float64       `yaml:"price_level" json:"price_level"`     // 1.0 = market price, 0.9 = 10% cheaper
+	ReplyAfter   time.Duration `yaml:"reply_after" json:"-"`               // typical time to answer
+	Jitter       time.Duration `yaml:"jitter" json:"-"`                    // ± random spread around ReplyAfter
+	OutOfStock   float64       `yaml:"out_of_stock" json:"out_of_stock"`   // chance an item is missing
+	AsksQuestion float64       `yaml:"asks_question" json:"asks_question"` // chance of asking before quoting
+	Silent       float64       `yaml:"silent" json:"silent"`               // chance of never answering
+	Style        string        `yaml:"style" json:"style"`                 // how they write, for the LLM
+}
+
+// personaFile is the YAML layout: a list under "personas".
+type personaFile struct {
+	Personas []Persona `yaml:"personas"`
+}
+
+// Load reads personas from a YAML file.
+func Load(path string) ([]Persona, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f personaFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Personas, nil
+}
+
+// Save writes personas as YAML, so generated ones can be reviewed and reused.
+func Save(path string, personas []Persona) error {
+	data, err := yaml.Marshal(personaFile{Personas: personas})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// matches reports whether the persona is meant for sup.
+func (p Persona) matches(sup suppliers.Supplier) bool {
+	key := strings.TrimSpace(p.Supplier)
+	return strings.EqualFold(key, sup.Name) || (sup.Phone != "" && key == sup.Phone)
+}
+
+// Generate asks the LLM to invent a plausible, varied persona for each
+// supplier. Suppliers the model skips get a random one.
+func Generate(ctx context.Context, cl *claude.Client, sups []suppliers.Supplier, rng *rand.Rand) ([]Persona, error) {
+	tools := []claude.ToolDef{
+		{
+			Name:        "define_personas",
+			Description: "Define o comportamento simulado de cada fornecedor",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"personas": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"supplier":      map[string]any{"type": "string", "description": "Nome exato do fornecedor"},
+								"price_level":   map[string]any{"type": "number", "description": "Nível de preço: 1.0 = mercado, 0.85 = barato, 1.2 = caro"},
+								"reply_minutes": map[string]any{"type": "number", "description": "Minutos típicos até responder"},
+								"out_of_stock":  map[string]any{"type": "number", "description": "Probabilidade (0-1) de faltar algum item"},
+								"asks_question": map[string]any{"type": "number", "description": "Probabilidade (0-1) de perguntar algo antes de cotar"},
+								"silent":        map[string]any{"type": "number", "description": "Probabilidade (0-1) de não responder"},
+								"style":         map[string]any{"type": "string", "description": "Como escreve no WhatsApp"},
+							},
+							"required": []string{"supplier", "price_level", "reply_minutes", "style"},
+						},
+					},
+				},
+				"required": []string{"personas"},
+			},
+		},
+	}
+
+	var names []string
+	for _, s := range sups {
+		names = append(names, fmt.Sprintf("- %s (%s; %s)", s.Name, s.City, strings.Join(s.Categories, ", ")))
+	}
+	prompt := "Crie personas variadas e realistas para simular estes fornecedores respondendo cotações no WhatsApp. " +
+		"Misture lojas rápidas e lentas, baratas e caras, atenciosas e secas:\n" + strings.Join(names, "\n")
+
+	var out []Persona
+	_, err := cl.ChatWithTools(ctx, claude.ChatRequest{
+		System: "Você cria dados de teste para um agente de compras.",
+		User:   prompt,
+		Tools:  tools,
+	}, func(name string, input json.RawMessage) (string, error) {
+		var r struct {
+			Personas []struct {
+				Persona
+				ReplyMinutes float64 `json:"reply_minutes"`
+			} `json:"personas"`
+		}
+		if err := json.Unmarshal(input, &r); err != nil {
+			return "", err
+		}
+		for _, p := range r.Personas {
+			p.Persona.ReplyAfter = time.Duration(p.ReplyMinutes * float64(time.Minute))
+			p.Persona.Jitter = p.Persona.ReplyAfter / 3
+			out = append(out, p.Persona)
+		}
+		return "ok", nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in whoever the model left out
+	for _, s := range sups {
+		found := false
+		for _, p := range out {
+			if p.matches(s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, Random(s, rng))
+		}
+	}
+	return out, nil
+}
+
+// Random returns a persona with random but plausible traits.
+func Random(sup suppliers.Supplier, rng *rand.Rand) Persona {
+	styles := []string{
+		"cordial, usa emojis",
+		"seco e direto, só números",
+		"formal, manda tabela item a item",
+		"informal, escreve com abreviações",
+	}
+	reply := time.Duration(3+rng.Intn(40)) * time.Minute
+	return Persona{
+		Supplier:     sup.Name,
+		PriceLevel:   0.85 + rng.Float64()*0.35,
+		ReplyAfter:   reply,
+		Jitter:       reply / 3,
+		OutOfStock:   rng.Float64() * 0.3,
+		AsksQuestion: rng.Float64() * 0.3,
+		Silent:       rng.Float64() * 0.2,
+		Style:        styles[rng.Intn(len(styles))],
+	}
+}
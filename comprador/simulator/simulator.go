@@ -0,0 +1,275 @@
+// Package simulator plays supplier replies in dry-run mode, so a quote runs
+// through to the comparison without a real WhatsApp. Each supplier answers
+// according to a Persona, on a virtual clock: a 30-minute quote window takes
+// only as long as the LLM calls.
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/agente/comprador/suppliers"
+	"github.com/user/agente/internal/claude"
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// Simulator answers messages sent through a MockSender with
+// MockSender.SimulateReply, as the supplier's persona would.
+type Simulator struct {
+	cl       *claude.Client // nil uses canned replies
+	mock     *whatsapp.MockSender
+	lookup   func(addr string) (*suppliers.Supplier, error)
+	personas []Persona
+	rng      *rand.Rand
+
+	mu   sync.Mutex
+	now  time.Time // virtual clock
+	seen int       // mock.Sent entries already answered
+}
+
+// New creates a simulator over mock; lookup finds the supplier behind a
+// recipient address. seed makes runs reproducible.
+func New(cl *claude.Client, mock *whatsapp.MockSender, lookup func(addr string) (*suppliers.Supplier, error), personas []Persona, seed int64) *Simulator {
+	return &Simulator{
+		cl:       cl,
+		mock:     mock,
+		lookup:   lookup,
+		personas: personas,
+		rng:      rand.New(rand.NewSource(seed)),
+		now:      time.Now(),
+	}
+}
+
+// Now returns the virtual time.
+func (s *Simulator) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Sleep advances the virtual clock without waiting.
+func (s *Simulator) Sleep(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+// event is a simulated reply due at a virtual offset from the send.
+type event struct {
+	at       time.Duration
+	phone    string
+	supplier string
+	text     string
+}
+
+// Play answers every message sent since the last call. Replies are delivered
+// in virtual-time order; those that would arrive after window are dropped,
+// as a real late reply would miss the comparison.
+func (s *Simulator) Play(ctx context.Context, window time.Duration) error {
+	s.mu.Lock()
+	sent := s.mock.Sent[s.seen:]
+	s.seen = len(s.mock.Sent)
+	s.mu.Unlock()
+
+	// One conversation per recipient; photos only count as context
+	byPhone := map[string][]whatsapp.SentMessage{}
+	var order []string
+	for _, m := range sent {
+		if _, ok := byPhone[m.Phone]; !ok {
+			order = append(order, m.Phone)
+		}
+		byPhone[m.Phone] = append(byPhone[m.Phone], m)
+	}
+
+	var events []event
+	for _, phone := range order {
+		sup, err := s.lookup(phone)
+		if err != nil {
+			return err
+		}
+		if sup == nil {
+			continue // the owner, or someone outside the supplier list
+		}
+		for _, m := range byPhone[phone] {
+			s.mock.SimulateReceipt(m.ID, whatsapp.StatusDelivered)
+		}
+		evs, err := s.conversation(ctx, *sup, byPhone[phone])
+		if err != nil {
+			return err
+		}
+		if len(evs) > 0 {
+			for _, m := range byPhone[phone] {
+				s.mock.SimulateReceipt(m.ID, whatsapp.StatusRead)
+			}
+		}
+		events = append(events, evs...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at < events[j].at })
+
+	start := s.Now()
+	for _, e := range events {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if e.at > window {
+			fmt.Printf("[simulação +%s] %s responderia só depois do prazo.\n", minutes(e.at), e.supplier)
+			continue
+		}
+		s.mu.Lock()
+		s.now = start.Add(e.at)
+		s.mu.Unlock()
+		fmt.Printf("[simulação +%s] %s responde:\n", minutes(e.at), e.supplier)
+		s.mock.SimulateReply(e.phone, e.text)
+	}
+	s.Sleep(window - s.Now().Sub(start))
+	return nil
+}
+
+// conversation decides how sup answers the messages and writes the replies.
+func (s *Simulator) conversation(ctx context.Context, sup suppliers.Supplier, msgs []whatsapp.SentMessage) ([]event, error) {
+	p := s.persona(sup)
+	if s.rng.Float64() < p.Silent {
+		fmt.Printf("[simulação] %s não vai responder.\n", sup.Name)
+		return nil, nil
+	}
+
+	delay := p.ReplyAfter
+	if p.Jitter > 0 {
+		delay += time.Duration(s.rng.Int63n(int64(2*p.Jitter))) - p.Jitter
+	}
+	delay = max(delay, time.Minute)
+	asks := s.rng.Float64() < p.AsksQuestion
+	missing := s.rng.Float64() < p.OutOfStock
+
+	var request []string
+	for _, m := range msgs {
+		if m.MediaPath != "" {
+			request = append(request, "[foto do item] "+m.Message)
+		} else {
+			request = append(request, m.Message)
+		}
+	}
+	replies, err := s.write(ctx, sup, p, strings.Join(request, "\n"), asks, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []event
+	for i, text := range replies {
+		// A question comes first; the quote follows a while later
+		at := delay + time.Duration(i)*max(delay/2, 2*time.Minute)
+		out = append(out, event{at: at, phone: msgs[0].Phone, supplier: sup.Name, text: text})
+	}
+	return out, nil
+}
+
+// persona returns the persona configured for sup, or a random one that is
+// then kept for the rest of the run.
+func (s *Simulator) persona(sup suppliers.Supplier) Persona {
+	for _, p := range s.personas {
+		if p.matches(sup) {
+			if p.PriceLevel == 0 {
+				p.PriceLevel = 1
+			}
+			return p
+		}
+	}
+	p := Random(sup, s.rng)
+	s.personas = append(s.personas, p)
+	return p
+}
+
+// write has the LLM play the supplier; without it (or if it fails) a canned
+// reply is used. It returns the question, if any, followed by the quote.
+func (s *Simulator) write(ctx context.Context, sup suppliers.Supplier, p Persona, request string, asks, missing bool) ([]string, error) {
+	if s.cl != nil {
+		replies, err := s.writeLLM(ctx, sup, p, request, asks, missing)
+		if err == nil && len(replies) > 0 {
+			return replies, nil
+		}
+		if err != nil {
+			fmt.Printf("[simulação] resposta de %s sem LLM: %v\n", sup.Name, err)
+		}
+	}
+
+	var out []string
+	if asks {
+		out = append(out, "Boa tarde! Tem preferência de marca?")
+	}
+	total := (50 + s.rng.Float64()*450) * p.PriceLevel
+	quote := fmt.Sprintf("Olá! Conseguimos atender. Total do pedido: R$ %.2f, entrega em %d dia(s).", total, 1+s.rng.Intn(5))
+	if missing {
+		quote += " Um dos itens está em falta no momento."
+	}
+	return append(out, quote), nil
+}
+
+func (s *Simulator) writeLLM(ctx context.Context, sup suppliers.Supplier, p Persona, request string, asks, missing bool) ([]string, error) {
+	tools := []claude.ToolDef{
+		{
+			Name:        "reply",
+			Description: "Mensagens de WhatsApp que o fornecedor envia, em ordem",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"question": map[string]any{"type": "string", "description": "Pergunta antes de cotar, se houver"},
+					"quote":    map[string]any{"type": "string", "description": "Resposta com preços unitários em R$ e prazo de entrega"},
+				},
+				"required": []string{"quote"},
+			},
+		},
+	}
+
+	var rules []string
+	rules = append(rules, fmt.Sprintf("Seus preços ficam em %.0f%% do preço de mercado em Campo Grande/MS.", p.PriceLevel*100))
+	if p.Style != "" {
+		rules = append(rules, "Estilo: "+p.Style+".")
+	}
+	if asks {
+		rules = append(rules, "Faça antes uma pergunta curta e pertinente (marca, medida, entrega) no campo question; a cotação vem depois, assumindo a resposta mais comum.")
+	}
+	if missing {
+		rules = append(rules, "Um dos itens está em falta: diga qual e cote os demais.")
+	}
+	prompt := fmt.Sprintf("Você é atendente da loja %q. Responda à mensagem de cotação abaixo.\n%s\n\nMensagem recebida:\n%s",
+		sup.Name, strings.Join(rules, "\n"), request)
+
+	var question, quote string
+	_, err := s.cl.ChatWithTools(ctx, claude.ChatRequest{
+		System: "Você simula fornecedores locais respondendo pedidos de cotação pelo WhatsApp. Use preços realistas.",
+		User:   prompt,
+		Tools:  tools,
+	}, func(name string, input json.RawMessage) (string, error) {
+		var r struct {
+			Question string `json:"question"`
+			Quote    string `json:"quote"`
+		}
+		if err := json.Unmarshal(input, &r); err != nil {
+			return "", err
+		}
+		question, quote = r.Question, r.Quote
+		return "ok", nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if asks && strings.TrimSpace(question) != "" {
+		out = append(out, question)
+	}
+	if strings.TrimSpace(quote) != "" {
+		out = append(out, quote)
+	}
+	return out, nil
+}
+
+func minutes(d time.Duration) string {
+	return fmt.Sprintf("%.0fmin", d.Minutes())
+}
@@ -0,0 +1,224 @@
+package comprador
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/agente/comprador/messages"
+	"github.com/user/agente/comprador/suppliers"
+)
+
+// FindSupplier resolves what the user typed — an ID, a name or a unique part
+// of one, accents optional — to a supplier, active or not.
+func (a *Agent) FindSupplier(ref string) (*suppliers.Supplier, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("informe o fornecedor (ID ou nome)")
+	}
+	all, err := a.supStore.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	fold := func(s string) string { return foldAccents(strings.ToLower(s)) }
+	var matches []suppliers.Supplier
+	for _, s := range all {
+		if s.ID == ref || fold(s.Name) == fold(ref) {
+			return &s, nil
+		}
+		if strings.Contains(fold(s.Name), fold(ref)) || strings.HasPrefix(s.ID, ref) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("fornecedor %q não encontrado", ref)
+	case 1:
+		return &matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, s := range matches {
+		names[i] = s.Name
+	}
+	return nil, fmt.Errorf("%q corresponde a %d fornecedores (%s); seja mais específico", ref, len(matches), strings.Join(names, ", "))
+}
+
+// UpdateSupplier validates and saves an edited supplier.
+func (a *Agent) UpdateSupplier(sup suppliers.Supplier) error {
+	if err := sup.Validate(); err != nil {
+		return err
+	}
+	return a.supStore.Update(sup)
+}
+
+// SetSupplierActive activates or deactivates a supplier. A supplier on the
+// do-not-contact list stays inactive until it is taken off the list.
+func (a *Agent) SetSupplierActive(ref string, active bool) (*suppliers.Supplier, error) {
+	sup, err := a.FindSupplier(ref)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		blocked, err := a.dnc.Contains(sup.Address())
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, fmt.Errorf("%s está na lista de não contactar; use 'comprador dnc remove'", sup.Name)
+		}
+	}
+	if err := a.supStore.SetActive(sup.ID, active); err != nil {
+		return nil, err
+	}
+	sup.Active = active
+	return sup, nil
+}
+
+// RemoveSupplier removes a supplier from every list; its quotes and
+// messages are kept.
+func (a *Agent) RemoveSupplier(ref string) (*suppliers.Supplier, error) {
+	sup, err := a.FindSupplier(ref)
+	if err != nil {
+		return nil, err
+	}
+	return sup, a.supStore.Remove(sup.ID)
+}
+
+// SupplierProfile is a supplier with its delivery and quoting record.
+type SupplierProfile struct {
+	Supplier     suppliers.Supplier
+	Messages     messages.Stats
+	Quotes       int // requests it was asked to quote
+	Answered     int
+	Accepted     int
+	LastQuote    *time.Time
+	DoNotContact bool
+	Recent       []suppliers.Quote    // last answered quotes, newest first
+	Score        *suppliers.Score     // nil until the supplier is scored
+	Feedback     []suppliers.Feedback // owner's ratings, newest first
+	Place        *Place               // the owner's default place, for the distance; nil when none
+}
+
+// Profile returns the record of a supplier (ID or name).
+func (a *Agent) Profile(ref string) (*SupplierProfile, error) {
+	sup, err := a.FindSupplier(ref)
+	if err != nil {
+		return nil, err
+	}
+	p := &SupplierProfile{Supplier: *sup}
+
+	stats, err := a.msgStore.SupplierStats()
+	if err != nil {
+		return nil, err
+	}
+	p.Messages = stats[sup.ID]
+
+	quotes, err := a.qStore.BySupplier(sup.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Quotes = len(quotes)
+	for _, q := range quotes {
+		if p.LastQuote == nil {
+			t := q.CreatedAt
+			p.LastQuote = &t
+		}
+		if q.Response == "" {
+			continue
+		}
+		p.Answered++
+		if q.Status == "accepted" {
+			p.Accepted++
+		}
+		if len(p.Recent) < 5 {
+			p.Recent = append(p.Recent, q)
+		}
+	}
+
+	if p.DoNotContact, err = a.dnc.Contains(sup.Address()); err != nil {
+		return nil, err
+	}
+	if p.Score, err = a.scores.Get(sup.ID); err != nil {
+		return nil, err
+	}
+	if p.Feedback, err = a.feedback.BySupplier(sup.ID); err != nil {
+		return nil, err
+	}
+	if p.Place, err = a.places.Default(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ImportSuppliers plans importing recs and, unless dryRun, saves the plan.
+// Suppliers on the do-not-contact list are imported inactive.
+func (a *Agent) ImportSuppliers(recs []suppliers.Record, opts suppliers.ImportOptions, dryRun bool) ([]suppliers.ImportItem, error) {
+	opts.DNC = a.dnc
+	items, err := a.supStore.PlanImport(recs, opts)
+	if err != nil || dryRun {
+		return items, err
+	}
+	return items, a.supStore.ApplyImport(items)
+}
+
+// ExportSuppliers returns the suppliers to write to a file: the active ones,
+// or all but the removed ones.
+func (a *Agent) ExportSuppliers(all bool) ([]suppliers.Record, error) {
+	list := a.supStore.List
+	if all {
+		list = a.supStore.ListAll
+	}
+	sups, err := list()
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]suppliers.Record, len(sups))
+	for i, s := range sups {
+		recs[i] = suppliers.RecordOf(s)
+	}
+	return recs, nil
+}
+
+// Duplicates finds suppliers that look like the same contact: same phone, or
+// similar names in the same city. Each group starts with the supplier to
+// keep, the one asked for the most quotes.
+func (a *Agent) Duplicates() ([]suppliers.Duplicates, error) {
+	all, err := a.supStore.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	groups := suppliers.FindDuplicates(all)
+	quotes := make(map[string]int)
+	for _, g := range groups {
+		for _, s := range g.Suppliers {
+			qs, err := a.qStore.BySupplier(s.ID)
+			if err != nil {
+				return nil, err
+			}
+			quotes[s.ID] = len(qs)
+		}
+		sort.SliceStable(g.Suppliers, func(i, j int) bool {
+			si, sj := g.Suppliers[i], g.Suppliers[j]
+			if quotes[si.ID] != quotes[sj.ID] {
+				return quotes[si.ID] > quotes[sj.ID]
+			}
+			return si.Active && !sj.Active
+		})
+	}
+	return groups, nil
+}
+
+// MergeSuppliers folds others into primary (see suppliers.Merged) and moves
+// their quotes, messages and purchases to it. It returns the merged supplier.
+func (a *Agent) MergeSuppliers(primary suppliers.Supplier, others []suppliers.Supplier) (suppliers.Supplier, error) {
+	merged := suppliers.Merged(primary, others)
+	if err := merged.Validate(); err != nil {
+		return merged, err
+	}
+	ids := make([]string, len(others))
+	for i, s := range others {
+		ids[i] = s.ID
+	}
+	return merged, a.supStore.Merge(merged, ids)
+}
@@ -0,0 +1,476 @@
+package suppliers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Category is a node of the taxonomy suppliers and items are classified in,
+// e.g. "frutas" under "hortifruti" under "alimentos".
+type Category struct {
+	Slug        string // identifier stored on suppliers, e.g. "carnes_acougue"
+	Name        string // e.g. "Carnes e açougue"
+	Parent      string // slug of the parent category; empty at the top
+	Description string
+	Synonyms    []string // other names, also used to recognise items
+}
+
+// ErrUnknownCategory is returned for a category that is not in the taxonomy.
+var ErrUnknownCategory = errors.New("categoria desconhecida")
+
+var slugRe = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// defaultCategories is the taxonomy a new database starts with.
+var defaultCategories = []Category{
+	{Slug: "alimentos", Name: "Alimentos", Description: "Comida e bebida para casa"},
+	{Slug: "supermercado_atacado", Name: "Supermercado e atacado", Parent: "alimentos",
+		Description: "Mercados e atacarejos: mercearia, bebidas, limpeza e higiene",
+		Synonyms:    []string{"mercado", "atacarejo", "arroz", "feijão", "açúcar", "café", "leite", "macarrão", "óleo de soja", "farinha"}},
+	{Slug: "hortifruti", Name: "Hortifrúti", Parent: "alimentos", Description: "Frutas, verduras e legumes frescos",
+		Synonyms: []string{"sacolão", "feira", "legumes", "batata", "cebola", "tomate", "cenoura", "mandioca"}},
+	{Slug: "frutas", Name: "Frutas", Parent: "hortifruti",
+		Synonyms: []string{"fruta", "banana", "maçã", "laranja", "mamão", "melancia", "uva", "abacaxi", "limão"}},
+	{Slug: "verduras", Name: "Verduras", Parent: "hortifruti",
+		Synonyms: []string{"verdura", "alface", "couve", "rúcula", "cheiro verde", "agrião", "repolho"}},
+	{Slug: "carnes_acougue", Name: "Carnes e açougue", Parent: "alimentos", Description: "Açougues e casas de carne",
+		Synonyms: []string{"carne", "açougue", "picanha", "costela", "alcatra", "patinho", "frango", "linguiça", "porco", "fraldinha"}},
+	{Slug: "churrasco", Name: "Churrasco", Parent: "carnes_acougue", Description: "Cortes para churrasco e acessórios",
+		Synonyms: []string{"carvão", "espeto", "sal grosso", "churrasqueira"}},
+	{Slug: "limpeza", Name: "Limpeza e higiene",
+		Synonyms: []string{"detergente", "sabão", "desinfetante", "água sanitária", "papel higiênico", "vassoura", "amaciante"}},
+	{Slug: "construcao", Name: "Construção e reforma"},
+	{Slug: "materiais_construcao", Name: "Materiais de construção", Parent: "construcao",
+		Description: "Material básico e acabamento",
+		Synonyms:    []string{"cimento", "areia", "brita", "tijolo", "bloco", "argamassa", "cal", "telha", "piso", "tinta", "rejunte"}},
+	{Slug: "ferramentas_ferragens", Name: "Ferramentas e ferragens", Parent: "construcao",
+		Synonyms: []string{"ferragens", "ferramenta", "parafuso", "prego", "martelo", "furadeira", "chave", "serra", "bucha", "cadeado"}},
+	{Slug: "eletrica_hidraulica", Name: "Elétrica e hidráulica", Parent: "construcao",
+		Synonyms: []string{"fio", "disjuntor", "tomada", "interruptor", "lâmpada", "cano", "tubo", "registro", "torneira", "caixa d'água"}},
+	{Slug: "veiculos", Name: "Veículos"},
+	{Slug: "autopecas", Name: "Autopeças", Parent: "veiculos",
+		Synonyms: []string{"peça", "pneu", "óleo de motor", "filtro", "pastilha de freio", "bateria", "amortecedor", "vela"}},
+	{Slug: "manutencao_veiculo", Name: "Manutenção de veículos", Parent: "veiculos", Description: "Oficinas e serviços",
+		Synonyms: []string{"oficina", "mecânico", "revisão", "troca de óleo", "alinhamento", "balanceamento", "funilaria"}},
+	{Slug: "eletro", Name: "Eletro e eletrônicos"},
+	{Slug: "eletrodomesticos", Name: "Eletrodomésticos", Parent: "eletro",
+		Synonyms: []string{"geladeira", "fogão", "máquina de lavar", "micro-ondas", "ventilador", "ar-condicionado", "liquidificador"}},
+	{Slug: "eletronicos", Name: "Eletrônicos", Parent: "eletro",
+		Synonyms: []string{"celular", "notebook", "televisão", "tv", "cabo hdmi", "carregador", "fone", "roteador"}},
+}
+
+// CategoryStore persists the taxonomy.
+type CategoryStore struct {
+	db *sql.DB
+}
+
+// NewCategoryStore creates a CategoryStore.
+func NewCategoryStore(db *sql.DB) *CategoryStore {
+	return &CategoryStore{db: db}
+}
+
+// Load returns the taxonomy. An empty one (a new database, or one from
+// before categories were managed) is first filled with defaultCategories
+// and the categories suppliers already have.
+func (cs *CategoryStore) Load() (*Taxonomy, error) {
+	cats, err := cs.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(cats) == 0 {
+		if err := cs.seed(); err != nil {
+			return nil, fmt.Errorf("criar categorias: %w", err)
+		}
+		if cats, err = cs.list(); err != nil {
+			return nil, err
+		}
+	}
+	return newTaxonomy(cats), nil
+}
+
+func (cs *CategoryStore) list() ([]Category, error) {
+	rows, err := cs.db.Query(`SELECT slug, name, parent, description, synonyms FROM categories ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Category
+	for rows.Next() {
+		var c Category
+		var syn string
+		if err := rows.Scan(&c.Slug, &c.Name, &c.Parent, &c.Description, &syn); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(syn), &c.Synonyms)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (cs *CategoryStore) seed() error {
+	cats := append([]Category(nil), defaultCategories...)
+	used, err := categoryCounts(cs.db)
+	if err != nil {
+		return err
+	}
+	tax := newTaxonomy(cats)
+	for slug := range used {
+		if _, ok := tax.Resolve(slug); !ok && slugRe.MatchString(slug) {
+			cats = append(cats, Category{Slug: slug, Name: slug})
+		}
+	}
+	for _, c := range cats {
+		if err := cs.insert(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cs *CategoryStore) insert(c Category) error {
+	syn, _ := json.Marshal(c.Synonyms)
+	_, err := cs.db.Exec(
+		`INSERT INTO categories (slug, name, parent, description, synonyms) VALUES (?, ?, ?, ?, ?)`,
+		c.Slug, c.Name, c.Parent, c.Description, string(syn),
+	)
+	return err
+}
+
+// Add creates a category. The slug must be new and lower case ("a-z",
+// digits and "_"), the parent must exist, and neither the name nor a
+// synonym may already name another category.
+func (cs *CategoryStore) Add(c Category) error {
+	tax, err := cs.Load()
+	if err != nil {
+		return err
+	}
+	if tax.Get(c.Slug) != nil {
+		return fmt.Errorf("categoria %s já existe", c.Slug)
+	}
+	if err := tax.check(c); err != nil {
+		return err
+	}
+	return cs.insert(c)
+}
+
+// Update saves every field of an existing category, with the checks of Add;
+// a category cannot be moved under itself or one of its subcategories.
+func (cs *CategoryStore) Update(c Category) error {
+	tax, err := cs.Load()
+	if err != nil {
+		return err
+	}
+	if tax.Get(c.Slug) == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownCategory, c.Slug)
+	}
+	for p := c.Parent; p != ""; p = tax.parentOf(p) {
+		if p == c.Slug {
+			return fmt.Errorf("%s não pode ficar dentro de si mesma", c.Slug)
+		}
+	}
+	if err := tax.check(c); err != nil {
+		return err
+	}
+	syn, _ := json.Marshal(c.Synonyms)
+	_, err = cs.db.Exec(
+		`UPDATE categories SET name = ?, parent = ?, description = ?, synonyms = ? WHERE slug = ?`,
+		c.Name, c.Parent, c.Description, string(syn), c.Slug,
+	)
+	return err
+}
+
+// Remove deletes a category that has no subcategories and no suppliers.
+func (cs *CategoryStore) Remove(slug string) error {
+	tax, err := cs.Load()
+	if err != nil {
+		return err
+	}
+	if tax.Get(slug) == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownCategory, slug)
+	}
+	if children := tax.Children(slug); len(children) > 0 {
+		return fmt.Errorf("%s tem subcategorias: %s", slug, strings.Join(children, ", "))
+	}
+	counts, err := categoryCounts(cs.db)
+	if err != nil {
+		return err
+	}
+	if n := counts[slug]; n > 0 {
+		return fmt.Errorf("%s é usada por %d fornecedor(es); troque a categoria deles antes", slug, n)
+	}
+	if _, err := cs.db.Exec(`DELETE FROM categories WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	_, err = cs.db.Exec(`DELETE FROM item_categories WHERE EXISTS (SELECT 1 FROM json_each(categories) WHERE value = ?)`, slug)
+	return err
+}
+
+// Counts returns how many suppliers not removed have each category.
+func (cs *CategoryStore) Counts() (map[string]int, error) {
+	return categoryCounts(cs.db)
+}
+
+func categoryCounts(db *sql.DB) (map[string]int, error) {
+	rows, err := db.Query(
+		`SELECT c.value, COUNT(*) FROM suppliers s, json_each(s.categories) c
+		 WHERE s.deleted_at IS NULL GROUP BY c.value`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]int)
+	for rows.Next() {
+		var slug string
+		var n int
+		if err := rows.Scan(&slug, &n); err != nil {
+			return nil, err
+		}
+		out[slug] = n
+	}
+	return out, rows.Err()
+}
+
+// Taxonomy is the loaded category tree.
+type Taxonomy struct {
+	cats   []Category
+	bySlug map[string]int
+	byKey  map[string]string // folded slug, name or synonym → slug
+}
+
+func newTaxonomy(cats []Category) *Taxonomy {
+	t := &Taxonomy{cats: cats, bySlug: make(map[string]int), byKey: make(map[string]string)}
+	for i, c := range cats {
+		t.bySlug[c.Slug] = i
+	}
+	// Slugs and names win over synonyms
+	for _, c := range cats {
+		t.byKey[categoryKey(c.Slug)] = c.Slug
+		t.byKey[categoryKey(c.Name)] = c.Slug
+	}
+	for _, c := range cats {
+		for _, s := range c.Synonyms {
+			if _, ok := t.byKey[categoryKey(s)]; !ok {
+				t.byKey[categoryKey(s)] = c.Slug
+			}
+		}
+	}
+	return t
+}
+
+// categoryKey folds a slug, name or synonym for comparison:
+// "materiais_construcao" and "Materiais de Construção" are equal.
+func categoryKey(s string) string {
+	return nameKey(strings.ReplaceAll(s, "_", " "))
+}
+
+// List returns every category, parents before their children, siblings by
+// name.
+func (t *Taxonomy) List() []Category {
+	var out []Category
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, slug := range t.Children(parent) {
+			out = append(out, *t.Get(slug))
+			walk(slug)
+		}
+	}
+	walk("")
+	return out
+}
+
+// Slugs returns every category slug.
+func (t *Taxonomy) Slugs() []string {
+	out := make([]string, len(t.cats))
+	for i, c := range t.cats {
+		out[i] = c.Slug
+	}
+	return out
+}
+
+// Get returns a category by slug, or nil.
+func (t *Taxonomy) Get(slug string) *Category {
+	i, ok := t.bySlug[slug]
+	if !ok {
+		return nil
+	}
+	return &t.cats[i]
+}
+
+// Resolve returns the slug of the category called name: its slug, name or
+// a synonym, ignoring case and accents.
+func (t *Taxonomy) Resolve(name string) (string, bool) {
+	slug, ok := t.byKey[categoryKey(name)]
+	return slug, ok
+}
+
+// ResolveAll resolves names to slugs, without repeats, failing with
+// ErrUnknownCategory on the first that is not in the taxonomy.
+func (t *Taxonomy) ResolveAll(names []string) ([]string, error) {
+	var out []string
+	for _, n := range names {
+		if strings.TrimSpace(n) == "" {
+			continue
+		}
+		slug, ok := t.Resolve(n)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q (veja 'comprador categories')", ErrUnknownCategory, n)
+		}
+		out = appendUnique(out, slug)
+	}
+	return out, nil
+}
+
+// Children returns the slugs of the direct subcategories of parent ("" for
+// the top level), by name.
+func (t *Taxonomy) Children(parent string) []string {
+	var out []Category
+	for _, c := range t.cats {
+		if c.Parent == parent || (c.Parent != "" && parent == "" && t.Get(c.Parent) == nil) {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	slugs := make([]string, len(out))
+	for i, c := range out {
+		slugs[i] = c.Slug
+	}
+	return slugs
+}
+
+// Ancestors returns the parent of slug, its parent, and so on up to the top.
+func (t *Taxonomy) Ancestors(slug string) []string {
+	var out []string
+	for p := t.parentOf(slug); p != "" && len(out) < len(t.cats); p = t.parentOf(p) {
+		out = append(out, p)
+	}
+	return out
+}
+
+// Top returns the top-level category slug falls under (slug itself at the
+// top).
+func (t *Taxonomy) Top(slug string) string {
+	if a := t.Ancestors(slug); len(a) > 0 {
+		return a[len(a)-1]
+	}
+	return slug
+}
+
+// Path returns the names from the top down to slug: "Alimentos > Hortifrúti
+// > Frutas".
+func (t *Taxonomy) Path(slug string) string {
+	names := []string{t.name(slug)}
+	for _, a := range t.Ancestors(slug) {
+		names = append([]string{t.name(a)}, names...)
+	}
+	return strings.Join(names, " > ")
+}
+
+func (t *Taxonomy) name(slug string) string {
+	if c := t.Get(slug); c != nil {
+		return c.Name
+	}
+	return slug
+}
+
+func (t *Taxonomy) parentOf(slug string) string {
+	if c := t.Get(slug); c != nil {
+		return c.Parent
+	}
+	return ""
+}
+
+// check validates c against the other categories of t.
+func (t *Taxonomy) check(c Category) error {
+	if !slugRe.MatchString(c.Slug) {
+		return fmt.Errorf("identificador %q inválido: use letras minúsculas sem acento, números e _", c.Slug)
+	}
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("%s: informe o nome", c.Slug)
+	}
+	if c.Parent != "" && t.Get(c.Parent) == nil {
+		return fmt.Errorf("%w: %s (categoria mãe)", ErrUnknownCategory, c.Parent)
+	}
+	for _, n := range append([]string{c.Name}, c.Synonyms...) {
+		if other, ok := t.Resolve(n); ok && other != c.Slug {
+			return fmt.Errorf("%q já identifica a categoria %s", n, other)
+		}
+	}
+	return nil
+}
+
+// Classify puts item in the categories whose name or slug shares a word
+// with it, or whose synonym it contains: "carne moída" falls in
+// "carnes_acougue", "saco de cimento" in "materiais_construcao" (see
+// sharesWord). A category is left out when one of its subcategories also
+// matches.
+func (t *Taxonomy) Classify(item string) []string {
+	words := strings.Fields(nameKey(item))
+	var matched []string
+	for _, c := range t.cats {
+		if t.matches(c, words) {
+			matched = append(matched, c.Slug)
+		}
+	}
+	var out []string
+	for _, slug := range matched {
+		specific := true
+		for _, other := range matched {
+			for _, a := range t.Ancestors(other) {
+				if a == slug {
+					specific = false
+				}
+			}
+		}
+		if specific {
+			out = append(out, slug)
+		}
+	}
+	return out
+}
+
+func (t *Taxonomy) matches(c Category, words []string) bool {
+	for _, term := range []string{c.Slug, c.Name} {
+		for _, w := range strings.Fields(categoryKey(term)) {
+			if sharesWord(words, w) {
+				return true
+			}
+		}
+	}
+	for _, s := range c.Synonyms {
+		all := true
+		for _, w := range strings.Fields(categoryKey(s)) {
+			if !sharesWord(words, w) {
+				all = false
+			}
+		}
+		if all && categoryKey(s) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesWord reports whether w is one of words, give or take a plural
+// ending: "carne" and "carnes" match, "saco" and "sacolao" do not.
+func sharesWord(words []string, w string) bool {
+	if len(w) < 3 {
+		return false
+	}
+	for _, x := range words {
+		short, long := x, w
+		if len(short) > len(long) {
+			short, long = long, short
+		}
+		if len(short) >= 3 && strings.HasPrefix(long, short) && len(long)-len(short) <= 2 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package suppliers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/agente/internal/claude"
+)
+
+// itemCategories returns the cached categories of an item, or nil.
+func (s *Store) itemCategories(item string) ([]string, error) {
+	var raw string
+	err := s.db.QueryRow(`SELECT categories FROM item_categories WHERE item = ?`, itemKey(item)).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cats []string
+	_ = json.Unmarshal([]byte(raw), &cats)
+	return cats, nil
+}
+
+// cacheItemCategories remembers the categories of an item.
+func (s *Store) cacheItemCategories(item string, cats []string) error {
+	raw, _ := json.Marshal(cats)
+	_, err := s.db.Exec(
+		`INSERT INTO item_categories (item, categories, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT(item) DO UPDATE SET categories = excluded.categories, created_at = excluded.created_at`,
+		itemKey(item), string(raw), time.Now(),
+	)
+	return err
+}
+
+// itemKey is the cache key of an item name, so "Saco de Cimento" and "saco
+// cimento" share an entry.
+func itemKey(item string) string {
+	return nameKey(item)
+}
+
+// Classify maps each item to the categories it falls in, loading the
+// taxonomy.
+func (m *Matcher) Classify(ctx context.Context, items []string) (map[string][]string, error) {
+	tax, err := m.categories.Load()
+	if err != nil {
+		return nil, err
+	}
+	return m.classify(ctx, items, tax)
+}
+
+// classify maps each item to the categories of tax it falls in. Items seen
+// before come from the cache; the rest are classified by the model and
+// cached, or by Taxonomy.Classify when the model is unavailable. Cached
+// categories no longer in tax are dropped, and an item left without any is
+// classified again, as a new category may now fit it.
+func (m *Matcher) classify(ctx context.Context, items []string, tax *Taxonomy) (map[string][]string, error) {
+	known := make(map[string]bool)
+	for _, c := range tax.Slugs() {
+		known[c] = true
+	}
+
+	out := make(map[string][]string, len(items))
+	var misses []string
+	for _, item := range items {
+		cached, err := m.store.itemCategories(item)
+		if err != nil {
+			return nil, err
+		}
+		var cats []string
+		for _, c := range cached {
+			if known[c] {
+				cats = append(cats, c)
+			}
+		}
+		if len(cats) == 0 {
+			misses = append(misses, item)
+			continue
+		}
+		out[item] = cats
+	}
+	if len(misses) == 0 {
+		return out, nil
+	}
+
+	classified, err := m.classifyLLM(ctx, misses, tax)
+	if err != nil {
+		for _, item := range misses {
+			out[item] = tax.Classify(item)
+		}
+		return out, nil
+	}
+	for _, item := range misses {
+		var cats []string
+		for _, c := range classified[item] {
+			if known[c] {
+				cats = appendUnique(cats, c)
+			}
+		}
+		out[item] = cats
+		if len(cats) > 0 {
+			if err := m.store.cacheItemCategories(item, cats); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// classifyLLM asks the model which categories of tax each item is sold
+// under.
+func (m *Matcher) classifyLLM(ctx context.Context, items []string, tax *Taxonomy) (map[string][]string, error) {
+	tools := []claude.ToolDef{
+		{
+			Name:        "classify_items",
+			Description: "Returns the supplier categories each item can be bought from",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"items": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"item": map[string]any{"type": "string"},
+								"categories": map[string]any{
+									"type":  "array",
+									"items": map[string]any{"type": "string", "enum": tax.Slugs()},
+								},
+							},
+							"required": []string{"item", "categories"},
+						},
+					},
+				},
+				"required": []string{"items"},
+			},
+		},
+	}
+	itemsJSON, _ := json.Marshal(items)
+	var cats strings.Builder
+	for _, c := range tax.List() {
+		fmt.Fprintf(&cats, "- %s: %s", c.Slug, tax.Path(c.Slug))
+		if c.Description != "" {
+			fmt.Fprintf(&cats, " — %s", c.Description)
+		}
+		if len(c.Synonyms) > 0 {
+			fmt.Fprintf(&cats, " (ex: %s)", strings.Join(c.Synonyms, ", "))
+		}
+		cats.WriteString("\n")
+	}
+	prompt := fmt.Sprintf(
+		"Classifique cada item nas categorias de fornecedor em que ele é vendido.\n\n"+
+			"Itens: %s\n\nCategorias:\n%s\n"+
+			"Use a ferramenta classify_items. Escolha a categoria mais específica que sirva; "+
+			"um item pode ter mais de uma. Deixe a lista vazia se nenhuma servir.",
+		itemsJSON, cats.String(),
+	)
+
+	out := make(map[string][]string)
+	_, err := m.claude.ChatWithTools(ctx, claude.ChatRequest{
+		System: "Você é um especialista em compras locais.",
+		User:   prompt,
+		Tools:  tools,
+	}, func(name string, input json.RawMessage) (string, error) {
+		if name != "classify_items" {
+			return "", fmt.Errorf("unknown tool: %s", name)
+		}
+		var result struct {
+			Items []struct {
+				Item       string   `json:"item"`
+				Categories []string `json:"categories"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(input, &result); err != nil {
+			return "", err
+		}
+		// Answers are matched back by key, as the model may retype the names
+		byKey := make(map[string]string, len(items))
+		for _, it := range items {
+			byKey[itemKey(it)] = it
+		}
+		for _, r := range result.Items {
+			if it, ok := byKey[itemKey(r.Item)]; ok {
+				out[it] = r.Categories
+			}
+		}
+		return "ok", nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude classify: %w", err)
+	}
+	return out, nil
+}
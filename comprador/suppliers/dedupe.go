@@ -0,0 +1,308 @@
+package suppliers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reasons two suppliers are taken as duplicates.
+const (
+	SamePhone   = "mesmo telefone"
+	SimilarName = "nome parecido"
+)
+
+// nameSimilarity is how alike two names (see nameKey) must be to be flagged,
+// as 1 - edit distance / length: "Cimento e Ferro" and "Cimento e Ferro
+// Ltda" are, "Magazine Luiza - Centro" and "Magazine Luiza - São Francisco"
+// are not.
+const nameSimilarity = 0.9
+
+// Duplicates is a set of suppliers that look like the same contact.
+type Duplicates struct {
+	Reasons   []string
+	Suppliers []Supplier
+}
+
+// FindDuplicates groups suppliers sharing a phone, or with similar names in
+// the same city. Suppliers are compared transitively, so a group may hold
+// more than two.
+func FindDuplicates(all []Supplier) []Duplicates {
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	reasons := make(map[int]map[string]bool)
+	link := func(i, j int, reason string) {
+		ri, rj := find(i), find(j)
+		if ri != rj {
+			parent[rj] = ri
+			for r := range reasons[rj] {
+				addReason(reasons, ri, r)
+			}
+		}
+		addReason(reasons, ri, reason)
+	}
+
+	keys := make([]string, len(all))
+	for i, s := range all {
+		keys[i] = nameKey(s.Name)
+	}
+	for i := range all {
+		for j := i + 1; j < len(all); j++ {
+			a, b := all[i], all[j]
+			if p := phoneKey(a.Phone); p != "" && p == phoneKey(b.Phone) {
+				link(i, j, SamePhone)
+			}
+			if nameKey(a.City) == nameKey(b.City) && similarity(keys[i], keys[j]) >= nameSimilarity {
+				link(i, j, SimilarName)
+			}
+		}
+	}
+
+	groups := make(map[int][]Supplier)
+	var roots []int
+	for i, s := range all {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], s)
+	}
+	var out []Duplicates
+	for _, r := range roots {
+		if len(groups[r]) < 2 {
+			continue
+		}
+		d := Duplicates{Suppliers: groups[r]}
+		for reason := range reasons[r] {
+			d.Reasons = append(d.Reasons, reason)
+		}
+		sort.Strings(d.Reasons)
+		out = append(out, d)
+	}
+	return out
+}
+
+func addReason(reasons map[int]map[string]bool, i int, r string) {
+	if reasons[i] == nil {
+		reasons[i] = make(map[string]bool)
+	}
+	reasons[i][r] = true
+}
+
+// Merged is the supplier a group becomes, keeping primary's ID. Names that
+// differ only after " - " ("Fort Atacadista - Cafezais", "Fort Atacadista -
+// Rua da Divisão") become branches of the common name; categories are
+// joined, and fields primary lacks are taken from the others.
+func Merged(primary Supplier, others []Supplier) Supplier {
+	m := primary
+	m.Categories = append([]string(nil), primary.Categories...)
+	m.Branches = append([]string(nil), primary.Branches...)
+
+	base, branch := splitBranch(primary.Name)
+	sameBase := true
+	for _, o := range others {
+		if b, _ := splitBranch(o.Name); !strings.EqualFold(b, base) {
+			sameBase = false
+		}
+	}
+	if sameBase {
+		m.Name = base
+		if branch != "" {
+			m.Branches = appendUnique(m.Branches, branch)
+		}
+	}
+	for _, s := range others {
+		if _, branch := splitBranch(s.Name); sameBase && branch != "" {
+			m.Branches = appendUnique(m.Branches, branch)
+		}
+		for _, b := range s.Branches {
+			m.Branches = appendUnique(m.Branches, b)
+		}
+		for _, c := range s.Categories {
+			m.Categories = appendUnique(m.Categories, c)
+		}
+		fill := func(dst *string, v string) {
+			if *dst == "" {
+				*dst = v
+			}
+		}
+		fill(&m.Phone, s.Phone)
+		fill(&m.City, s.City)
+		fill(&m.Email, s.Email)
+		fill(&m.TelegramID, s.TelegramID)
+		fill(&m.Hours, s.Hours)
+		fill(&m.Timezone, s.Timezone)
+		m.Active = m.Active || s.Active
+	}
+	m.Phone = phoneKey(m.Phone)
+	return m
+}
+
+// Merge saves merged and folds the suppliers in dupIDs into it: their
+// quotes, messages, queued messages, purchases and ratings move to
+// merged.ID, and they are removed (with merged_into set). When both had a
+// quote for the same request, the answered one is kept.
+func (s *Store) Merge(merged Supplier, dupIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, id := range dupIDs {
+		if id == merged.ID {
+			continue
+		}
+		if err := mergeQuotes(tx, merged.ID, id); err != nil {
+			return err
+		}
+		for _, q := range []string{
+			`UPDATE messages SET supplier_id = ? WHERE supplier_id = ?`,
+			`UPDATE outbox SET supplier_id = ? WHERE supplier_id = ?`,
+			`UPDATE supplier_feedback SET supplier_id = ? WHERE supplier_id = ?`,
+		} {
+			if _, err := tx.Exec(q, merged.ID, id); err != nil {
+				return fmt.Errorf("merge %s: %w", id, err)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE purchase_memory SET supplier_id = ?, chosen_supplier = ? WHERE supplier_id = ?`,
+			merged.ID, merged.Name, id); err != nil {
+			return fmt.Errorf("merge %s: %w", id, err)
+		}
+		if _, err := tx.Exec(`UPDATE suppliers SET active = 0, deleted_at = ?, merged_into = ? WHERE id = ?`,
+			now, merged.ID, id); err != nil {
+			return fmt.Errorf("merge %s: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM supplier_scores WHERE supplier_id = ?`, id); err != nil {
+			return fmt.Errorf("merge %s: %w", id, err)
+		}
+	}
+	if err := update(tx, merged); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE purchase_memory SET chosen_supplier = ? WHERE supplier_id = ?`, merged.Name, merged.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mergeQuotes moves the quotes of supplier from to supplier to. A request
+// both were asked about keeps a single quote: the answered one, or to's.
+func mergeQuotes(tx *sql.Tx, to, from string) error {
+	rows, err := tx.Query(
+		`SELECT f.id, COALESCE(f.response, ''), t.id, COALESCE(t.response, '')
+		 FROM quotes f JOIN quotes t ON t.request_id = f.request_id AND t.supplier_id = ?
+		 WHERE f.supplier_id = ?`, to, from,
+	)
+	if err != nil {
+		return err
+	}
+	var drop []string
+	for rows.Next() {
+		var fromID, fromResp, toID, toResp string
+		if err := rows.Scan(&fromID, &fromResp, &toID, &toResp); err != nil {
+			rows.Close()
+			return err
+		}
+		if toResp == "" && fromResp != "" {
+			drop = append(drop, toID)
+		} else {
+			drop = append(drop, fromID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range drop {
+		if _, err := tx.Exec(`DELETE FROM quotes WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`UPDATE quotes SET supplier_id = ? WHERE supplier_id = ?`, to, from)
+	return err
+}
+
+// splitBranch splits "Chain - Branch" into its parts.
+func splitBranch(name string) (base, branch string) {
+	if i := strings.LastIndex(name, " - "); i > 0 {
+		return strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+3:])
+	}
+	return strings.TrimSpace(name), ""
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return list
+		}
+	}
+	return append(list, v)
+}
+
+// nameNoise are words left out when comparing names: company-type suffixes
+// and connectives.
+var nameNoise = map[string]bool{
+	"ltda": true, "me": true, "epp": true, "eireli": true, "sa": true, "cia": true,
+	"de": true, "da": true, "do": true, "das": true, "dos": true, "e": true,
+}
+
+// nameKey is name lower-cased, without accents, punctuation or nameNoise.
+func nameKey(name string) string {
+	name = strings.NewReplacer(
+		"á", "a", "à", "a", "â", "a", "ã", "a",
+		"é", "e", "ê", "e",
+		"í", "i",
+		"ó", "o", "ô", "o", "õ", "o",
+		"ú", "u", "ü", "u",
+		"ç", "c",
+	).Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	var kept []string
+	for _, w := range words {
+		if !nameNoise[w] {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// similarity is 1 - the edit distance between a and b over the longer
+// length; 1 means equal.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
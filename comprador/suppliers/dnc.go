@@ -0,0 +1,72 @@
+package suppliers
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/user/agente/internal/whatsapp"
+)
+
+// DNCEntry is an address that must never be messaged.
+type DNCEntry struct {
+	Address   string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// DNCStore is the do-not-contact list. It is checked before every message to
+// a supplier, so it holds even for suppliers re-registered or imported later.
+type DNCStore struct {
+	db *sql.DB
+}
+
+// NewDNCStore creates a do-not-contact store.
+func NewDNCStore(db *sql.DB) *DNCStore {
+	return &DNCStore{db: db}
+}
+
+// Add puts addr on the list; adding it again only updates the reason.
+func (s *DNCStore) Add(addr, reason string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO do_not_contact (address, reason, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT(address) DO UPDATE SET reason = excluded.reason`,
+		whatsapp.NormalizeAddress(addr), reason, time.Now(),
+	)
+	return err
+}
+
+// Remove takes addr off the list. It reports whether it was there.
+func (s *DNCStore) Remove(addr string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM do_not_contact WHERE address = ?`, whatsapp.NormalizeAddress(addr))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Contains reports whether addr is on the list.
+func (s *DNCStore) Contains(addr string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM do_not_contact WHERE address = ?`, whatsapp.NormalizeAddress(addr)).Scan(&n)
+	return n > 0, err
+}
+
+// List returns the whole list, most recent first.
+func (s *DNCStore) List() ([]DNCEntry, error) {
+	rows, err := s.db.Query(`SELECT address, reason, created_at FROM do_not_contact ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DNCEntry
+	for rows.Next() {
+		var e DNCEntry
+		if err := rows.Scan(&e.Address, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
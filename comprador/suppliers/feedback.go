@@ -0,0 +1,64 @@
+package suppliers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Feedback is the owner's rating of a supplier after buying from it.
+type Feedback struct {
+	ID         int64
+	SupplierID string
+	RequestID  string // the purchase rated; empty for a general rating
+	Rating     int    // 1-5
+	Comment    string
+	CreatedAt  time.Time
+}
+
+// FeedbackStore persists the owner's ratings; they feed the supplier score.
+type FeedbackStore struct {
+	db *sql.DB
+}
+
+// NewFeedbackStore creates a FeedbackStore.
+func NewFeedbackStore(db *sql.DB) *FeedbackStore {
+	return &FeedbackStore{db: db}
+}
+
+// Add records a rating.
+func (fs *FeedbackStore) Add(f Feedback) error {
+	if f.Rating < 1 || f.Rating > 5 {
+		return fmt.Errorf("nota %d inválida: use de 1 a 5", f.Rating)
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+	_, err := fs.db.Exec(
+		`INSERT INTO supplier_feedback (supplier_id, request_id, rating, comment, created_at) VALUES (?, NULLIF(?, ''), ?, ?, ?)`,
+		f.SupplierID, f.RequestID, f.Rating, f.Comment, f.CreatedAt,
+	)
+	return err
+}
+
+// BySupplier returns the ratings of a supplier, newest first.
+func (fs *FeedbackStore) BySupplier(supplierID string) ([]Feedback, error) {
+	rows, err := fs.db.Query(
+		`SELECT id, supplier_id, COALESCE(request_id, ''), rating, comment, created_at
+		 FROM supplier_feedback WHERE supplier_id = ? ORDER BY created_at DESC`, supplierID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Feedback
+	for rows.Next() {
+		var f Feedback
+		if err := rows.Scan(&f.ID, &f.SupplierID, &f.RequestID, &f.Rating, &f.Comment, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
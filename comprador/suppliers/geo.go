@@ -0,0 +1,275 @@
+package suppliers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean radius of the Earth in km.
+const earthRadius = 6371.0
+
+const (
+	// kmPerPoint is how many km of distance cost a supplier one rating point
+	// when suppliers are ordered for a delivery address.
+	kmPerPoint = 10.0
+	// unknownDistance is the distance assumed for a supplier without a
+	// location, so it ranks with the nearby ones rather than first or last.
+	unknownDistance = 5.0
+)
+
+// Point is a position in decimal degrees. The zero Point means the location
+// is unknown.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// IsZero reports whether the location is unknown.
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lng == 0
+}
+
+// Check fails if p is not a valid latitude and longitude.
+func (p Point) Check() error {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lng) || p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+		return fmt.Errorf("coordenadas inválidas: %s (latitude -90 a 90, longitude -180 a 180)", p)
+	}
+	return nil
+}
+
+func (p Point) String() string {
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lng, 'f', -1, 64)
+}
+
+// ParsePoint reads "lat,lng" as copied from a map app ("-20.4697, -54.6201").
+// Coordinates written with decimal commas must be separated by ";" or a
+// space ("-20,4697 -54,6201").
+func ParsePoint(s string) (Point, error) {
+	s = strings.TrimSpace(s)
+	var parts []string
+	switch {
+	case strings.Contains(s, ";"):
+		parts = strings.Split(s, ";")
+	case strings.Count(s, ",") == 1:
+		parts = strings.Split(s, ",")
+	default:
+		parts = strings.Fields(s)
+	}
+	if len(parts) != 2 {
+		return Point{}, fmt.Errorf("coordenadas %q: use latitude,longitude (ex: -20.4697,-54.6201)", s)
+	}
+	var p Point
+	for i, dst := range []*float64{&p.Lat, &p.Lng} {
+		v, err := parseDecimal(parts[i])
+		if err != nil {
+			return Point{}, fmt.Errorf("coordenadas %q: %w", s, err)
+		}
+		*dst = v
+	}
+	return p, p.Check()
+}
+
+// parseDecimal reads a number written with a decimal point or comma.
+func parseDecimal(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("número inválido %q", s)
+	}
+	return v, nil
+}
+
+// Distance returns the great-circle distance between a and b in km.
+func Distance(a, b Point) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLng := (b.Lng - a.Lng) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DistanceFrom returns how far the supplier is from origin in km, or -1 when
+// either location is unknown.
+func (s Supplier) DistanceFrom(origin Point) float64 {
+	if origin.IsZero() || s.Geo.IsZero() {
+		return -1
+	}
+	return Distance(origin, s.Geo)
+}
+
+// DeliversTo reports whether origin is within the supplier's delivery
+// radius. A supplier without a location or radius is not known to deliver.
+func (s Supplier) DeliversTo(origin Point) bool {
+	d := s.DistanceFrom(origin)
+	return d >= 0 && s.DeliveryRadius > 0 && d <= s.DeliveryRadius
+}
+
+// proximityScore orders suppliers for a purchase delivered at origin: the
+// rating, less a point per kmPerPoint km away, plus a point when the
+// supplier delivers there.
+func proximityScore(s Supplier, origin Point) float64 {
+	d := s.DistanceFrom(origin)
+	if d < 0 {
+		d = unknownDistance
+	}
+	score := s.Rating - d/kmPerPoint
+	if s.DeliversTo(origin) {
+		score++
+	}
+	return score
+}
+
+// FormatDistance writes a distance in km as "850 m" or "3,2 km"; a negative
+// distance is unknown.
+func FormatDistance(km float64) string {
+	switch {
+	case km < 0:
+		return "distância desconhecida"
+	case km < 1:
+		return fmt.Sprintf("%.0f m", km*1000)
+	}
+	return strings.Replace(fmt.Sprintf("%.1f km", km), ".", ",", 1)
+}
+
+// Gazetteer is an offline address book of coordinates, read from a CSV file
+// with the address, latitude and longitude on each line, e.g. exported from
+// a map app or an open data set of the city's streets and neighbourhoods.
+type Gazetteer struct {
+	entries []gazetteerEntry
+}
+
+type gazetteerEntry struct {
+	key   string // nameKey of the address
+	point Point
+}
+
+// ReadGazetteer reads a gazetteer. Columns are separated by ";" or ",", and
+// a first line whose coordinates are not numbers is taken as a header.
+// Coordinates in a ";"-separated file may use decimal commas.
+func ReadGazetteer(r io.Reader) (*Gazetteer, error) {
+	br := bufio.NewReader(r)
+	first, _ := br.Peek(4096)
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	cr := csv.NewReader(br)
+	if bytes.Contains(first, []byte(";")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	g := &Gazetteer{}
+	for n := 1; ; n++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ler arquivo de coordenadas: %w", err)
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("linha %d: esperado endereço, latitude e longitude", n)
+		}
+		lat, errLat := parseDecimal(row[1])
+		lng, errLng := parseDecimal(row[2])
+		if errLat != nil || errLng != nil {
+			if n == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("linha %d: coordenadas inválidas %q, %q", n, row[1], row[2])
+		}
+		p := Point{Lat: lat, Lng: lng}
+		if err := p.Check(); err != nil {
+			return nil, fmt.Errorf("linha %d: %w", n, err)
+		}
+		if key := nameKey(row[0]); key != "" {
+			g.entries = append(g.entries, gazetteerEntry{key: key, point: p})
+		}
+	}
+	return g, nil
+}
+
+// Len returns the number of addresses in the gazetteer.
+func (g *Gazetteer) Len() int {
+	return len(g.entries)
+}
+
+// Lookup returns the coordinates of an address: those of the entry with the
+// same address, ignoring case, accents and punctuation, or else of the entry
+// with the most words all found in it (a street, or a neighbourhood, named
+// in a full address with number and city).
+func (g *Gazetteer) Lookup(address string) (Point, bool) {
+	key := nameKey(address)
+	if key == "" {
+		return Point{}, false
+	}
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(key) {
+		words[w] = true
+	}
+	var best *gazetteerEntry
+	bestWords := 0
+	for i, e := range g.entries {
+		if e.key == key {
+			return e.point, true
+		}
+		ew := strings.Fields(e.key)
+		all := true
+		for _, w := range ew {
+			all = all && words[w]
+		}
+		if all && len(ew) > bestWords {
+			best, bestWords = &g.entries[i], len(ew)
+		}
+	}
+	if best == nil {
+		return Point{}, false
+	}
+	return best.point, true
+}
+
+// GeocodeResult is a supplier whose street address was looked up.
+type GeocodeResult struct {
+	Supplier Supplier
+	Found    bool
+}
+
+// Geocode looks up in g the street address of every supplier that has one
+// and, with overwrite, also of those already located, and saves the
+// coordinates found. The city is tried with the street first, so the same
+// street name in two cities can be told apart.
+func (s *Store) Geocode(g *Gazetteer, overwrite bool) ([]GeocodeResult, error) {
+	all, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var out []GeocodeResult
+	for _, sup := range all {
+		if strings.TrimSpace(sup.Street) == "" || (!sup.Geo.IsZero() && !overwrite) {
+			continue
+		}
+		p, ok := g.Lookup(sup.Street + ", " + sup.City)
+		if !ok {
+			p, ok = g.Lookup(sup.Street)
+		}
+		if ok {
+			sup.Geo = p
+			if _, err := s.db.Exec(`UPDATE suppliers SET lat = ?, lng = ? WHERE id = ?`, p.Lat, p.Lng, sup.ID); err != nil {
+				return nil, fmt.Errorf("save location: %w", err)
+			}
+		}
+		out = append(out, GeocodeResult{Supplier: sup, Found: ok})
+	}
+	return out, nil
+}
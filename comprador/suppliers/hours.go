@@ -0,0 +1,189 @@
+package suppliers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // suppliers' timezones must resolve on hosts without zoneinfo
+)
+
+// DefaultTimezone is used for suppliers without one.
+const DefaultTimezone = "America/Campo_Grande"
+
+// Hours is a weekly opening schedule, written as "seg-sex 08:00-18:00;
+// sab 08:00-12:00". A day may list several ranges ("seg 08:00-12:00,
+// 13:30-18:00"). The zero value is always open.
+type Hours struct {
+	spec string
+	days [7][]span // indexed by time.Weekday
+}
+
+type span struct{ from, to int } // minutes since midnight, to exclusive
+
+var weekdays = map[string]time.Weekday{
+	"dom": time.Sunday, "seg": time.Monday, "ter": time.Tuesday, "qua": time.Wednesday,
+	"qui": time.Thursday, "sex": time.Friday, "sab": time.Saturday,
+}
+
+// ParseHours parses an opening schedule; an empty spec is always open.
+func ParseHours(spec string) (Hours, error) {
+	h := Hours{spec: strings.TrimSpace(spec)}
+	for _, part := range strings.Split(h.spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Fields(part)
+		if len(fields) < 2 {
+			return Hours{}, fmt.Errorf("horário %q: use 'dias HH:MM-HH:MM'", part)
+		}
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return Hours{}, err
+		}
+		for _, r := range strings.Split(strings.Join(fields[1:], ""), ",") {
+			sp, err := parseSpan(r)
+			if err != nil {
+				return Hours{}, err
+			}
+			for _, d := range days {
+				h.days[d] = append(h.days[d], sp)
+			}
+		}
+	}
+	for _, d := range h.days {
+		sort.Slice(d, func(i, j int) bool { return d[i].from < d[j].from })
+	}
+	return h, nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	s = strings.NewReplacer("á", "a", "Á", "a").Replace(strings.ToLower(s))
+	var out []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		d1, ok := weekdays[from]
+		if !ok {
+			return nil, fmt.Errorf("dia %q inválido (use dom, seg, ter, qua, qui, sex, sab)", from)
+		}
+		if !isRange {
+			out = append(out, d1)
+			continue
+		}
+		d2, ok := weekdays[to]
+		if !ok {
+			return nil, fmt.Errorf("dia %q inválido (use dom, seg, ter, qua, qui, sex, sab)", to)
+		}
+		for d := d1; ; d = (d + 1) % 7 {
+			out = append(out, d)
+			if d == d2 {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func parseSpan(s string) (span, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return span{}, fmt.Errorf("intervalo %q: use HH:MM-HH:MM", s)
+	}
+	f, err := parseClock(from)
+	if err != nil {
+		return span{}, err
+	}
+	t, err := parseClock(to)
+	if err != nil {
+		return span{}, err
+	}
+	if t <= f {
+		return span{}, fmt.Errorf("intervalo %q: fim antes do início", s)
+	}
+	return span{f, t}, nil
+}
+
+// parseClock accepts "08:30", "08h30", "08h" and "8".
+func parseClock(s string) (int, error) {
+	c := strings.TrimSuffix(strings.Replace(strings.TrimSpace(s), "h", ":", 1), ":")
+	t, err := time.Parse("15:04", c)
+	if err != nil {
+		if t, err = time.Parse("15", c); err != nil {
+			return 0, fmt.Errorf("hora %q inválida", s)
+		}
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Always reports whether the schedule has no restriction.
+func (h Hours) Always() bool {
+	for _, d := range h.days {
+		if len(d) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (h Hours) String() string {
+	if h.Always() {
+		return "sempre"
+	}
+	return h.spec
+}
+
+// Open reports whether t falls within the schedule, in t's location.
+func (h Hours) Open(t time.Time) bool {
+	if h.Always() {
+		return true
+	}
+	min := t.Hour()*60 + t.Minute()
+	for _, sp := range h.days[t.Weekday()] {
+		if min >= sp.from && min < sp.to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if the schedule is open then, else when it next opens.
+func (h Hours) NextOpen(t time.Time) time.Time {
+	if h.Open(t) {
+		return t
+	}
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, t.Location())
+		for _, sp := range h.days[day.Weekday()] {
+			start := day.Add(time.Duration(sp.from) * time.Minute)
+			if start.After(t) {
+				return start
+			}
+		}
+	}
+	return t // unreachable for a schedule with at least one range
+}
+
+// Location returns the supplier's timezone, DefaultTimezone when unset or
+// unknown.
+func (s Supplier) Location() *time.Location {
+	name := s.Timezone
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	return loc
+}
+
+// NextOpen returns t if the supplier is open then, else when it next opens.
+// A schedule that does not parse is treated as always open.
+func (s Supplier) NextOpen(t time.Time) time.Time {
+	h, err := ParseHours(s.Hours)
+	if err != nil || h.Always() {
+		return t
+	}
+	return h.NextOpen(t.In(s.Location()))
+}
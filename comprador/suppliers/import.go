@@ -0,0 +1,334 @@
+package suppliers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Import actions.
+const (
+	ImportAdd       = "add"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportSkip      = "skip"
+	ImportBranch    = "branch" // another store of a supplier earlier in the file
+)
+
+// ImportOptions fill in what a file leaves out.
+type ImportOptions struct {
+	City        string    // for new suppliers without a city
+	Categories  []string  // for new suppliers without categories (e.g. phone contacts)
+	CountryCode string    // prepended to national phone numbers, e.g. "55"
+	DNC         *DNCStore // suppliers on the do-not-contact list are imported inactive
+}
+
+// FieldChange is one field an import changes.
+type FieldChange struct {
+	Field, Old, New string
+}
+
+// ImportItem is what importing one record does.
+type ImportItem struct {
+	Record   Record
+	Action   string        // ImportAdd, ImportUpdate, ImportUnchanged or ImportSkip
+	Supplier Supplier      // as it will be saved
+	Changes  []FieldChange // for ImportUpdate
+	Note     string        // why it was skipped, or a warning
+
+	current *Supplier // the saved supplier it updates
+}
+
+// PlanImport works out what importing recs would do without saving anything.
+// A record updates the supplier with the same ID or, failing that, the same
+// phone (compared after CanonicalPhone), e-mail or Telegram chat; otherwise
+// it adds a new one. Only the fields a record fills in are changed, so
+// re-importing the same file is a no-op. A record named "Chain - Branch"
+// whose phone is that of "Chain", in the database or earlier in the file,
+// adds Branch to its stores instead of renaming it.
+func (s *Store) PlanImport(recs []Record, opts ImportOptions) ([]ImportItem, error) {
+	existing, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	tax, err := NewCategoryStore(s.db).Load()
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*Supplier)
+	for i := range existing {
+		for _, k := range supplierKeys(existing[i], opts.CountryCode) {
+			byKey[k] = &existing[i]
+		}
+	}
+
+	seen := make(map[string]int) // key → index in items of the record that used it
+	var items []ImportItem
+	for _, rec := range recs {
+		item := ImportItem{Record: rec}
+		rec.Phone = CanonicalPhone(rec.Phone, opts.CountryCode)
+
+		var cur *Supplier
+		if rec.ID != "" {
+			cur = byKey["id:"+rec.ID]
+		}
+		keys := recordKeys(rec)
+		for _, k := range keys {
+			if cur == nil {
+				cur = byKey[k]
+			}
+		}
+		dup := -1
+		for _, k := range keys {
+			if i, ok := seen[k]; ok && dup < 0 {
+				dup = i
+			}
+		}
+		if dup >= 0 {
+			prev := &items[dup]
+			if addBranch(prev, rec) {
+				item.Action = ImportBranch
+				item.Note = fmt.Sprintf("loja de %s (#%d)", prev.Supplier.Name, prev.Record.Line)
+			} else {
+				item.Action, item.Note = ImportSkip, fmt.Sprintf("mesmo contato de #%d", prev.Record.Line)
+			}
+			items = append(items, item)
+			continue
+		}
+		if cur != nil && len(rec.Branches) == 0 {
+			if base, branch := splitBranch(rec.Name); branch != "" && strings.EqualFold(base, cur.Name) {
+				rec.Name, rec.Branches = "", appendUnique(append([]string(nil), cur.Branches...), branch)
+			}
+		}
+
+		var sup Supplier
+		if cur != nil {
+			sup = *cur
+		} else {
+			sup = Supplier{ID: rec.ID, City: opts.City, Categories: opts.Categories, Rating: 5, Active: true}
+		}
+		mergeRecord(&sup, rec)
+		if err := sup.Validate(); err != nil {
+			item.Action, item.Note = ImportSkip, err.Error()
+			items = append(items, item)
+			continue
+		}
+		if sup.Categories, err = tax.ResolveAll(sup.Categories); err != nil {
+			item.Action, item.Note = ImportSkip, err.Error()
+			items = append(items, item)
+			continue
+		}
+		if opts.DNC != nil && sup.Active {
+			blocked, err := opts.DNC.Contains(sup.Address())
+			if err != nil {
+				return nil, err
+			}
+			if blocked {
+				sup.Active = false
+				item.Note = "na lista de não contactar; fica inativo"
+			}
+		}
+
+		item.Supplier, item.current = sup, cur
+		item.classify()
+		if item.Action != ImportSkip {
+			for _, k := range keys {
+				seen[k] = len(items)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// classify sets the action of a valid item from what it changes.
+func (it *ImportItem) classify() {
+	if it.current == nil {
+		it.Action = ImportAdd
+		return
+	}
+	it.Changes = diffSuppliers(*it.current, it.Supplier)
+	it.Action = ImportUpdate
+	if len(it.Changes) == 0 {
+		it.Action = ImportUnchanged
+	}
+}
+
+// addBranch folds rec into prev, an item earlier in the file with the same
+// contact, when both are stores of one chain ("Fort Atacadista - Cafezais",
+// "Fort Atacadista - Rua da Divisão"). It reports whether it did.
+func addBranch(prev *ImportItem, rec Record) bool {
+	if prev.Action == ImportSkip || prev.Action == ImportBranch {
+		return false
+	}
+	base, branch := splitBranch(rec.Name)
+	prevBase, _ := splitBranch(prev.Supplier.Name)
+	if branch == "" || !strings.EqualFold(base, prevBase) {
+		return false
+	}
+	var other Supplier
+	mergeRecord(&other, rec)
+	prev.Supplier = Merged(prev.Supplier, []Supplier{other})
+	prev.classify()
+	return true
+}
+
+// ApplyImport saves a plan made by PlanImport in one transaction, so a
+// failing item leaves nothing saved. Phones are checked against the saved
+// suppliers and the plan itself; the unique index catches any taken since.
+func (s *Store) ApplyImport(items []ImportItem) error {
+	existing, err := s.ListAll()
+	if err != nil {
+		return err
+	}
+	owner := make(map[string]Supplier) // phone → supplier that will have it
+	for _, sup := range existing {
+		if p := phoneKey(sup.Phone); p != "" {
+			owner[p] = sup
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, it := range items {
+		sup := it.Supplier
+		switch it.Action {
+		case ImportAdd:
+			if sup.ID == "" {
+				sup.ID = uuid.New().String()
+			}
+		case ImportUpdate:
+			if it.current != nil {
+				delete(owner, phoneKey(it.current.Phone))
+			}
+		default:
+			continue
+		}
+		if p := phoneKey(sup.Phone); p != "" {
+			if o, ok := owner[p]; ok && o.ID != sup.ID {
+				return fmt.Errorf("%s (#%d): %w: %s é de %s", sup.Name, it.Record.Line, ErrDuplicatePhone, p, o.Name)
+			}
+			owner[p] = sup
+		}
+		if it.Action == ImportAdd {
+			_, err = insert(tx, sup)
+		} else {
+			err = update(tx, sup)
+		}
+		if err != nil {
+			return fmt.Errorf("%s (#%d): %w", sup.Name, it.Record.Line, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DefaultCountryCode is the country code of suppliers' national phone
+// numbers.
+const DefaultCountryCode = "55"
+
+// CanonicalPhone reduces a phone to digits with the country code: "+55 (67)
+// 99999-0000", "067 99999-0000" and "67 99999-0000" all become
+// "5567999990000" for countryCode "55". Numbers of 10 or 11 digits (area
+// code and number) are taken as national.
+func CanonicalPhone(phone, countryCode string) string {
+	digits := NormalizePhone(phone)
+	if strings.HasPrefix(strings.TrimSpace(phone), "+") || countryCode == "" {
+		return digits
+	}
+	if strings.HasPrefix(digits, "00") {
+		return digits[2:] // international prefix
+	}
+	digits = strings.TrimPrefix(digits, "0") // trunk prefix
+	if len(digits) == 10 || len(digits) == 11 {
+		return countryCode + digits
+	}
+	return digits
+}
+
+// supplierKeys are the identifiers an imported record can match sup by.
+func supplierKeys(sup Supplier, countryCode string) []string {
+	keys := []string{"id:" + sup.ID}
+	r := Record{Phone: CanonicalPhone(sup.Phone, countryCode), Email: sup.Email, TelegramID: sup.TelegramID}
+	return append(keys, recordKeys(r)...)
+}
+
+func recordKeys(r Record) []string {
+	var keys []string
+	if r.Phone != "" {
+		keys = append(keys, "phone:"+r.Phone)
+	}
+	if r.Email != "" {
+		keys = append(keys, "email:"+strings.ToLower(r.Email))
+	}
+	if r.TelegramID != "" {
+		keys = append(keys, "telegram:"+r.TelegramID)
+	}
+	return keys
+}
+
+// mergeRecord copies the fields rec fills in into sup.
+func mergeRecord(sup *Supplier, rec Record) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&sup.Name, rec.Name)
+	set(&sup.Phone, rec.Phone)
+	set(&sup.City, rec.City)
+	set(&sup.Channel, rec.Channel)
+	set(&sup.TelegramID, rec.TelegramID)
+	set(&sup.Email, rec.Email)
+	set(&sup.Hours, rec.Hours)
+	set(&sup.Timezone, rec.Timezone)
+	set(&sup.Street, rec.Street)
+	if len(rec.Categories) > 0 {
+		sup.Categories = rec.Categories
+	}
+	if len(rec.Branches) > 0 {
+		sup.Branches = rec.Branches
+	}
+	if rec.Rating != nil {
+		sup.Rating = *rec.Rating
+	}
+	if rec.Active != nil {
+		sup.Active = *rec.Active
+	}
+	if rec.Lat != nil && rec.Lng != nil {
+		sup.Geo = Point{Lat: *rec.Lat, Lng: *rec.Lng}
+	}
+	if rec.Radius != nil {
+		sup.DeliveryRadius = *rec.Radius
+	}
+}
+
+func diffSuppliers(old, new Supplier) []FieldChange {
+	var out []FieldChange
+	add := func(field, o, n string) {
+		if o != n {
+			out = append(out, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+	add("nome", old.Name, new.Name)
+	add("telefone", old.Phone, new.Phone)
+	add("cidade", old.City, new.City)
+	add("categorias", strings.Join(old.Categories, ","), strings.Join(new.Categories, ","))
+	add("nota", strconv.FormatFloat(old.Rating, 'f', -1, 64), strconv.FormatFloat(new.Rating, 'f', -1, 64))
+	add("ativo", strconv.FormatBool(old.Active), strconv.FormatBool(new.Active))
+	add("canal", old.Channel, new.Channel)
+	add("telegram", old.TelegramID, new.TelegramID)
+	add("email", old.Email, new.Email)
+	add("horário", old.Hours, new.Hours)
+	add("fuso", old.Timezone, new.Timezone)
+	add("lojas", strings.Join(old.Branches, "|"), strings.Join(new.Branches, "|"))
+	add("endereço", old.Street, new.Street)
+	add("coordenadas", old.Geo.String(), new.Geo.String())
+	add("raio de entrega", strconv.FormatFloat(old.DeliveryRadius, 'f', -1, 64), strconv.FormatFloat(new.DeliveryRadius, 'f', -1, 64))
+	return out
+}
@@ -0,0 +1,64 @@
+package suppliers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyImportIsAllOrNothing(t *testing.T) {
+	s := NewStore(openTestDB(t))
+	xID, err := s.Add(Supplier{Name: "Atacadão", Phone: "5567900000000", City: "Campo Grande", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Get(xID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(name, phone string) ImportItem {
+		return ImportItem{Action: ImportAdd, Supplier: Supplier{Name: name, Phone: phone, City: "Campo Grande", Active: true}}
+	}
+	names := func() []string {
+		all, err := s.ListAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, sup := range all {
+			out = append(out, sup.Name)
+		}
+		return out
+	}
+
+	// A phone already taken is caught before anything is written
+	err = s.ApplyImport([]ImportItem{add("Fort", "5567911110000"), add("Assaí", "5567900000000")})
+	if !errors.Is(err, ErrDuplicatePhone) {
+		t.Errorf("taken phone: got %v, want ErrDuplicatePhone", err)
+	}
+	if got := names(); len(got) != 1 {
+		t.Errorf("after a duplicate phone: suppliers %v, want only the existing one", got)
+	}
+
+	// A failure in the database rolls back the items saved before it
+	missing := ImportItem{Action: ImportUpdate, Supplier: Supplier{ID: "nenhum", Name: "Comper", Phone: "5567922220000"}}
+	if err := s.ApplyImport([]ImportItem{add("Fort", "5567911110000"), missing}); err == nil {
+		t.Error("update of a missing supplier: want error")
+	}
+	if got := names(); len(got) != 1 {
+		t.Errorf("after a failed update: suppliers %v, want only the existing one", got)
+	}
+
+	// A phone freed by an update earlier in the plan can be taken later in it
+	moved := *x
+	moved.Phone = "5567933330000"
+	err = s.ApplyImport([]ImportItem{
+		{Action: ImportUpdate, Supplier: moved, current: x},
+		add("Assaí", "5567900000000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); len(got) != 2 {
+		t.Errorf("after moving a phone: suppliers %v, want two", got)
+	}
+}
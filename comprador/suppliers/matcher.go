@@ -4,39 +4,145 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/user/agente/internal/claude"
 )
 
-// Matcher uses Claude to find the best suppliers for a list of items.
+const (
+	// maxPerCategory is how many of the best-rated suppliers of each
+	// category are considered for a request.
+	maxPerCategory = 5
+	// maxCandidates caps the suppliers shown to the model for ranking.
+	maxCandidates = 15
+)
+
+// Matcher finds the best suppliers for a list of items in two stages: items
+// are classified into supplier categories and the suppliers of those
+// categories pre-filtered in SQL, then Claude ranks the few candidates left.
 type Matcher struct {
-	claude *claude.Client
-	store  *Store
+	claude     *claude.Client
+	store      *Store
+	categories *CategoryStore
 }
 
 // NewMatcher creates a Matcher.
 func NewMatcher(claude *claude.Client, store *Store) *Matcher {
-	return &Matcher{claude: claude, store: store}
+	return &Matcher{claude: claude, store: store, categories: NewCategoryStore(store.db)}
+}
+
+// MatchOptions says where a purchase is for.
+type MatchOptions struct {
+	City string // suppliers in it are preferred; ignored when MaxRadius is set
+	// Origin is where the purchase is delivered; suppliers near it, and
+	// those delivering there, are preferred. Zero when unknown.
+	Origin Point
+	// MaxRadius, in km from Origin, leaves out suppliers farther away or
+	// without a location; 0 means any distance.
+	MaxRadius float64
 }
 
 // MatchResult holds a supplier matched to items.
 type MatchResult struct {
 	Supplier   Supplier
 	Categories []string
+	Items      []string // the items it serves
 	Reason     string
+	Distance   float64 // km from MatchOptions.Origin; -1 when unknown
+	Delivers   bool    // Origin is within the supplier's delivery radius
 }
 
-// Match asks Claude to map items to appropriate suppliers from the store.
-func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]MatchResult, error) {
-	all, err := m.store.List()
+// Match maps items to suppliers. Only active suppliers off the do-not-contact
+// list, serving the category of some item or a category above it, are
+// considered: those in opts.City (or within opts.MaxRadius) first, closest
+// to opts.Origin first, up to maxPerCategory per category and maxCandidates
+// in all. When the model is unavailable every candidate is matched by rule.
+func (m *Matcher) Match(ctx context.Context, items []string, opts MatchOptions) ([]MatchResult, error) {
+	tax, err := m.categories.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load categories: %w", err)
+	}
+	byItem, err := m.classify(ctx, items, tax)
+	if err != nil {
+		return nil, fmt.Errorf("classify items: %w", err)
+	}
+	// A supplier of "hortifruti" (or "alimentos") also sells "frutas"
+	for item, cats := range byItem {
+		for _, c := range cats {
+			for _, a := range tax.Ancestors(c) {
+				byItem[item] = appendUnique(byItem[item], a)
+			}
+		}
+	}
+	candidates, err := m.candidates(items, byItem, opts)
 	if err != nil {
 		return nil, fmt.Errorf("list suppliers: %w", err)
 	}
-
-	if len(all) == 0 {
+	if len(candidates) == 0 {
 		return nil, nil
 	}
 
+	results, err := m.rank(ctx, items, byItem, candidates, opts)
+	if err != nil {
+		results = ruleMatch(items, byItem, candidates)
+	}
+	for i := range results {
+		results[i].Distance = results[i].Supplier.DistanceFrom(opts.Origin)
+		results[i].Delivers = results[i].Supplier.DeliversTo(opts.Origin)
+	}
+	return results, nil
+}
+
+// candidates pre-filters the suppliers for the categories in byItem, taking
+// the best of each category in turn so every category is covered before any
+// gets more. Without a radius, a category with no supplier in the city takes
+// them from anywhere.
+func (m *Matcher) candidates(items []string, byItem map[string][]string, opts MatchOptions) ([]Supplier, error) {
+	var cats []string
+	for _, item := range items {
+		for _, c := range byItem[item] {
+			cats = appendUnique(cats, c)
+		}
+	}
+
+	city := opts.City
+	if opts.MaxRadius > 0 && !opts.Origin.IsZero() {
+		city = ""
+	}
+	perCat := make([][]Supplier, len(cats))
+	for i, c := range cats {
+		f := CandidateFilter{Categories: []string{c}, City: city, Limit: maxPerCategory, Near: opts.Origin, MaxRadius: opts.MaxRadius}
+		sups, err := m.store.Candidates(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(sups) == 0 && city != "" {
+			f.City = ""
+			sups, err = m.store.Candidates(f)
+			if err != nil {
+				return nil, err
+			}
+		}
+		perCat[i] = sups
+	}
+
+	seen := make(map[string]bool)
+	var out []Supplier
+	for rank := 0; rank < maxPerCategory && len(out) < maxCandidates; rank++ {
+		for _, sups := range perCat {
+			if rank >= len(sups) || seen[sups[rank].ID] || len(out) >= maxCandidates {
+				continue
+			}
+			seen[sups[rank].ID] = true
+			out = append(out, sups[rank])
+		}
+	}
+	return out, nil
+}
+
+// rank asks Claude which candidates should get the request.
+func (m *Matcher) rank(ctx context.Context, items []string, byItem map[string][]string, candidates []Supplier, opts MatchOptions) ([]MatchResult, error) {
 	// Serialize suppliers for Claude
 	type supSummary struct {
 		ID         string   `json:"id"`
@@ -44,13 +150,28 @@ func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]Mat
 		Categories []string `json:"categories"`
 		City       string   `json:"city"`
 		Rating     float64  `json:"rating"`
+		DistanceKm *float64 `json:"distance_km,omitempty"`
+		Delivers   bool     `json:"delivers_to_address,omitempty"`
 	}
-	summaries := make([]supSummary, len(all))
-	for i, s := range all {
-		summaries[i] = supSummary{s.ID, s.Name, s.Categories, s.City, s.Rating}
+	summaries := make([]supSummary, len(candidates))
+	for i, s := range candidates {
+		summaries[i] = supSummary{ID: s.ID, Name: s.Name, Categories: s.Categories, City: s.City, Rating: s.Rating}
+		if d := s.DistanceFrom(opts.Origin); d >= 0 {
+			d = math.Round(d*10) / 10
+			summaries[i].DistanceKm = &d
+			summaries[i].Delivers = s.DeliversTo(opts.Origin)
+		}
 	}
 	supJSON, _ := json.Marshal(summaries)
-	itemsJSON, _ := json.Marshal(items)
+	type itemSummary struct {
+		Item       string   `json:"item"`
+		Categories []string `json:"categories"`
+	}
+	itemList := make([]itemSummary, len(items))
+	for i, it := range items {
+		itemList[i] = itemSummary{it, byItem[it]}
+	}
+	itemsJSON, _ := json.Marshal(itemList)
 
 	tools := []claude.ToolDef{
 		{
@@ -76,13 +197,19 @@ func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]Mat
 		},
 	}
 
+	where := "Cidade alvo: " + opts.City
+	if !opts.Origin.IsZero() {
+		where += "\nDistâncias (distance_km) medidas a partir do endereço de entrega; delivers_to_address indica que o " +
+			"fornecedor entrega nesse endereço. Sem distance_km, a localização do fornecedor é desconhecida."
+	}
 	prompt := fmt.Sprintf(
-		"Você é um assistente de compras. Dada a lista de itens a comprar e os fornecedores disponíveis, "+
-			"selecione quais fornecedores devem receber pedido de cotação.\n\n"+
-			"Itens: %s\n\nFornecedores disponíveis: %s\n\nCidade alvo: %s\n\n"+
+		"Você é um assistente de compras. Dada a lista de itens a comprar (com suas categorias) e os fornecedores "+
+			"pré-selecionados, escolha quais devem receber pedido de cotação.\n\n"+
+			"Itens: %s\n\nFornecedores: %s\n\n%s\n\n"+
 			"Use a ferramenta match_suppliers para retornar os fornecedores mais adequados. "+
-			"Prefira fornecedores na mesma cidade. Selecione todos que possam fornecer ao menos um item.",
-		itemsJSON, supJSON, city,
+			"Prefira fornecedores na mesma cidade, mais próximos, que entregam no endereço e com nota maior. "+
+			"Selecione todos que possam fornecer ao menos um item.",
+		itemsJSON, supJSON, where,
 	)
 
 	var matchedIDs []struct {
@@ -90,7 +217,7 @@ func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]Mat
 		Reason     string `json:"reason"`
 	}
 
-	_, err = m.claude.ChatWithTools(ctx, claude.ChatRequest{
+	_, err := m.claude.ChatWithTools(ctx, claude.ChatRequest{
 		System: "Você é um especialista em compras locais.",
 		User:   prompt,
 		Tools:  tools,
@@ -115,8 +242,8 @@ func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]Mat
 	}
 
 	// Build index for fast lookup
-	supIndex := make(map[string]Supplier, len(all))
-	for _, s := range all {
+	supIndex := make(map[string]Supplier, len(candidates))
+	for _, s := range candidates {
 		supIndex[s.ID] = s
 	}
 
@@ -131,10 +258,43 @@ func (m *Matcher) Match(ctx context.Context, items []string, city string) ([]Mat
 			continue
 		}
 		seen[m.SupplierID] = true
+		cats, its := covered(sup, items, byItem)
 		results = append(results, MatchResult{
-			Supplier: sup,
-			Reason:   m.Reason,
+			Supplier:   sup,
+			Categories: cats,
+			Items:      its,
+			Reason:     m.Reason,
 		})
 	}
 	return results, nil
 }
+
+// ruleMatch matches every candidate, with the items it covers as the reason.
+func ruleMatch(items []string, byItem map[string][]string, candidates []Supplier) []MatchResult {
+	results := make([]MatchResult, 0, len(candidates))
+	for _, sup := range candidates {
+		cats, its := covered(sup, items, byItem)
+		results = append(results, MatchResult{
+			Supplier:   sup,
+			Categories: cats,
+			Items:      its,
+			Reason:     fmt.Sprintf("atende %s (%s); nota %.1f", strings.Join(its, ", "), strings.Join(cats, ", "), sup.Rating),
+		})
+	}
+	return results
+}
+
+// covered returns the categories of sup some item needs, and those items.
+func covered(sup Supplier, items []string, byItem map[string][]string) (cats, its []string) {
+	for _, item := range items {
+		for _, c := range byItem[item] {
+			for _, sc := range sup.Categories {
+				if sc == c {
+					cats = appendUnique(cats, c)
+					its = appendUnique(its, item)
+				}
+			}
+		}
+	}
+	return cats, its
+}
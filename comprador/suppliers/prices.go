@@ -0,0 +1,135 @@
+package suppliers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Price is what a supplier quoted for one unit of an item.
+type Price struct {
+	ID         int64
+	SupplierID string
+	Supplier   string // supplier name, filled in when read back
+	RequestID  string
+	QuoteID    string
+	Item       string
+	Unit       string
+	Price      float64 // per Unit
+	QuotedAt   time.Time
+}
+
+// PriceStore is the catalog of item prices read from supplier replies.
+type PriceStore struct {
+	db *sql.DB
+}
+
+// NewPriceStore creates a PriceStore.
+func NewPriceStore(db *sql.DB) *PriceStore {
+	return &PriceStore{db: db}
+}
+
+// Record saves the prices read from replies. Prices already recorded for
+// the same quote are replaced, so a request compared again is not counted
+// twice.
+func (ps *PriceStore) Record(prices []Price) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cleared := make(map[string]bool)
+	for _, p := range prices {
+		if p.Price <= 0 || strings.TrimSpace(p.Item) == "" {
+			continue
+		}
+		if !cleared[p.QuoteID] {
+			if _, err := tx.Exec(`DELETE FROM supplier_prices WHERE quote_id = ?`, p.QuoteID); err != nil {
+				return err
+			}
+			cleared[p.QuoteID] = true
+		}
+		if p.QuotedAt.IsZero() {
+			p.QuotedAt = time.Now()
+		}
+		_, err := tx.Exec(
+			`INSERT INTO supplier_prices (supplier_id, request_id, quote_id, item, item_key, unit, price, quoted_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.SupplierID, p.RequestID, p.QuoteID, p.Item, itemKey(p.Item), p.Unit, p.Price, p.QuotedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("insert price: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Search returns the prices quoted since the given time for items whose
+// name has every word of query, newest first.
+func (ps *PriceStore) Search(query string, since time.Time) ([]Price, error) {
+	words := strings.Fields(itemKey(query))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("informe o item")
+	}
+	where := make([]string, len(words))
+	args := make([]any, len(words))
+	for i, w := range words {
+		where[i] = `(' ' || p.item_key || ' ') LIKE ?`
+		args[i] = "% " + w + " %"
+	}
+	return ps.query(strings.Join(where, " AND "), since, args...)
+}
+
+// Latest returns the newest price each supplier quoted since the given time
+// for item, newest first.
+func (ps *PriceStore) Latest(item string, since time.Time) ([]Price, error) {
+	all, err := ps.query(`p.item_key = ?`, since, itemKey(item))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var out []Price
+	for _, p := range all {
+		if !seen[p.SupplierID] {
+			seen[p.SupplierID] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// query returns the prices matching where, quoted since the given time,
+// newest first. Dates are compared in Go: SQLite holds them as text.
+func (ps *PriceStore) query(where string, since time.Time, args ...any) ([]Price, error) {
+	rows, err := ps.db.Query(
+		`SELECT p.id, p.supplier_id, COALESCE(s.name, ''), p.request_id, p.quote_id, p.item, p.unit, p.price, p.quoted_at
+		 FROM supplier_prices p LEFT JOIN suppliers s ON s.id = p.supplier_id
+		 WHERE `+where, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Price
+	for rows.Next() {
+		var p Price
+		if err := rows.Scan(&p.ID, &p.SupplierID, &p.Supplier, &p.RequestID, &p.QuoteID, &p.Item, &p.Unit, &p.Price, &p.QuotedAt); err != nil {
+			return nil, err
+		}
+		if !p.QuotedAt.Before(since) {
+			out = append(out, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].QuotedAt.After(out[j].QuotedAt) })
+	return out, nil
+}
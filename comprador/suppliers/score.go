@@ -0,0 +1,355 @@
+package suppliers
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// Scoring turns what suppliers actually did into their Rating. Each part is
+// a value from 0 to 1; parts without data are left out and the weights of
+// the others renormalized.
+var scoreWeights = struct {
+	Response, Speed, Price, Win, Feedback float64
+}{
+	Response: 0.30, // answered / asked
+	Speed:    0.15, // 1 / (1 + median reply time / speedHalf)
+	Price:    0.25, // cheapest price / own price, averaged over requests
+	Win:      0.10, // accepted / answered on decided requests
+	Feedback: 0.20, // (owner's average rating - 1) / 4
+}
+
+const (
+	// scoreWindow is how far back quotes count, so a supplier that got
+	// better (or worse) is not held to its old record.
+	scoreWindow = 180 * 24 * time.Hour
+	// speedHalf is the median reply time that scores half on speed.
+	speedHalf = 2 * time.Hour
+	// scorePrior and scorePriorWeight pull suppliers with little history
+	// towards a neutral rating: the prior counts as that many requests.
+	scorePrior       = 3.0
+	scorePriorWeight = 3.0
+)
+
+// Score is a supplier's computed rating and what it is made of.
+type Score struct {
+	SupplierID     string
+	Rating         float64       // 0-5
+	Asked          int           // quotes asked whose requests are past their deadline, or answered
+	Answered       int           // of Asked
+	MedianResponse time.Duration // from our message to the first reply; 0 if none
+	Priced         int           // answers priced alongside at least one competitor
+	PriceIndex     float64       // mean of cheapest price / own price; 1 = always the cheapest
+	Decided        int           // answers on requests where the owner chose an option
+	Won            int           // of Decided
+	Feedbacks      int
+	FeedbackAvg    float64 // 1-5
+	UpdatedAt      time.Time
+}
+
+// ResponseRate is Answered / Asked.
+func (s Score) ResponseRate() float64 {
+	if s.Asked == 0 {
+		return 0
+	}
+	return float64(s.Answered) / float64(s.Asked)
+}
+
+// WinRate is Won / Decided.
+func (s Score) WinRate() float64 {
+	if s.Decided == 0 {
+		return 0
+	}
+	return float64(s.Won) / float64(s.Decided)
+}
+
+// ScoreStore computes and keeps supplier scores.
+type ScoreStore struct {
+	db *sql.DB
+}
+
+// NewScoreStore creates a ScoreStore.
+func NewScoreStore(db *sql.DB) *ScoreStore {
+	return &ScoreStore{db: db}
+}
+
+// scoredQuote is a quote with what scoring needs from its request and
+// messages.
+type scoredQuote struct {
+	requestID, supplierID string
+	answered              bool
+	price                 float64
+	accepted              bool
+	requestStatus         string
+	asked                 time.Time // first message to the supplier, else the quote's creation
+	responded             sql.NullTime
+}
+
+// Compute scores every supplier with history in the last scoreWindow.
+// Suppliers never asked nor rated are left out.
+func (ss *ScoreStore) Compute(now time.Time) ([]Score, error) {
+	quotes, err := ss.quotes(now.Add(-scoreWindow))
+	if err != nil {
+		return nil, err
+	}
+	feedback, err := ss.feedback()
+	if err != nil {
+		return nil, err
+	}
+
+	cheapest := make(map[string]float64) // request → lowest price
+	offers := make(map[string]int)       // request → priced answers
+	decided := make(map[string]bool)     // requests with an accepted quote
+	for _, q := range quotes {
+		if q.answered && q.price > 0 {
+			offers[q.requestID]++
+			if c, ok := cheapest[q.requestID]; !ok || q.price < c {
+				cheapest[q.requestID] = q.price
+			}
+		}
+		if q.accepted {
+			decided[q.requestID] = true
+		}
+	}
+
+	type acc struct {
+		score     Score
+		delays    []time.Duration
+		priceSum  float64
+		ratingSum int
+	}
+	bySup := make(map[string]*acc)
+	get := func(id string) *acc {
+		if bySup[id] == nil {
+			bySup[id] = &acc{score: Score{SupplierID: id, UpdatedAt: now}}
+		}
+		return bySup[id]
+	}
+	for _, q := range quotes {
+		open := q.requestStatus == "open" || q.requestStatus == "cancelled"
+		if !q.answered && open {
+			continue // still time to answer, or nobody waited for it
+		}
+		a := get(q.supplierID)
+		a.score.Asked++
+		if !q.answered {
+			continue
+		}
+		a.score.Answered++
+		if q.responded.Valid && q.responded.Time.After(q.asked) {
+			a.delays = append(a.delays, q.responded.Time.Sub(q.asked))
+		}
+		if q.price > 0 && offers[q.requestID] > 1 {
+			a.score.Priced++
+			a.priceSum += cheapest[q.requestID] / q.price
+		}
+		if decided[q.requestID] {
+			a.score.Decided++
+			if q.accepted {
+				a.score.Won++
+			}
+		}
+	}
+	for id, ratings := range feedback {
+		a := get(id)
+		a.score.Feedbacks = len(ratings)
+		for _, r := range ratings {
+			a.ratingSum += r
+		}
+	}
+
+	var out []Score
+	for _, a := range bySup {
+		s := a.score
+		if len(a.delays) > 0 {
+			sort.Slice(a.delays, func(i, j int) bool { return a.delays[i] < a.delays[j] })
+			s.MedianResponse = a.delays[len(a.delays)/2]
+			if len(a.delays)%2 == 0 {
+				s.MedianResponse = (a.delays[len(a.delays)/2-1] + a.delays[len(a.delays)/2]) / 2
+			}
+		}
+		if s.Priced > 0 {
+			s.PriceIndex = a.priceSum / float64(s.Priced)
+		}
+		if s.Feedbacks > 0 {
+			s.FeedbackAvg = float64(a.ratingSum) / float64(s.Feedbacks)
+		}
+		s.Rating = rate(s)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rating > out[j].Rating })
+	return out, nil
+}
+
+// rate combines the parts of s into a 0-5 rating, shrunk towards
+// scorePrior while there is little history.
+func rate(s Score) float64 {
+	var sum, weights float64
+	part := func(ok bool, w, v float64) {
+		if ok {
+			sum += w * v
+			weights += w
+		}
+	}
+	w := scoreWeights
+	part(s.Asked > 0, w.Response, s.ResponseRate())
+	part(s.MedianResponse > 0, w.Speed, 1/(1+float64(s.MedianResponse)/float64(speedHalf)))
+	part(s.Priced > 0, w.Price, s.PriceIndex)
+	part(s.Decided > 0, w.Win, s.WinRate())
+	part(s.Feedbacks > 0, w.Feedback, (s.FeedbackAvg-1)/4)
+	if weights == 0 {
+		return scorePrior
+	}
+	raw := 5 * sum / weights
+	n := float64(s.Asked + s.Feedbacks)
+	r := (n*raw + scorePriorWeight*scorePrior) / (n + scorePriorWeight)
+	return math.Round(r*10) / 10
+}
+
+// Save stores scores and copies each rating to its supplier.
+func (ss *ScoreStore) Save(scores []Score) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range scores {
+		_, err := tx.Exec(
+			`INSERT INTO supplier_scores (supplier_id, rating, asked, answered, median_response, priced, price_index,
+			   decided, won, feedbacks, feedback_avg, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(supplier_id) DO UPDATE SET rating = excluded.rating, asked = excluded.asked,
+			   answered = excluded.answered, median_response = excluded.median_response, priced = excluded.priced,
+			   price_index = excluded.price_index, decided = excluded.decided, won = excluded.won,
+			   feedbacks = excluded.feedbacks, feedback_avg = excluded.feedback_avg, updated_at = excluded.updated_at`,
+			s.SupplierID, s.Rating, s.Asked, s.Answered, int64(s.MedianResponse.Seconds()), s.Priced, s.PriceIndex,
+			s.Decided, s.Won, s.Feedbacks, s.FeedbackAvg, s.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE suppliers SET rating = ? WHERE id = ?`, s.Rating, s.SupplierID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get returns the last saved score of a supplier, or nil.
+func (ss *ScoreStore) Get(supplierID string) (*Score, error) {
+	var s Score
+	var median int64
+	err := ss.db.QueryRow(
+		`SELECT supplier_id, rating, asked, answered, median_response, priced, price_index, decided, won,
+		   feedbacks, feedback_avg, updated_at
+		 FROM supplier_scores WHERE supplier_id = ?`, supplierID,
+	).Scan(&s.SupplierID, &s.Rating, &s.Asked, &s.Answered, &median, &s.Priced, &s.PriceIndex, &s.Decided, &s.Won,
+		&s.Feedbacks, &s.FeedbackAvg, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.MedianResponse = time.Duration(median) * time.Second
+	return &s, nil
+}
+
+// LastUpdate returns when scores were last saved; zero if never.
+func (ss *ScoreStore) LastUpdate() (time.Time, error) {
+	var last time.Time
+	err := ss.db.QueryRow(`SELECT updated_at FROM supplier_scores ORDER BY updated_at DESC LIMIT 1`).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return last, err
+}
+
+func (ss *ScoreStore) quotes(since time.Time) ([]scoredQuote, error) {
+	firstSent, err := ss.firstSent()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ss.db.Query(
+		`SELECT q.request_id, q.supplier_id, COALESCE(q.response, '') <> '', COALESCE(q.price, 0), q.status,
+		   r.status, q.created_at, q.responded_at
+		 FROM quotes q JOIN quote_requests r ON r.id = q.request_id
+		 JOIN suppliers s ON s.id = q.supplier_id AND s.deleted_at IS NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []scoredQuote
+	for rows.Next() {
+		var q scoredQuote
+		var status string
+		if err := rows.Scan(&q.requestID, &q.supplierID, &q.answered, &q.price, &status, &q.requestStatus,
+			&q.asked, &q.responded); err != nil {
+			return nil, err
+		}
+		// Dates are compared in Go: SQLite holds them as text
+		if q.asked.Before(since) {
+			continue
+		}
+		if t, ok := firstSent[q.requestID+"/"+q.supplierID]; ok {
+			q.asked = t
+		}
+		q.accepted = status == "accepted"
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
+
+// firstSent maps "request/supplier" to when the first message of the request
+// went to the supplier, which for a shop that was closed is later than the
+// quote itself.
+func (ss *ScoreStore) firstSent() (map[string]time.Time, error) {
+	rows, err := ss.db.Query(
+		`SELECT request_id, supplier_id, sent_at FROM messages
+		 WHERE direction = 'out' AND request_id IS NOT NULL AND supplier_id IS NOT NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]time.Time)
+	for rows.Next() {
+		var req, sup string
+		var at time.Time
+		if err := rows.Scan(&req, &sup, &at); err != nil {
+			return nil, err
+		}
+		k := req + "/" + sup
+		if t, ok := out[k]; !ok || at.Before(t) {
+			out[k] = at
+		}
+	}
+	return out, rows.Err()
+}
+
+// feedback maps each supplier to its ratings.
+func (ss *ScoreStore) feedback() (map[string][]int, error) {
+	rows, err := ss.db.Query(
+		`SELECT f.supplier_id, f.rating FROM supplier_feedback f
+		 JOIN suppliers s ON s.id = f.supplier_id AND s.deleted_at IS NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]int)
+	for rows.Next() {
+		var id string
+		var r int
+		if err := rows.Scan(&id, &r); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], r)
+	}
+	return out, rows.Err()
+}
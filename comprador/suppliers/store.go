@@ -3,10 +3,15 @@ package suppliers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/user/agente/internal/whatsapp"
 )
 
 // Supplier represents a local vendor.
@@ -18,8 +23,35 @@ type Supplier struct {
 	Categories []string
 	Rating     float64
 	Active     bool
+	Channel    string // preferred channel: "whatsapp" (default), "telegram" or "email"
+	TelegramID string // Telegram chat ID, when Channel is "telegram"
+	Email      string
+	Hours      string   // opening hours, see ParseHours; empty means always open
+	Timezone   string   // IANA name; empty means DefaultTimezone
+	Branches   []string // stores answering on the same phone, e.g. "Cafezais", "Rua da Divisão"
+
+	Street         string  // street address, e.g. "Rua da Divisão, 120"; see Geocode
+	Geo            Point   // coordinates; zero when unknown
+	DeliveryRadius float64 // km around Geo the supplier delivers to; 0 when unknown
+}
+
+// Address returns where the supplier is messaged: its Telegram chat or
+// e-mail when that is the preferred channel, else its WhatsApp phone.
+func (s Supplier) Address() string {
+	switch {
+	case s.Channel == whatsapp.ChannelTelegram && s.TelegramID != "":
+		return whatsapp.ChannelTelegram + ":" + s.TelegramID
+	case s.Channel == whatsapp.ChannelEmail && s.Email != "":
+		return whatsapp.ChannelEmail + ":" + s.Email
+	}
+	return s.Phone
 }
 
+// supplierColumns is the column list every supplier query selects, in
+// scanSupplierRow order.
+const supplierColumns = `id, name, phone, city, categories, rating, active, channel, telegram_id, email, hours, timezone, branches,
+	street, lat, lng, delivery_radius`
+
 // Store handles supplier persistence.
 type Store struct {
 	db *sql.DB
@@ -30,8 +62,27 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-// Add inserts a new supplier and returns its generated ID.
+// ErrDuplicatePhone is returned when saving a supplier whose phone another
+// supplier already has. Stores of one chain answering on the same phone are
+// a single supplier with Branches.
+var ErrDuplicatePhone = errors.New("telefone já cadastrado")
+
+// Add inserts a new supplier and returns its generated ID. The phone is
+// stored in its canonical form (see phoneKey) and must not belong to another
+// supplier; categories must be in the taxonomy, and are stored by slug.
 func (s *Store) Add(sup Supplier) (string, error) {
+	sup.Phone = phoneKey(sup.Phone)
+	if err := s.checkPhone(sup); err != nil {
+		return "", err
+	}
+	var err error
+	if sup.Categories, err = s.resolveCategories(sup.Categories); err != nil {
+		return "", err
+	}
+	return insert(s.db, sup)
+}
+
+func insert(db execer, sup Supplier) (string, error) {
 	if sup.ID == "" {
 		sup.ID = uuid.New().String()
 	}
@@ -39,11 +90,20 @@ func (s *Store) Add(sup Supplier) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("marshal categories: %w", err)
 	}
+	branches, err := json.Marshal(sup.Branches)
+	if err != nil {
+		return "", fmt.Errorf("marshal branches: %w", err)
+	}
+
+	if sup.Channel == "" {
+		sup.Channel = whatsapp.ChannelWhatsApp
+	}
 
-	_, err = s.db.Exec(
-		`INSERT INTO suppliers (id, name, phone, city, categories, rating, active)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		sup.ID, sup.Name, sup.Phone, sup.City, string(cats), sup.Rating, sup.Active,
+	_, err = db.Exec(
+		`INSERT INTO suppliers (`+supplierColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sup.ID, sup.Name, NormalizePhone(sup.Phone), sup.City, string(cats), sup.Rating, sup.Active, sup.Channel, sup.TelegramID, sup.Email,
+		sup.Hours, sup.Timezone, string(branches), sup.Street, sup.Geo.Lat, sup.Geo.Lng, sup.DeliveryRadius,
 	)
 	if err != nil {
 		return "", fmt.Errorf("insert supplier: %w", err)
@@ -51,11 +111,29 @@ func (s *Store) Add(sup Supplier) (string, error) {
 	return sup.ID, nil
 }
 
+// checkPhone fails with ErrDuplicatePhone if another supplier not removed
+// has sup's phone.
+func (s *Store) checkPhone(sup Supplier) error {
+	phone := phoneKey(sup.Phone)
+	if phone == "" {
+		return nil
+	}
+	all, err := s.ListAll()
+	if err != nil {
+		return err
+	}
+	for _, o := range all {
+		if o.ID != sup.ID && phoneKey(o.Phone) == phone {
+			return fmt.Errorf("%w: %s é de %s", ErrDuplicatePhone, phone, o.Name)
+		}
+	}
+	return nil
+}
+
 // List returns all active suppliers.
 func (s *Store) List() ([]Supplier, error) {
 	rows, err := s.db.Query(
-		`SELECT id, name, phone, city, categories, rating, active
-		 FROM suppliers WHERE active = 1 ORDER BY name`,
+		`SELECT `+supplierColumns+` FROM suppliers WHERE active = 1 ORDER BY name`,
 	)
 	if err != nil {
 		return nil, err
@@ -65,36 +143,110 @@ func (s *Store) List() ([]Supplier, error) {
 	return scanSuppliers(rows)
 }
 
-// ByCategory returns active suppliers that match any of the given categories.
-func (s *Store) ByCategory(categories []string) ([]Supplier, error) {
-	// SQLite doesn't support array params; load all and filter in Go
-	all, err := s.List()
+// ListAll returns every supplier, inactive ones included; removed ones are
+// left out.
+func (s *Store) ListAll() ([]Supplier, error) {
+	rows, err := s.db.Query(
+		`SELECT ` + supplierColumns + ` FROM suppliers WHERE deleted_at IS NULL ORDER BY name`,
+	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	return scanSuppliers(rows)
+}
+
+// ByCategory returns active suppliers that match any of the given categories
+// and are not on the do-not-contact list.
+func (s *Store) ByCategory(categories []string) ([]Supplier, error) {
+	return s.Candidates(CandidateFilter{Categories: categories})
+}
 
-	catSet := make(map[string]bool, len(categories))
-	for _, c := range categories {
-		catSet[c] = true
+// CandidateFilter narrows the suppliers a request can go to.
+type CandidateFilter struct {
+	Categories []string // any of them; empty means any category
+	City       string   // compared case-insensitively; empty means any city
+	Limit      int      // 0 means no limit
+
+	// Near is where the purchase is delivered. When set, suppliers are
+	// ordered by proximityScore instead of rating.
+	Near Point
+	// MaxRadius, in km, leaves out suppliers farther from Near, and those
+	// without a location; 0 means any distance.
+	MaxRadius float64
+}
+
+// supplierAddressSQL is Supplier.Address in SQL, normalized as the
+// do-not-contact list stores it.
+const supplierAddressSQL = `CASE
+	 WHEN s.channel = 'telegram' AND COALESCE(s.telegram_id, '') <> '' THEN 'telegram:' || trim(s.telegram_id)
+	 WHEN s.channel = 'email' AND COALESCE(s.email, '') <> '' THEN 'email:' || trim(s.email)
+	 ELSE s.phone END`
+
+// Candidates returns the active suppliers matching f that are not on the
+// do-not-contact list, best rated (or, with f.Near, best placed) first.
+func (s *Store) Candidates(f CandidateFilter) ([]Supplier, error) {
+	if f.Near.IsZero() {
+		f.MaxRadius = 0
+	}
+	q := `SELECT ` + supplierColumns + ` FROM suppliers s
+		 WHERE s.active = 1 AND s.deleted_at IS NULL
+		 AND NOT EXISTS (SELECT 1 FROM do_not_contact d WHERE d.address = ` + supplierAddressSQL + `)`
+	var args []any
+	if len(f.Categories) > 0 {
+		q += ` AND EXISTS (SELECT 1 FROM json_each(s.categories) c WHERE c.value IN (?` +
+			strings.Repeat(", ?", len(f.Categories)-1) + `))`
+		for _, c := range f.Categories {
+			args = append(args, c)
+		}
+	}
+	if f.City != "" {
+		q += ` AND s.city = ? COLLATE NOCASE`
+		args = append(args, f.City)
+	}
+	if f.MaxRadius > 0 {
+		// A bounding box in SQL; the exact distance is checked below
+		dLat := f.MaxRadius / earthRadius * 180 / math.Pi
+		dLng := dLat / math.Max(math.Cos(f.Near.Lat*math.Pi/180), 0.01)
+		q += ` AND (s.lat <> 0 OR s.lng <> 0) AND s.lat BETWEEN ? AND ? AND s.lng BETWEEN ? AND ?`
+		args = append(args, f.Near.Lat-dLat, f.Near.Lat+dLat, f.Near.Lng-dLng, f.Near.Lng+dLng)
 	}
+	q += ` ORDER BY s.rating DESC, s.name`
+	if f.Limit > 0 && f.Near.IsZero() {
+		q += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	var result []Supplier
-	for _, sup := range all {
-		for _, c := range sup.Categories {
-			if catSet[c] {
-				result = append(result, sup)
-				break
-			}
+	sups, err := scanSuppliers(rows)
+	if err != nil || f.Near.IsZero() {
+		return sups, err
+	}
+	kept := sups[:0]
+	for _, sup := range sups {
+		if f.MaxRadius == 0 || Distance(f.Near, sup.Geo) <= f.MaxRadius {
+			kept = append(kept, sup)
 		}
 	}
-	return result, nil
+	sort.SliceStable(kept, func(i, j int) bool {
+		return proximityScore(kept[i], f.Near) > proximityScore(kept[j], f.Near)
+	})
+	if f.Limit > 0 && len(kept) > f.Limit {
+		kept = kept[:f.Limit]
+	}
+	return kept, nil
 }
 
-// Get returns a supplier by ID.
+// Get returns a supplier by ID, even a removed one, so past quotes can
+// still name it.
 func (s *Store) Get(id string) (*Supplier, error) {
 	row := s.db.QueryRow(
-		`SELECT id, name, phone, city, categories, rating, active
-		 FROM suppliers WHERE id = ?`, id,
+		`SELECT `+supplierColumns+` FROM suppliers WHERE id = ?`, id,
 	)
 	sup, err := scanSupplier(row)
 	if err == sql.ErrNoRows {
@@ -109,22 +261,170 @@ func (s *Store) UpdateRating(id string, rating float64) error {
 	return err
 }
 
-// ByPhone returns the supplier matching a phone number (strips non-digits for comparison).
+// Update saves every field of an existing supplier. Like Add, it refuses a
+// phone another supplier has and categories not in the taxonomy.
+func (s *Store) Update(sup Supplier) error {
+	sup.Phone = phoneKey(sup.Phone)
+	if err := s.checkPhone(sup); err != nil {
+		return err
+	}
+	var err error
+	if sup.Categories, err = s.resolveCategories(sup.Categories); err != nil {
+		return err
+	}
+	return update(s.db, sup)
+}
+
+// resolveCategories maps category names and synonyms to slugs (see
+// Taxonomy.ResolveAll).
+func (s *Store) resolveCategories(cats []string) ([]string, error) {
+	if len(cats) == 0 {
+		return cats, nil
+	}
+	tax, err := NewCategoryStore(s.db).Load()
+	if err != nil {
+		return nil, err
+	}
+	return tax.ResolveAll(cats)
+}
+
+// execer is a *sql.DB or a *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func update(db execer, sup Supplier) error {
+	cats, err := json.Marshal(sup.Categories)
+	if err != nil {
+		return fmt.Errorf("marshal categories: %w", err)
+	}
+	branches, err := json.Marshal(sup.Branches)
+	if err != nil {
+		return fmt.Errorf("marshal branches: %w", err)
+	}
+	if sup.Channel == "" {
+		sup.Channel = whatsapp.ChannelWhatsApp
+	}
+	res, err := db.Exec(
+		`UPDATE suppliers SET name = ?, phone = ?, city = ?, categories = ?, rating = ?, active = ?,
+		   channel = ?, telegram_id = ?, email = ?, hours = ?, timezone = ?, branches = ?,
+		   street = ?, lat = ?, lng = ?, delivery_radius = ?
+		 WHERE id = ? AND deleted_at IS NULL`,
+		sup.Name, NormalizePhone(sup.Phone), sup.City, string(cats), sup.Rating, sup.Active,
+		sup.Channel, sup.TelegramID, sup.Email, sup.Hours, sup.Timezone, string(branches),
+		sup.Street, sup.Geo.Lat, sup.Geo.Lng, sup.DeliveryRadius, sup.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update supplier: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("fornecedor %s não encontrado", sup.ID)
+	}
+	return nil
+}
+
+// Remove takes a supplier out of every list. The row is kept (inactive,
+// with deleted_at set) so quotes and messages that refer to it still
+// resolve.
+func (s *Store) Remove(id string) error {
+	_, err := s.db.Exec(`UPDATE suppliers SET active = 0, deleted_at = ? WHERE id = ?`, time.Now(), id)
+	return err
+}
+
+// SetActive activates or deactivates a supplier; inactive suppliers are
+// never matched to requests.
+func (s *Store) SetActive(id string, active bool) error {
+	_, err := s.db.Exec(`UPDATE suppliers SET active = ? WHERE id = ?`, active, id)
+	return err
+}
+
+// ByPhone returns the supplier matching a phone number, compared in
+// canonical form so "67 99999-0000" matches a reply from 5567999990000.
 func (s *Store) ByPhone(phone string) (*Supplier, error) {
 	all, err := s.List()
 	if err != nil {
 		return nil, err
 	}
-	phone = normalizePhone(phone)
+	phone = phoneKey(phone)
 	for _, sup := range all {
-		if normalizePhone(sup.Phone) == phone {
+		if phoneKey(sup.Phone) == phone {
 			return &sup, nil
 		}
 	}
 	return nil, nil
 }
 
-func normalizePhone(phone string) string {
+// ByAddress returns the supplier an incoming message came from: a
+// "telegram:<chat id>" address matches TelegramID, "email:<address>" the
+// e-mail (case-insensitively), anything else the phone.
+func (s *Store) ByAddress(addr string) (*Supplier, error) {
+	ch, id := whatsapp.SplitAddress(addr)
+	if ch == whatsapp.ChannelWhatsApp {
+		return s.ByPhone(id)
+	}
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, sup := range all {
+		switch {
+		case ch == whatsapp.ChannelTelegram && sup.TelegramID != "" && sup.TelegramID == id,
+			ch == whatsapp.ChannelEmail && sup.Email != "" && strings.EqualFold(sup.Email, id):
+			return &sup, nil
+		}
+	}
+	return nil, nil
+}
+
+// Validate checks that a supplier can be saved: it needs a name, an address
+// on its preferred channel, a valid schedule and timezone, and valid
+// coordinates when located.
+func (s Supplier) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("informe o nome do fornecedor")
+	}
+	switch s.Channel {
+	case "", whatsapp.ChannelWhatsApp:
+		if NormalizePhone(s.Phone) == "" {
+			return fmt.Errorf("%s: informe o telefone", s.Name)
+		}
+	case whatsapp.ChannelTelegram:
+		if s.TelegramID == "" {
+			return fmt.Errorf("%s: canal telegram sem chat ID", s.Name)
+		}
+	case whatsapp.ChannelEmail:
+		if !strings.Contains(s.Email, "@") {
+			return fmt.Errorf("%s: canal email sem e-mail válido", s.Name)
+		}
+	default:
+		return fmt.Errorf("canal inválido: %q", s.Channel)
+	}
+	if _, err := ParseHours(s.Hours); err != nil {
+		return err
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("fuso horário %q inválido", s.Timezone)
+		}
+	}
+	if err := s.Geo.Check(); err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+	if s.DeliveryRadius < 0 {
+		return fmt.Errorf("%s: raio de entrega negativo", s.Name)
+	}
+	return nil
+}
+
+// phoneKey is the form supplier phones are stored and compared in: digits
+// with the country code, DefaultCountryCode for national numbers. Phones
+// saved before it as national numbers still compare equal.
+func phoneKey(phone string) string {
+	return CanonicalPhone(phone, DefaultCountryCode)
+}
+
+// NormalizePhone keeps only the digits of a phone number.
+func NormalizePhone(phone string) string {
 	var out []byte
 	for i := 0; i < len(phone); i++ {
 		if phone[i] >= '0' && phone[i] <= '9' {
@@ -156,14 +456,21 @@ func scanSupplier(row *sql.Row) (*Supplier, error) {
 
 func scanSupplierRow(row rowScanner) (*Supplier, error) {
 	var sup Supplier
-	var catsJSON string
-	err := row.Scan(&sup.ID, &sup.Name, &sup.Phone, &sup.City, &catsJSON, &sup.Rating, &sup.Active)
+	var catsJSON, branchesJSON string
+	var telegramID, email sql.NullString
+	err := row.Scan(&sup.ID, &sup.Name, &sup.Phone, &sup.City, &catsJSON, &sup.Rating, &sup.Active, &sup.Channel, &telegramID, &email,
+		&sup.Hours, &sup.Timezone, &branchesJSON, &sup.Street, &sup.Geo.Lat, &sup.Geo.Lng, &sup.DeliveryRadius)
 	if err != nil {
 		return nil, err
 	}
+	sup.TelegramID = telegramID.String
+	sup.Email = email.String
 	if err := json.Unmarshal([]byte(catsJSON), &sup.Categories); err != nil {
 		sup.Categories = nil
 	}
+	if err := json.Unmarshal([]byte(branchesJSON), &sup.Branches); err != nil {
+		sup.Branches = nil
+	}
 	return &sup, nil
 }
 
@@ -186,6 +493,7 @@ type Quote struct {
 	Response    string
 	Price       float64
 	Status      string // pending/received/accepted/rejected
+	Attachments []string // media files received with the response
 	CreatedAt   time.Time
 	RespondedAt *time.Time
 }
@@ -222,36 +530,195 @@ func (qs *QuoteStore) UpdateQuoteResponse(id, response string, price float64) er
 	return err
 }
 
-// UpdateBySupplier records a response for the most recent pending quote from a supplier.
-func (qs *QuoteStore) UpdateBySupplier(supplierID, response string) error {
+// UpdateBySupplier records a supplier message that does not say which
+// request it answers on the supplier's most recent quote of a request still
+// open: a pending quote becomes received, and follow-up messages (e.g. a
+// photo of the price list after a greeting) are appended to it.
+// attachments are paths of media files received with the message.
+func (qs *QuoteStore) UpdateBySupplier(supplierID, response string, attachments []string) error {
+	return qs.update(
+		`SELECT q.id, q.status, COALESCE(q.response,''), q.attachments FROM quotes q
+		 JOIN quote_requests r ON r.id = q.request_id
+		 WHERE q.supplier_id = ? AND r.status = 'open' AND q.status IN ('pending', 'received')
+		 ORDER BY q.created_at DESC LIMIT 1`,
+		response, attachments, supplierID,
+	)
+}
+
+// UpdateForRequest records a supplier message on its quote of one request,
+// for replies that identify the request they answer (e.g. an e-mail
+// thread): a pending quote becomes received, and follow-ups are appended
+// while the request is still open.
+func (qs *QuoteStore) UpdateForRequest(requestID, supplierID, response string, attachments []string) error {
+	return qs.update(
+		`SELECT id, status, COALESCE(response,''), attachments FROM quotes
+		 WHERE supplier_id = ? AND request_id = ? AND (status = 'pending' OR (status = 'received' AND
+		   request_id IN (SELECT id FROM quote_requests WHERE status = 'open')))`,
+		response, attachments, supplierID, requestID,
+	)
+}
+
+// update records response on the quotes selected by query, which returns
+// their id, status, response and attachments.
+func (qs *QuoteStore) update(query, response string, attachments []string, args ...any) error {
+	now := time.Now()
+	rows, err := qs.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	type update struct {
+		id, response string
+		attachments  []string
+	}
+	var updates []update
+	for rows.Next() {
+		var id, status, prev, attJSON string
+		if err := rows.Scan(&id, &status, &prev, &attJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var att []string
+		_ = json.Unmarshal([]byte(attJSON), &att)
+		u := update{id: id, response: response, attachments: append(att, attachments...)}
+		if status == "received" && prev != "" {
+			u.response = prev + "\n" + response
+		}
+		updates = append(updates, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		att, _ := json.Marshal(u.attachments)
+		if att == nil || string(att) == "null" {
+			att = []byte("[]")
+		}
+		_, err := qs.db.Exec(
+			`UPDATE quotes SET response = ?, attachments = ?, status = 'received',
+			 responded_at = COALESCE(responded_at, ?) WHERE id = ?`,
+			u.response, string(att), now, u.id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReceivedByRequest returns the answered quotes of a request in arrival order.
+// The position in this list is the option number shown to the owner.
+func (qs *QuoteStore) ReceivedByRequest(requestID string) ([]Quote, error) {
+	rows, err := qs.db.Query(
+		`SELECT id, request_id, supplier_id, items, COALESCE(response,''), COALESCE(price,0), status, attachments, created_at
+		 FROM quotes WHERE request_id = ? AND status IN ('received', 'accepted', 'rejected') AND response IS NOT NULL
+		 ORDER BY responded_at, created_at`, requestID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanQuotes(rows)
+}
+
+// SetPrice records the total a quote came to, as read from its response.
+func (qs *QuoteStore) SetPrice(id string, price float64) error {
+	_, err := qs.db.Exec(`UPDATE quotes SET price = ? WHERE id = ?`, price, id)
+	return err
+}
+
+// SetStatus changes the status of a single quote.
+func (qs *QuoteStore) SetStatus(id, status string) error {
+	_, err := qs.db.Exec(`UPDATE quotes SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// RejectOthers marks every quote of a request except keepID as rejected.
+// Pass an empty keepID to reject them all (e.g. when a request is cancelled).
+func (qs *QuoteStore) RejectOthers(requestID, keepID string) error {
 	_, err := qs.db.Exec(
-		`UPDATE quotes SET response = ?, status = 'received', responded_at = ?
-		 WHERE supplier_id = ? AND status = 'pending'`,
-		response, time.Now(), supplierID,
+		`UPDATE quotes SET status = 'rejected' WHERE request_id = ? AND id != ?`,
+		requestID, keepID,
 	)
 	return err
 }
 
+// BySupplier returns every quote asked of a supplier, newest first.
+func (qs *QuoteStore) BySupplier(supplierID string) ([]Quote, error) {
+	rows, err := qs.db.Query(
+		`SELECT id, request_id, supplier_id, items, COALESCE(response,''), COALESCE(price,0), status, attachments, created_at
+		 FROM quotes WHERE supplier_id = ? ORDER BY created_at DESC`, supplierID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanQuotes(rows)
+}
+
+// Asked is how often a supplier was asked to quote.
+type Asked struct {
+	Count  int       // ever
+	Recent int       // since the time given to AskedCounts
+	Last   time.Time // when it was last asked
+}
+
+// AskedCounts returns, per supplier, the quotes ever asked of it and how
+// many of them since recent, in one pass over the quotes. Suppliers never
+// asked are absent.
+func (qs *QuoteStore) AskedCounts(recent time.Time) (map[string]Asked, error) {
+	rows, err := qs.db.Query(`SELECT supplier_id, created_at FROM quotes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]Asked)
+	for rows.Next() {
+		var id string
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		a := out[id]
+		a.Count++
+		// Dates are stored as text; compare them here
+		if !at.Before(recent) {
+			a.Recent++
+		}
+		if at.After(a.Last) {
+			a.Last = at
+		}
+		out[id] = a
+	}
+	return out, rows.Err()
+}
+
 // PendingByRequest returns all pending quotes for a request.
 func (qs *QuoteStore) PendingByRequest(requestID string) ([]Quote, error) {
 	rows, err := qs.db.Query(
-		`SELECT id, request_id, supplier_id, items, COALESCE(response,''), COALESCE(price,0), status, created_at
+		`SELECT id, request_id, supplier_id, items, COALESCE(response,''), COALESCE(price,0), status, attachments, created_at
 		 FROM quotes WHERE request_id = ?`, requestID,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanQuotes(rows)
+}
 
+func scanQuotes(rows *sql.Rows) ([]Quote, error) {
 	var quotes []Quote
 	for rows.Next() {
 		var q Quote
-		var itemsJSON string
-		err := rows.Scan(&q.ID, &q.RequestID, &q.SupplierID, &itemsJSON, &q.Response, &q.Price, &q.Status, &q.CreatedAt)
+		var itemsJSON, attJSON string
+		err := rows.Scan(&q.ID, &q.RequestID, &q.SupplierID, &itemsJSON, &q.Response, &q.Price, &q.Status, &attJSON, &q.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal([]byte(itemsJSON), &q.Items)
+		_ = json.Unmarshal([]byte(attJSON), &q.Attachments)
 		quotes = append(quotes, q)
 	}
 	return quotes, rows.Err()
@@ -0,0 +1,116 @@
+package suppliers
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/agente/internal/db"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func addRequest(t *testing.T, d *sql.DB, id, status string, created time.Time) {
+	t.Helper()
+	_, err := d.Exec(
+		`INSERT INTO quote_requests (id, description, items, status, deadline, created_at) VALUES (?, ?, '[]', ?, ?, ?)`,
+		id, id, status, created.Add(time.Hour), created,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func quoteResponse(t *testing.T, d *sql.DB, id string) (status, response string) {
+	t.Helper()
+	err := d.QueryRow(`SELECT status, COALESCE(response,'') FROM quotes WHERE id = ?`, id).Scan(&status, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, response
+}
+
+func TestUpdateBySupplierAnswersLatestOpenRequest(t *testing.T) {
+	d := openTestDB(t)
+	qs := NewQuoteStore(d)
+	now := time.Now()
+
+	addRequest(t, d, "closed", "closed", now.Add(-3*time.Hour))
+	addRequest(t, d, "older", "open", now.Add(-2*time.Hour))
+	addRequest(t, d, "newer", "open", now.Add(-time.Hour))
+	for _, q := range []Quote{
+		{ID: "q-closed", RequestID: "closed", SupplierID: "s1", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "q-older", RequestID: "older", SupplierID: "s1", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "q-newer", RequestID: "newer", SupplierID: "s1", CreatedAt: now.Add(-time.Hour)},
+		{ID: "q-other", RequestID: "newer", SupplierID: "s2", CreatedAt: now.Add(-time.Hour)},
+	} {
+		if err := qs.CreateQuote(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := qs.UpdateBySupplier("s1", "arroz R$ 25", nil); err != nil {
+		t.Fatal(err)
+	}
+	if status, resp := quoteResponse(t, d, "q-newer"); status != "received" || resp != "arroz R$ 25" {
+		t.Errorf("newest open request: got %s %q, want received", status, resp)
+	}
+	for _, id := range []string{"q-older", "q-closed", "q-other"} {
+		if status, resp := quoteResponse(t, d, id); status != "pending" || resp != "" {
+			t.Errorf("%s: got %s %q, want untouched", id, status, resp)
+		}
+	}
+
+	// A follow-up is appended to the same quote
+	if err := qs.UpdateBySupplier("s1", "entrega amanhã", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, resp := quoteResponse(t, d, "q-newer"); resp != "arroz R$ 25\nentrega amanhã" {
+		t.Errorf("follow-up: got %q", resp)
+	}
+
+	// A threaded reply goes to the request it names
+	if err := qs.UpdateForRequest("older", "s1", "feijão R$ 8", nil); err != nil {
+		t.Fatal(err)
+	}
+	if status, resp := quoteResponse(t, d, "q-older"); status != "received" || resp != "feijão R$ 8" {
+		t.Errorf("threaded reply: got %s %q", status, resp)
+	}
+}
+
+func TestPhonesAreUniqueInCanonicalForm(t *testing.T) {
+	s := NewStore(openTestDB(t))
+
+	id, err := s.Add(Supplier{Name: "Atacadão", Phone: "67 99999-0000", City: "Campo Grande", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sup, _ := s.Get(id); sup.Phone != "5567999990000" {
+		t.Errorf("stored phone %q, want 5567999990000", sup.Phone)
+	}
+	if _, err := s.Add(Supplier{Name: "Atacadão Centro", Phone: "+55 67 99999-0000", City: "Campo Grande", Active: true}); !errors.Is(err, ErrDuplicatePhone) {
+		t.Errorf("same number with country code: got %v, want ErrDuplicatePhone", err)
+	}
+
+	// A supplier saved before phones were canonical, as a national number
+	old, err := insert(s.db, Supplier{Name: "Fort", Phone: "67988880000", City: "Campo Grande", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sup, err := s.ByPhone("5567988880000"); err != nil || sup == nil || sup.ID != old {
+		t.Errorf("reply from the JID number: got %+v, %v", sup, err)
+	}
+	if _, err := s.Add(Supplier{Name: "Fort 2", Phone: "+5567988880000", City: "Campo Grande", Active: true}); !errors.Is(err, ErrDuplicatePhone) {
+		t.Errorf("national number saved before: got %v, want ErrDuplicatePhone", err)
+	}
+}
@@ -0,0 +1,571 @@
+package suppliers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File formats for importing and exporting suppliers.
+const (
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatVCard = "vcard"
+)
+
+// Record is a supplier as read from or written to a file. Fields left empty
+// in a file keep the current value when the record updates an existing
+// supplier; Rating, Active, the coordinates and the delivery radius are
+// pointers for the same reason.
+type Record struct {
+	ID         string   `json:"id,omitempty"`
+	Name       string   `json:"name"`
+	Phone      string   `json:"phone,omitempty"`
+	City       string   `json:"city,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Rating     *float64 `json:"rating,omitempty"`
+	Active     *bool    `json:"active,omitempty"`
+	Channel    string   `json:"channel,omitempty"`
+	TelegramID string   `json:"telegram_id,omitempty"`
+	Email      string   `json:"email,omitempty"`
+	Hours      string   `json:"hours,omitempty"`
+	Timezone   string   `json:"timezone,omitempty"`
+	Branches   []string `json:"branches,omitempty"`
+	Street     string   `json:"street,omitempty"`
+	Lat        *float64 `json:"lat,omitempty"`
+	Lng        *float64 `json:"lng,omitempty"`
+	Radius     *float64 `json:"delivery_radius,omitempty"` // km
+
+	Line int `json:"-"` // position in the source file (CSV line, JSON index or vCard number), for messages
+}
+
+// RecordOf converts a supplier for export.
+func RecordOf(s Supplier) Record {
+	rating, active := s.Rating, s.Active
+	rec := Record{
+		ID: s.ID, Name: s.Name, Phone: s.Phone, City: s.City, Categories: s.Categories,
+		Rating: &rating, Active: &active, Channel: s.Channel, TelegramID: s.TelegramID,
+		Email: s.Email, Hours: s.Hours, Timezone: s.Timezone, Branches: s.Branches, Street: s.Street,
+	}
+	if !s.Geo.IsZero() {
+		lat, lng := s.Geo.Lat, s.Geo.Lng
+		rec.Lat, rec.Lng = &lat, &lng
+	}
+	if s.DeliveryRadius > 0 {
+		radius := s.DeliveryRadius
+		rec.Radius = &radius
+	}
+	return rec
+}
+
+// FormatOf returns the format named by format, or, when it is empty, the one
+// implied by the extension of path.
+func FormatOf(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch strings.ToLower(format) {
+	case "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	case "vcf", "vcard":
+		return FormatVCard, nil
+	case "":
+		return "", fmt.Errorf("informe o formato (csv, json ou vcard)")
+	}
+	return "", fmt.Errorf("formato %q não suportado; use csv, json ou vcard", format)
+}
+
+// ReadRecords decodes suppliers in the given format.
+func ReadRecords(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatJSON:
+		return readJSON(r)
+	case FormatVCard:
+		return readVCards(r)
+	}
+	return nil, fmt.Errorf("formato %q não suportado", format)
+}
+
+// WriteRecords encodes suppliers in the given format.
+func WriteRecords(w io.Writer, format string, recs []Record) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, recs)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if recs == nil {
+			recs = []Record{}
+		}
+		return enc.Encode(recs)
+	case FormatVCard:
+		return writeVCards(w, recs)
+	}
+	return fmt.Errorf("formato %q não suportado", format)
+}
+
+// csvColumns is the header written on export, in order.
+var csvColumns = []string{"id", "name", "phone", "city", "categories", "rating", "active",
+	"channel", "telegram_id", "email", "hours", "timezone", "branches", "street", "lat", "lng", "delivery_radius"}
+
+// csvAliases maps the header names accepted on import, English or
+// Portuguese, to csvColumns.
+var csvAliases = map[string]string{
+	"nome": "name", "fornecedor": "name",
+	"telefone": "phone", "fone": "phone", "celular": "phone", "whatsapp": "phone",
+	"cidade":    "city",
+	"categoria": "categories", "categorias": "categories", "category": "categories",
+	"nota": "rating", "avaliacao": "rating",
+	"ativo":    "active",
+	"canal":    "channel",
+	"telegram": "telegram_id",
+	"e-mail":   "email",
+	"horario":  "hours", "horarios": "hours", "horario_atendimento": "hours",
+	"fuso": "timezone", "fuso_horario": "timezone",
+	"lojas": "branches", "filiais": "branches",
+	"endereco": "street", "address": "street", "rua": "street",
+	"latitude": "lat", "longitude": "lng", "lon": "lng", "long": "lng",
+	"raio": "delivery_radius", "raio_entrega": "delivery_radius", "raio_de_entrega": "delivery_radius", "entrega_km": "delivery_radius",
+}
+
+func readCSV(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	// Spreadsheets set to Portuguese save with ";" between columns
+	first, _ := br.Peek(4096)
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	cr := csv.NewReader(br)
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ler cabeçalho do CSV: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		h = strings.NewReplacer(" ", "_", "á", "a", "ã", "a", "ç", "c", "ó", "o").Replace(h)
+		if alias, ok := csvAliases[h]; ok {
+			h = alias
+		}
+		cols[h] = i
+	}
+	if _, ok := cols["name"]; !ok {
+		return nil, fmt.Errorf("CSV sem coluna de nome (name ou nome)")
+	}
+
+	var recs []Record
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ler CSV: %w", err)
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		rec := Record{
+			ID: get("id"), Name: get("name"), Phone: get("phone"), City: get("city"),
+			Categories: splitCategories(get("categories")), Channel: strings.ToLower(get("channel")),
+			TelegramID: get("telegram_id"), Email: get("email"), Hours: get("hours"), Timezone: get("timezone"),
+			Branches: splitBranches(get("branches")), Street: get("street"), Line: line,
+		}
+		if v := get("rating"); v != "" {
+			f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+			if err != nil {
+				return nil, fmt.Errorf("linha %d: nota inválida %q", line, v)
+			}
+			rec.Rating = &f
+		}
+		for _, n := range []struct {
+			col  string
+			dst  **float64
+			what string
+		}{{"lat", &rec.Lat, "latitude"}, {"lng", &rec.Lng, "longitude"}, {"delivery_radius", &rec.Radius, "raio de entrega"}} {
+			if v := get(n.col); v != "" {
+				f, err := parseDecimal(v)
+				if err != nil {
+					return nil, fmt.Errorf("linha %d: %s inválida %q", line, n.what, v)
+				}
+				*n.dst = &f
+			}
+		}
+		if v := get("active"); v != "" {
+			b, err := parseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("linha %d: %w", line, err)
+			}
+			rec.Active = &b
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+func writeCSV(w io.Writer, recs []Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, r := range recs {
+		active := ""
+		if r.Active != nil {
+			active = strconv.FormatBool(*r.Active)
+		}
+		if err := cw.Write([]string{r.ID, r.Name, r.Phone, r.City, strings.Join(r.Categories, ","), formatFloat(r.Rating), active,
+			r.Channel, r.TelegramID, r.Email, r.Hours, r.Timezone, strings.Join(r.Branches, "|"),
+			r.Street, formatFloat(r.Lat), formatFloat(r.Lng), formatFloat(r.Radius)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatFloat writes an optional number, empty when unset.
+func formatFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func readJSON(r io.Reader) ([]Record, error) {
+	var recs []Record
+	if err := json.NewDecoder(r).Decode(&recs); err != nil {
+		return nil, fmt.Errorf("ler JSON (esperada uma lista de fornecedores): %w", err)
+	}
+	for i := range recs {
+		recs[i].Line = i + 1
+		recs[i].Channel = strings.ToLower(recs[i].Channel)
+	}
+	return recs, nil
+}
+
+// vCard properties carrying the supplier fields a phone contact has no
+// place for, so an export can be imported back unchanged.
+const (
+	vcardID       = "X-COMPRADOR-ID"
+	vcardRating   = "X-COMPRADOR-RATING"
+	vcardActive   = "X-COMPRADOR-ACTIVE"
+	vcardChannel  = "X-COMPRADOR-CHANNEL"
+	vcardTelegram = "X-COMPRADOR-TELEGRAM"
+	vcardHours    = "X-COMPRADOR-HOURS"
+	vcardTimezone = "X-COMPRADOR-TIMEZONE"
+	vcardBranches = "X-COMPRADOR-BRANCHES"
+	vcardRadius   = "X-COMPRADOR-DELIVERY-RADIUS"
+)
+
+// vcardProp is one "NAME;PARAM=X:value" line of a vCard.
+type vcardProp struct {
+	name   string
+	params string // upper-cased, e.g. ";TYPE=CELL"
+	value  string
+}
+
+// readVCards reads contacts as exported by a phone's address book (vCard 2.1,
+// 3.0 or 4.0). The name comes from FN (or N, or ORG), the phone from the
+// first mobile TEL (or the first TEL), the street and city from ADR and the
+// coordinates from GEO.
+func readVCards(r io.Reader) ([]Record, error) {
+	lines, err := unfoldVCard(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		recs  []Record
+		props []vcardProp
+		in    bool
+	)
+	for _, line := range lines {
+		p, ok := parseVCardLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			in, props = true, nil
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if in {
+				rec, err := vcardRecord(props)
+				if err != nil {
+					return nil, fmt.Errorf("contato %d: %w", len(recs)+1, err)
+				}
+				rec.Line = len(recs) + 1
+				recs = append(recs, rec)
+			}
+			in = false
+		case in:
+			props = append(props, p)
+		}
+	}
+	return recs, nil
+}
+
+// unfoldVCard joins continuation lines: a line starting with a space or tab
+// continues the previous one, as does a quoted-printable line ending in "=".
+func unfoldVCard(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		n := len(lines)
+		switch {
+		case n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			lines[n-1] += line[1:]
+		case n > 0 && strings.HasSuffix(lines[n-1], "=") && strings.Contains(strings.ToUpper(lines[n-1]), "QUOTED-PRINTABLE"):
+			lines[n-1] = lines[n-1][:len(lines[n-1])-1] + line
+		default:
+			lines = append(lines, strings.TrimPrefix(line, "\ufeff"))
+		}
+	}
+	return lines, sc.Err()
+}
+
+func parseVCardLine(line string) (vcardProp, bool) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return vcardProp{}, false
+	}
+	head, value := line[:i], line[i+1:]
+	name, params := head, ""
+	if j := strings.IndexByte(head, ';'); j >= 0 {
+		name, params = head[:j], strings.ToUpper(head[j:])
+	}
+	// Grouped properties ("item1.TEL") are treated as plain ones
+	if j := strings.LastIndexByte(name, '.'); j >= 0 {
+		name = name[j+1:]
+	}
+	if strings.Contains(params, "QUOTED-PRINTABLE") {
+		if b, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value))); err == nil {
+			value = string(b)
+		}
+	}
+	return vcardProp{name: strings.ToUpper(name), params: params, value: value}, true
+}
+
+func vcardRecord(props []vcardProp) (Record, error) {
+	var rec Record
+	var n, org, cell, tel string
+	for _, p := range props {
+		switch p.name {
+		case "FN":
+			rec.Name = vcardUnescape(p.value)
+		case "N":
+			// Family;Given;Additional;Prefix;Suffix
+			parts := vcardSplit(p.value, ';')
+			var given []string
+			for _, i := range []int{1, 2, 0} {
+				if i < len(parts) && parts[i] != "" {
+					given = append(given, parts[i])
+				}
+			}
+			n = strings.Join(given, " ")
+		case "ORG":
+			org = strings.Join(vcardSplit(p.value, ';'), " ")
+		case "TEL":
+			v := strings.TrimPrefix(vcardUnescape(p.value), "tel:")
+			if tel == "" {
+				tel = v
+			}
+			if cell == "" && strings.Contains(p.params, "CELL") {
+				cell = v
+			}
+		case "EMAIL":
+			if rec.Email == "" {
+				rec.Email = vcardUnescape(p.value)
+			}
+		case "ADR":
+			// PO box;Extended;Street;Locality;Region;Postal code;Country
+			if parts := vcardSplit(p.value, ';'); len(parts) > 3 && rec.City == "" {
+				rec.Street, rec.City = parts[2], parts[3]
+			}
+		case "GEO":
+			// "geo:-20.46,-54.62" in vCard 4.0, "-20.46;-54.62" before
+			pt, err := ParsePoint(strings.TrimPrefix(strings.ToLower(p.value), "geo:"))
+			if err != nil {
+				return rec, err
+			}
+			rec.Lat, rec.Lng = &pt.Lat, &pt.Lng
+		case "CATEGORIES":
+			rec.Categories = splitCategories(vcardUnescape(p.value))
+		case vcardID:
+			rec.ID = vcardUnescape(p.value)
+		case vcardRating:
+			f, err := strconv.ParseFloat(p.value, 64)
+			if err != nil {
+				return rec, fmt.Errorf("nota inválida %q", p.value)
+			}
+			rec.Rating = &f
+		case vcardActive:
+			b, err := parseBool(p.value)
+			if err != nil {
+				return rec, err
+			}
+			rec.Active = &b
+		case vcardChannel:
+			rec.Channel = strings.ToLower(p.value)
+		case vcardTelegram:
+			rec.TelegramID = vcardUnescape(p.value)
+		case vcardHours:
+			rec.Hours = vcardUnescape(p.value)
+		case vcardTimezone:
+			rec.Timezone = vcardUnescape(p.value)
+		case vcardBranches:
+			rec.Branches = splitBranches(vcardUnescape(p.value))
+		case vcardRadius:
+			f, err := parseDecimal(p.value)
+			if err != nil {
+				return rec, fmt.Errorf("raio de entrega inválido %q", p.value)
+			}
+			rec.Radius = &f
+		}
+	}
+	if rec.Name == "" {
+		rec.Name = n
+	}
+	if rec.Name == "" {
+		rec.Name = org
+	}
+	rec.Phone = tel
+	if cell != "" {
+		rec.Phone = cell
+	}
+	return rec, nil
+}
+
+func writeVCards(w io.Writer, recs []Record) error {
+	bw := bufio.NewWriter(w)
+	prop := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(bw, "%s:%s\r\n", name, vcardEscape(value))
+		}
+	}
+	for _, r := range recs {
+		bw.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+		prop("FN", r.Name)
+		prop("ORG", r.Name)
+		prop("TEL;TYPE=CELL", r.Phone)
+		prop("EMAIL", r.Email)
+		if r.City != "" || r.Street != "" {
+			fmt.Fprintf(bw, "ADR;TYPE=WORK:;;%s;%s;;;\r\n", vcardEscape(r.Street), vcardEscape(r.City))
+		}
+		if r.Lat != nil && r.Lng != nil {
+			fmt.Fprintf(bw, "GEO:%s;%s\r\n", formatFloat(r.Lat), formatFloat(r.Lng))
+		}
+		if len(r.Categories) > 0 {
+			cats := make([]string, len(r.Categories))
+			for i, c := range r.Categories {
+				cats[i] = vcardEscape(c)
+			}
+			fmt.Fprintf(bw, "CATEGORIES:%s\r\n", strings.Join(cats, ","))
+		}
+		prop(vcardID, r.ID)
+		if r.Rating != nil {
+			prop(vcardRating, strconv.FormatFloat(*r.Rating, 'f', -1, 64))
+		}
+		if r.Active != nil {
+			prop(vcardActive, strconv.FormatBool(*r.Active))
+		}
+		prop(vcardChannel, r.Channel)
+		prop(vcardTelegram, r.TelegramID)
+		prop(vcardHours, r.Hours)
+		prop(vcardTimezone, r.Timezone)
+		prop(vcardBranches, strings.Join(r.Branches, "|"))
+		prop(vcardRadius, formatFloat(r.Radius))
+		bw.WriteString("END:VCARD\r\n")
+	}
+	return bw.Flush()
+}
+
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\n", `\n`).Replace(s)
+}
+
+func vcardUnescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// vcardSplit splits a structured value on unescaped sep and unescapes the
+// parts.
+func vcardSplit(s string, sep byte) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			cur.WriteByte(s[i])
+			cur.WriteByte(s[i+1])
+			i++
+		case s[i] == sep:
+			parts = append(parts, strings.TrimSpace(vcardUnescape(cur.String())))
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(parts, strings.TrimSpace(vcardUnescape(cur.String())))
+}
+
+// splitCategories splits a category list written with commas, semicolons or
+// bars.
+func splitCategories(s string) []string {
+	var out []string
+	for _, c := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// splitBranches splits a branch list written with bars or semicolons;
+// branch names may hold commas ("Av. Bandeirantes, 100").
+func splitBranches(s string) []string {
+	var out []string
+	for _, b := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == '|' }) {
+		if b = strings.TrimSpace(b); b != "" {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "sim", "s", "yes", "y", "ativo":
+		return true, nil
+	case "0", "false", "nao", "não", "n", "no", "inativo":
+		return false, nil
+	}
+	return false, fmt.Errorf("valor inválido para ativo: %q (use sim ou não)", s)
+}
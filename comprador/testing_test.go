@@ -0,0 +1,21 @@
+package comprador
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/user/agente/internal/db"
+)
+
+// newTestAgent returns a dry-run agent over a fresh database, without a
+// model client.
+func newTestAgent(t *testing.T, cfg Config) *Agent {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "comprador.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	cfg.DryRun = true
+	return New(d, nil, cfg)
+}
@@ -0,0 +1,61 @@
+// Package alert reaches the owner through a channel other than WhatsApp, for
+// the cases where WhatsApp itself is what broke.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Alerter delivers an out-of-band alert to the owner.
+type Alerter interface {
+	Alert(ctx context.Context, title, message string) error
+}
+
+// Webhook posts alerts to an HTTP endpoint. The format follows ntfy.sh
+// (plain-text body, title in the Title header), so a topic URL such as
+// https://ntfy.sh/my-topic pushes to the owner's phone; any endpoint that
+// accepts a text POST works too.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook creates an alerter that posts to url.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (w *Webhook) Alert(ctx context.Context, title, message string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Title", title)
+	req.Header.Set("Priority", "high")
+	req.Header.Set("Tags", "warning")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alerta: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alerta: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Stdout prints alerts to the terminal; used when no other channel is set.
+type Stdout struct{}
+
+func (Stdout) Alert(_ context.Context, title, message string) error {
+	fmt.Printf("\n[ALERTA] %s\n%s\n\n", title, message)
+	return nil
+}
@@ -2,9 +2,14 @@ package claude
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -13,17 +18,20 @@ import (
 )
 
 const (
-	defaultModel      = "deepseek/deepseek-chat-v3-0324"
-	openRouterBaseURL = "https://openrouter.ai/api/v1"
+	defaultModel       = "deepseek/deepseek-chat-v3-0324"
+	defaultVisionModel = "google/gemini-2.0-flash-001"
+	openRouterBaseURL  = "https://openrouter.ai/api/v1"
 )
 
 // Client wraps openai-go pointed at OpenRouter.
 type Client struct {
-	inner openai.Client
-	model string
+	inner       openai.Client
+	model       string
+	visionModel string // used instead of model when a request carries images
 }
 
 // New creates a Client using OPENROUTER_API_KEY from env.
+// OPENROUTER_VISION_MODEL optionally overrides the model used for images.
 func New() (*Client, error) {
 	key := os.Getenv("OPENROUTER_API_KEY")
 	if key == "" {
@@ -33,7 +41,11 @@ func New() (*Client, error) {
 		option.WithAPIKey(key),
 		option.WithBaseURL(openRouterBaseURL),
 	)
-	return &Client{inner: c, model: defaultModel}, nil
+	vision := os.Getenv("OPENROUTER_VISION_MODEL")
+	if vision == "" {
+		vision = defaultVisionModel
+	}
+	return &Client{inner: c, model: defaultModel, visionModel: vision}, nil
 }
 
 // ToolDef defines a tool available to the model.
@@ -43,16 +55,30 @@ type ToolDef struct {
 	InputSchema any
 }
 
-// ChatRequest is a single-turn request with optional tools.
+// Message is a previous turn of a conversation.
+type Message struct {
+	Role    string // "user" or "assistant"
+	Content string
+}
+
+// ChatRequest is a request with optional tools. History, if set, holds the
+// earlier turns of the conversation and is sent before User. Images are local
+// file paths attached to the User message; they route the request to the
+// vision model.
 type ChatRequest struct {
-	System string
-	User   string
-	Tools  []ToolDef
+	System  string
+	History []Message
+	User    string
+	Images  []string
+	Tools   []ToolDef
 }
 
 // Chat sends a message and returns the model's text response.
 func (c *Client) Chat(ctx context.Context, req ChatRequest) (string, error) {
-	params := c.buildParams(req)
+	params, err := c.buildParams(req)
+	if err != nil {
+		return "", err
+	}
 	resp, err := c.inner.Chat.Completions.New(ctx, params)
 	if err != nil {
 		return "", fmt.Errorf("openrouter api: %w", err)
@@ -70,7 +96,10 @@ func (c *Client) ChatWithTools(
 	req ChatRequest,
 	executor func(name string, input json.RawMessage) (string, error),
 ) (string, error) {
-	params := c.buildParams(req)
+	params, err := c.buildParams(req)
+	if err != nil {
+		return "", err
+	}
 
 	for {
 		resp, err := c.inner.Chat.Completions.New(ctx, params)
@@ -107,15 +136,39 @@ func (c *Client) ChatWithTools(
 }
 
 // buildParams constructs ChatCompletionNewParams from a ChatRequest.
-func (c *Client) buildParams(req ChatRequest) openai.ChatCompletionNewParams {
+func (c *Client) buildParams(req ChatRequest) (openai.ChatCompletionNewParams, error) {
 	var messages []openai.ChatCompletionMessageParamUnion
 	if req.System != "" {
 		messages = append(messages, openai.SystemMessage(req.System))
 	}
-	messages = append(messages, openai.UserMessage(req.User))
+	for _, m := range req.History {
+		if m.Role == "assistant" {
+			messages = append(messages, openai.AssistantMessage(m.Content))
+		} else {
+			messages = append(messages, openai.UserMessage(m.Content))
+		}
+	}
+
+	model := c.model
+	if len(req.Images) == 0 {
+		messages = append(messages, openai.UserMessage(req.User))
+	} else {
+		parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(req.User)}
+		for _, path := range req.Images {
+			url, err := imageDataURL(path)
+			if err != nil {
+				return openai.ChatCompletionNewParams{}, err
+			}
+			parts = append(parts, openai.ImageContentPart(
+				openai.ChatCompletionContentPartImageImageURLParam{URL: url},
+			))
+		}
+		messages = append(messages, openai.UserMessage(parts))
+		model = c.visionModel
+	}
 
 	params := openai.ChatCompletionNewParams{
-		Model:    openai.ChatModel(c.model),
+		Model:    openai.ChatModel(model),
 		Messages: messages,
 	}
 
@@ -123,7 +176,20 @@ func (c *Client) buildParams(req ChatRequest) openai.ChatCompletionNewParams {
 		params.Tools = buildTools(req.Tools)
 	}
 
-	return params
+	return params, nil
+}
+
+// imageDataURL inlines a local image as a base64 data URL.
+func imageDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
 }
 
 func buildTools(defs []ToolDef) []openai.ChatCompletionToolParam {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -29,8 +30,59 @@ func Open(dsn string) (*sql.DB, error) {
 }
 
 func migrate(db *sql.DB) error {
-	_, err := db.Exec(schema)
-	return err
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	for _, stmt := range alterations {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	for _, stmt := range uniqueIndexes {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// alterations upgrade databases created by earlier versions of the schema.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so "duplicate column" errors mean
+// the change is already applied. Append only; never reorder.
+var alterations = []string{
+	`ALTER TABLE purchase_memory ADD COLUMN request_id TEXT`,
+	`ALTER TABLE purchase_memory ADD COLUMN supplier_id TEXT`,
+	`ALTER TABLE quotes ADD COLUMN attachments TEXT NOT NULL DEFAULT '[]'`, // JSON array of media paths
+	`ALTER TABLE quote_requests ADD COLUMN images TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE assets ADD COLUMN photos TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE suppliers ADD COLUMN channel TEXT NOT NULL DEFAULT 'whatsapp'`, // whatsapp/telegram
+	`ALTER TABLE suppliers ADD COLUMN telegram_id TEXT`,
+	`ALTER TABLE suppliers ADD COLUMN email TEXT`,
+	`ALTER TABLE messages ADD COLUMN seen_at DATETIME`,                // inbound: when read in 'comprador inbox'
+	`ALTER TABLE suppliers ADD COLUMN hours TEXT NOT NULL DEFAULT ''`, // e.g. "seg-sex 08:00-18:00; sab 08:00-12:00"
+	`ALTER TABLE suppliers ADD COLUMN timezone TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE suppliers ADD COLUMN deleted_at DATETIME`,                 // removed: kept for quote history, hidden everywhere else
+	`ALTER TABLE suppliers ADD COLUMN branches TEXT NOT NULL DEFAULT '[]'`, // JSON array of store names sharing the phone
+	`ALTER TABLE suppliers ADD COLUMN merged_into TEXT`,                    // set when removed by 'suppliers dedupe'
+	`ALTER TABLE purchase_memory ADD COLUMN delivery_due DATETIME`,         // set on accept; the owner is asked for a rating after it
+	`ALTER TABLE purchase_memory ADD COLUMN feedback_asked_at DATETIME`,
+	`ALTER TABLE suppliers ADD COLUMN street TEXT NOT NULL DEFAULT ''`, // street address, geocoded into lat/lng
+	`ALTER TABLE suppliers ADD COLUMN lat REAL NOT NULL DEFAULT 0`,     // 0,0 = location unknown
+	`ALTER TABLE suppliers ADD COLUMN lng REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE suppliers ADD COLUMN delivery_radius REAL NOT NULL DEFAULT 0`, // km; 0 = unknown
+	`ALTER TABLE quote_requests ADD COLUMN place TEXT NOT NULL DEFAULT ''`,     // owner location delivered to
+	`ALTER TABLE quote_requests ADD COLUMN lat REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE quote_requests ADD COLUMN lng REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE quote_requests ADD COLUMN max_radius REAL NOT NULL DEFAULT 0`,          // km; 0 = any distance
+	`ALTER TABLE quote_requests ADD COLUMN compare_failures INTEGER NOT NULL DEFAULT 0`, // failed comparisons of the replies
+	`ALTER TABLE quote_requests ADD COLUMN compare_retry_at DATETIME`,                   // next comparison after a failure
+}
+
+// uniqueIndexes are created once the data satisfies them: a database from
+// before phones were unique keeps working, and gets the index on the first
+// start after 'comprador suppliers dedupe' has merged its duplicates.
+var uniqueIndexes = []string{
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_suppliers_phone ON suppliers(phone) WHERE deleted_at IS NULL AND phone <> ''`,
 }
 
 const schema = `
@@ -48,6 +100,17 @@ CREATE TABLE IF NOT EXISTS suppliers (
   active     BOOLEAN NOT NULL DEFAULT 1
 );
 
+CREATE TABLE IF NOT EXISTS quote_requests (
+  id          TEXT PRIMARY KEY,
+  description TEXT NOT NULL,
+  items       TEXT NOT NULL DEFAULT '[]', -- JSON
+  urgent      BOOLEAN NOT NULL DEFAULT 0,
+  status      TEXT NOT NULL DEFAULT 'open', -- open/closed/accepted/cancelled
+  deadline    DATETIME NOT NULL,
+  created_at  DATETIME NOT NULL,
+  closed_at   DATETIME
+);
+
 CREATE TABLE IF NOT EXISTS quotes (
   id           TEXT PRIMARY KEY,
   request_id   TEXT NOT NULL,
@@ -69,6 +132,136 @@ CREATE TABLE IF NOT EXISTS purchase_memory (
   created_at       DATETIME NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS send_log (
+  phone         TEXT NOT NULL, -- digits only
+  sent_at       DATETIME NOT NULL,
+  first_contact BOOLEAN NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_send_log_sent_at ON send_log(sent_at);
+CREATE INDEX IF NOT EXISTS idx_send_log_phone ON send_log(phone);
+
+CREATE TABLE IF NOT EXISTS messages (
+  id           INTEGER PRIMARY KEY AUTOINCREMENT,
+  wa_id        TEXT,                 -- channel message ID (WhatsApp ID, e-mail Message-ID)
+  direction    TEXT NOT NULL,        -- out/in
+  phone        TEXT NOT NULL,        -- digits only
+  body         TEXT NOT NULL DEFAULT '',
+  media_path   TEXT NOT NULL DEFAULT '',
+  request_id   TEXT,
+  supplier_id  TEXT,
+  status       TEXT NOT NULL,        -- out: sent/delivered/read/invalid; in: received
+  sent_at      DATETIME NOT NULL,
+  delivered_at DATETIME,
+  read_at      DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_messages_wa_id ON messages(wa_id);
+CREATE INDEX IF NOT EXISTS idx_messages_request ON messages(request_id);
+CREATE INDEX IF NOT EXISTS idx_messages_phone ON messages(phone);
+
+CREATE TABLE IF NOT EXISTS do_not_contact (
+  address    TEXT PRIMARY KEY,       -- normalized address (phone digits or channel:id)
+  reason     TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  request_id  TEXT,
+  supplier_id TEXT,
+  address     TEXT NOT NULL,
+  body        TEXT NOT NULL DEFAULT '',
+  media_path  TEXT NOT NULL DEFAULT '', -- set for image messages
+  not_before  DATETIME NOT NULL,         -- when the shop opens
+  status      TEXT NOT NULL DEFAULT 'pending', -- pending/sent/cancelled/failed
+  error       TEXT NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL,
+  sent_at     DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_status ON outbox(status);
+
+CREATE TABLE IF NOT EXISTS group_members (
+  phone      TEXT PRIMARY KEY,           -- digits only
+  name       TEXT NOT NULL DEFAULT '',
+  approver   BOOLEAN NOT NULL DEFAULT 0, -- may accept options above the approval limit
+  created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS supplier_feedback (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  supplier_id TEXT NOT NULL,
+  request_id  TEXT,                   -- the purchase rated, if any
+  rating      INTEGER NOT NULL,       -- 1-5
+  comment     TEXT NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_supplier_feedback_supplier ON supplier_feedback(supplier_id);
+
+CREATE TABLE IF NOT EXISTS supplier_scores (
+  supplier_id     TEXT PRIMARY KEY,
+  rating          REAL NOT NULL,               -- copied to suppliers.rating
+  asked           INTEGER NOT NULL DEFAULT 0,
+  answered        INTEGER NOT NULL DEFAULT 0,
+  median_response INTEGER NOT NULL DEFAULT 0,  -- seconds; 0 = no timed reply
+  priced          INTEGER NOT NULL DEFAULT 0,
+  price_index     REAL NOT NULL DEFAULT 0,     -- mean cheapest/own price; 1 = always the cheapest
+  decided         INTEGER NOT NULL DEFAULT 0,
+  won             INTEGER NOT NULL DEFAULT 0,
+  feedbacks       INTEGER NOT NULL DEFAULT 0,
+  feedback_avg    REAL NOT NULL DEFAULT 0,
+  updated_at      DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+  slug        TEXT PRIMARY KEY,          -- stored in suppliers.categories
+  name        TEXT NOT NULL,
+  parent      TEXT NOT NULL DEFAULT '',  -- slug; '' = top level
+  description TEXT NOT NULL DEFAULT '',
+  synonyms    TEXT NOT NULL DEFAULT '[]' -- JSON array
+);
+
+CREATE TABLE IF NOT EXISTS places (
+  name       TEXT PRIMARY KEY COLLATE NOCASE, -- e.g. "casa", "sítio"
+  address    TEXT NOT NULL DEFAULT '',
+  lat        REAL NOT NULL,
+  lng        REAL NOT NULL,
+  is_default BOOLEAN NOT NULL DEFAULT 0,      -- used by quotes that name no place
+  created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS supplier_prices (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  supplier_id TEXT NOT NULL,
+  request_id  TEXT NOT NULL,
+  quote_id    TEXT NOT NULL,   -- the reply the price was read from
+  item        TEXT NOT NULL,   -- as named in the request
+  item_key    TEXT NOT NULL,   -- item lower case, no accents, for lookups
+  unit        TEXT NOT NULL DEFAULT '',
+  price       REAL NOT NULL,   -- per unit
+  quoted_at   DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_supplier_prices_item ON supplier_prices(item_key);
+
+CREATE TABLE IF NOT EXISTS item_categories (
+  item       TEXT PRIMARY KEY,            -- item name as keyed by the matcher (lower case, no accents)
+  categories TEXT NOT NULL DEFAULT '[]',  -- JSON array; empty = no supplier category fits
+  created_at DATETIME NOT NULL
+);
+
+-- assistente
+CREATE TABLE IF NOT EXISTS assistant_messages (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  conversation_id TEXT NOT NULL,
+  role            TEXT NOT NULL, -- user/assistant
+  content         TEXT NOT NULL,
+  created_at      DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS assistant_pending (
+  conversation_id TEXT PRIMARY KEY,
+  action          TEXT NOT NULL, -- JSON: outward-facing action awaiting confirmation
+  created_at      DATETIME NOT NULL
+);
+
 -- patrimonial
 CREATE TABLE IF NOT EXISTS assets (
   id          TEXT PRIMARY KEY,
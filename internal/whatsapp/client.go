@@ -7,17 +7,143 @@ import (
 
 // MessageSender abstracts WhatsApp message delivery.
 // MockSender is used in dev/dry-run; WhatsAppSender (whatsmeow) in production.
+// The Send methods return the ID WhatsApp assigned to the message, which
+// later Receipts refer to.
 type MessageSender interface {
-	Send(phone, message string) error
-	Listen(handler func(from, msg string)) error
+	Send(phone, message string) (string, error)
+	SendImage(phone, path, caption string) (string, error)
+	SendDocument(phone, path, caption string) (string, error)
+	Listen(handler func(msg IncomingMessage)) error
+	ListenReceipts(handler func(r Receipt)) error
+	Health() Health
 	Close() error
 }
 
+// Connection states reported by Health.
+const (
+	HealthConnected    = "connected"
+	HealthConnecting   = "connecting"   // reconnecting after a drop
+	HealthDisconnected = "disconnected" // dropped; a reconnect is scheduled
+	HealthLoggedOut    = "logged_out"   // session revoked; needs a new QR pairing
+)
+
+// Health is the state of the link to WhatsApp.
+type Health struct {
+	State    string
+	Since    time.Time // when State was entered
+	Detail   string    // last error or reason, if any
+	Attempts int       // reconnect attempts since the link dropped
+}
+
+// OK reports whether messages can be sent right now.
+func (h Health) OK() bool { return h.State == HealthConnected }
+
+// Receipt statuses, in the order a message goes through them.
+const (
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+)
+
+// Receipt reports that messages we sent were delivered to or read by the recipient.
+type Receipt struct {
+	MessageIDs []string
+	From       string // recipient phone
+	Status     string // StatusDelivered or StatusRead
+	Timestamp  time.Time
+}
+
+// NumberChecker is implemented by senders that can tell whether a phone
+// number has a WhatsApp account before messaging it.
+type NumberChecker interface {
+	IsOnWhatsApp(phone string) (bool, error)
+}
+
+// IsOnWhatsApp asks s (or the sender it wraps) whether phone has WhatsApp.
+// Senders that cannot check report true, so the message is attempted anyway.
+func IsOnWhatsApp(s MessageSender, phone string) (bool, error) {
+	for s != nil {
+		if c, ok := s.(NumberChecker); ok {
+			return c.IsOnWhatsApp(phone)
+		}
+		w, ok := s.(interface{ Unwrap() MessageSender })
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return true, nil
+}
+
+// GroupInfo is a WhatsApp group the account belongs to.
+type GroupInfo struct {
+	Address string // "<id>@g.us", usable wherever a phone is
+	Name    string
+	Members []string // participant phones, when known
+}
+
+// GroupLister is implemented by senders that can list the groups the
+// account was added to.
+type GroupLister interface {
+	JoinedGroups() ([]GroupInfo, error)
+}
+
+// JoinedGroups asks s (or the sender it wraps) for its groups.
+func JoinedGroups(s MessageSender) ([]GroupInfo, error) {
+	for s != nil {
+		if g, ok := s.(GroupLister); ok {
+			return g.JoinedGroups()
+		}
+		w, ok := s.(interface{ Unwrap() MessageSender })
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return nil, fmt.Errorf("este canal não lista grupos")
+}
+
 // IncomingMessage represents a received WhatsApp message.
 type IncomingMessage struct {
-	From      string
-	Body      string
+	From      string // sender; in a group, the member who posted
+	Group     string // group address ("<id>@g.us") when posted in a group
+	Body      string // text, or the caption when the message carries media
+	Media     *Media // nil for plain text
 	Timestamp time.Time
+	// ThreadRefs are the IDs of messages this one replies to (e-mail
+	// In-Reply-To/References), as returned by Send.
+	ThreadRefs []string
+}
+
+// Media kinds.
+const (
+	MediaImage    = "image"
+	MediaDocument = "document"
+	MediaAudio    = "audio"
+	MediaVideo    = "video"
+)
+
+// Media is a file received with a message, already downloaded to disk.
+type Media struct {
+	Kind     string // image/document/audio/video
+	Path     string // local file under the media dir
+	MimeType string
+	FileName string // original name, when the sender provided one
+}
+
+// Describe returns a short text placeholder for the media, used where only
+// text can be stored (e.g. a quote response).
+func (m *Media) Describe() string {
+	label := map[string]string{
+		MediaImage:    "imagem",
+		MediaDocument: "documento",
+		MediaAudio:    "áudio",
+		MediaVideo:    "vídeo",
+	}[m.Kind]
+	if label == "" {
+		label = "arquivo"
+	}
+	return fmt.Sprintf("[%s: %s]", label, m.Path)
 }
 
 // --- Mock implementation ---
@@ -25,14 +151,17 @@ type IncomingMessage struct {
 // MockSender prints messages to stdout and records them in memory.
 type MockSender struct {
 	Sent     []SentMessage
-	handlers []func(from, msg string)
+	handlers []func(msg IncomingMessage)
+	receipts []func(r Receipt)
 }
 
 // SentMessage records a message sent via MockSender.
 type SentMessage struct {
-	Phone   string
-	Message string
-	SentAt  time.Time
+	ID        string
+	Phone     string
+	Message   string // text, or caption for media
+	MediaPath string // set for SendImage/SendDocument
+	SentAt    time.Time
 }
 
 // NewMockSender creates a dry-run WhatsApp sender.
@@ -40,22 +169,79 @@ func NewMockSender() *MockSender {
 	return &MockSender{}
 }
 
-func (m *MockSender) Send(phone, message string) error {
-	msg := SentMessage{Phone: phone, Message: message, SentAt: time.Now()}
+func (m *MockSender) Send(phone, message string) (string, error) {
+	msg := SentMessage{ID: m.nextID(), Phone: phone, Message: message, SentAt: time.Now()}
 	m.Sent = append(m.Sent, msg)
 	fmt.Printf("\n[DRY-RUN WhatsApp → %s]\n%s\n[/WhatsApp]\n\n", phone, message)
-	return nil
+	return msg.ID, nil
+}
+
+func (m *MockSender) SendImage(phone, path, caption string) (string, error) {
+	return m.sendMedia("imagem", phone, path, caption)
 }
 
-func (m *MockSender) Listen(handler func(from, msg string)) error {
+func (m *MockSender) SendDocument(phone, path, caption string) (string, error) {
+	return m.sendMedia("documento", phone, path, caption)
+}
+
+func (m *MockSender) sendMedia(label, phone, path, caption string) (string, error) {
+	msg := SentMessage{ID: m.nextID(), Phone: phone, Message: caption, MediaPath: path, SentAt: time.Now()}
+	m.Sent = append(m.Sent, msg)
+	fmt.Printf("\n[DRY-RUN WhatsApp → %s] %s: %s\n%s\n[/WhatsApp]\n\n", phone, label, path, caption)
+	return msg.ID, nil
+}
+
+func (m *MockSender) nextID() string {
+	return fmt.Sprintf("MOCK%06d", len(m.Sent)+1)
+}
+
+func (m *MockSender) Listen(handler func(msg IncomingMessage)) error {
 	m.handlers = append(m.handlers, handler)
 	return nil
 }
 
+func (m *MockSender) ListenReceipts(handler func(r Receipt)) error {
+	m.receipts = append(m.receipts, handler)
+	return nil
+}
+
+// Health always reports connected: the mock has no link to lose.
+func (m *MockSender) Health() Health {
+	return Health{State: HealthConnected}
+}
+
+// SimulateReceipt injects a fake delivery or read receipt for a sent message.
+func (m *MockSender) SimulateReceipt(messageID, status string) {
+	r := Receipt{MessageIDs: []string{messageID}, Status: status, Timestamp: time.Now()}
+	for _, s := range m.Sent {
+		if s.ID == messageID {
+			r.From = s.Phone
+		}
+	}
+	for _, h := range m.receipts {
+		h(r)
+	}
+}
+
 // SimulateReply injects a fake incoming message (used in tests/demos).
 func (m *MockSender) SimulateReply(from, msg string) {
+	m.deliver(IncomingMessage{From: from, Body: msg, Timestamp: time.Now()})
+}
+
+// SimulateGroupMessage injects a fake message posted by member in group.
+func (m *MockSender) SimulateGroupMessage(group, member, msg string) {
+	m.deliver(IncomingMessage{From: member, Group: group, Body: msg, Timestamp: time.Now()})
+}
+
+// SimulateMedia injects a fake incoming media message; path must point to an
+// existing local file.
+func (m *MockSender) SimulateMedia(from string, media Media, caption string) {
+	m.deliver(IncomingMessage{From: from, Body: caption, Media: &media, Timestamp: time.Now()})
+}
+
+func (m *MockSender) deliver(msg IncomingMessage) {
 	for _, h := range m.handlers {
-		h(from, msg)
+		h(msg)
 	}
 }
 
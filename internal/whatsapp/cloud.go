@@ -0,0 +1,586 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCloudAPI is the Graph API endpoint of the WhatsApp Business Cloud API.
+const DefaultCloudAPI = "https://graph.facebook.com/v21.0"
+
+// sessionWindow is how long after a contact's last message the Cloud API
+// accepts free-form messages to them; outside it only templates go through.
+const sessionWindow = 24 * time.Hour
+
+// ErrOutsideWindow is returned when sending media to a contact outside the
+// session window, where the Cloud API only takes templates. The text of a
+// message has usually gone out as a template; callers should skip the media.
+var ErrOutsideWindow = errors.New("fora da janela de 24h da Cloud API")
+
+// CloudConfig configures a CloudSender.
+type CloudConfig struct {
+	Token         string // permanent or system-user access token
+	PhoneNumberID string // ID of the business phone number in Meta
+	AppSecret     string // signs webhook payloads (X-Hub-Signature-256)
+	VerifyToken   string // echoed back by Meta when subscribing the webhook
+	APIURL        string // DefaultCloudAPI unless pointing at a stub (see whatsapptest.CloudStub)
+
+	WebhookAddr string // listen address of the webhook server, e.g. ":8443"
+	WebhookPath string // defaults to /webhook
+
+	// Template is sent to contacts outside the 24h session window, with the
+	// message text as its only body parameter. Empty sends free-form text
+	// anyway, which Meta rejects for first contacts.
+	Template     string
+	TemplateLang string // defaults to pt_BR
+
+	MediaDir string
+}
+
+// CloudSender implements MessageSender over the official WhatsApp Business
+// Cloud API: messages go out through the Graph API and incoming messages
+// and status callbacks arrive on an embedded webhook server.
+type CloudSender struct {
+	cfg    CloudConfig
+	http   *http.Client
+	server *http.Server
+
+	mu          sync.Mutex
+	handlers    []func(msg IncomingMessage)
+	receipts    []func(r Receipt)
+	lastInbound map[string]time.Time // phone → last message from them
+	health      Health
+}
+
+// NewCloudSender checks the token against the phone number and starts the
+// webhook server.
+func NewCloudSender(ctx context.Context, cfg CloudConfig) (*CloudSender, error) {
+	if cfg.Token == "" || cfg.PhoneNumberID == "" {
+		return nil, errors.New("cloud api: token e phone number ID são obrigatórios")
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultCloudAPI
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	if cfg.WebhookPath == "" {
+		cfg.WebhookPath = "/webhook"
+	}
+	if cfg.TemplateLang == "" {
+		cfg.TemplateLang = "pt_BR"
+	}
+	if cfg.MediaDir == "" {
+		cfg.MediaDir = DefaultMediaDir
+	}
+	c := &CloudSender{
+		cfg:         cfg,
+		http:        &http.Client{Timeout: 60 * time.Second},
+		lastInbound: map[string]time.Time{},
+		health:      Health{State: HealthConnecting, Since: time.Now()},
+	}
+
+	var phone struct {
+		DisplayPhoneNumber string `json:"display_phone_number"`
+		VerifiedName       string `json:"verified_name"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/"+cfg.PhoneNumberID, nil, &phone); err != nil {
+		return nil, fmt.Errorf("cloud api: %w", err)
+	}
+	fmt.Printf("WhatsApp Cloud API: %s (%s)\n", phone.DisplayPhoneNumber, phone.VerifiedName)
+	c.setHealth(HealthConnected, "")
+
+	if cfg.WebhookAddr != "" {
+		ln, err := net.Listen("tcp", cfg.WebhookAddr)
+		if err != nil {
+			return nil, fmt.Errorf("webhook: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(cfg.WebhookPath, c)
+		c.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := c.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Printf("[Cloud API] webhook parou: %v\n", err)
+				c.setHealth(HealthDisconnected, "webhook: "+err.Error())
+			}
+		}()
+		fmt.Printf("Webhook do WhatsApp em %s%s\n", ln.Addr(), cfg.WebhookPath)
+	}
+	return c, nil
+}
+
+func (c *CloudSender) Send(phone, message string) (string, error) {
+	to := normalizePhone(phone)
+	if !c.inSession(to) && c.cfg.Template != "" {
+		return c.send(to, map[string]any{
+			"type": "template",
+			"template": map[string]any{
+				"name":     c.cfg.Template,
+				"language": map[string]string{"code": c.cfg.TemplateLang},
+				"components": []any{map[string]any{
+					"type":       "body",
+					"parameters": []any{map[string]string{"type": "text", "text": templateText(message)}},
+				}},
+			},
+		})
+	}
+	return c.send(to, map[string]any{
+		"type": "text",
+		"text": map[string]any{"body": message},
+	})
+}
+
+func (c *CloudSender) SendImage(phone, path, caption string) (string, error) {
+	return c.sendMedia(MediaImage, phone, path, caption)
+}
+
+func (c *CloudSender) SendDocument(phone, path, caption string) (string, error) {
+	return c.sendMedia(MediaDocument, phone, path, caption)
+}
+
+func (c *CloudSender) sendMedia(kind, phone, path, caption string) (string, error) {
+	to := normalizePhone(phone)
+	if !c.inSession(to) && c.cfg.Template != "" {
+		// Media is never allowed outside the session window; fail before
+		// uploading instead of after.
+		return "", fmt.Errorf("send %s to %s: %w", kind, to, ErrOutsideWindow)
+	}
+	id, err := c.upload(path)
+	if err != nil {
+		return "", fmt.Errorf("upload %s: %w", path, err)
+	}
+	obj := map[string]any{"id": id}
+	if caption != "" {
+		obj["caption"] = caption
+	}
+	if kind == MediaDocument {
+		obj["filename"] = filepath.Base(path)
+	}
+	return c.send(to, map[string]any{"type": kind, kind: obj})
+}
+
+func (c *CloudSender) Listen(handler func(msg IncomingMessage)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+	return nil
+}
+
+func (c *CloudSender) ListenReceipts(handler func(r Receipt)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receipts = append(c.receipts, handler)
+	return nil
+}
+
+func (c *CloudSender) Health() Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.health
+}
+
+func (c *CloudSender) Close() error {
+	if c.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.server.Shutdown(ctx)
+}
+
+func (c *CloudSender) setHealth(state, detail string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if (state == HealthConnected) != c.health.OK() || state == HealthLoggedOut {
+		c.health.Since = time.Now()
+	}
+	if state == HealthConnected {
+		c.health.Attempts = 0
+	} else {
+		c.health.Attempts++
+	}
+	c.health.State = state
+	c.health.Detail = detail
+}
+
+// inSession reports whether phone wrote to us within the session window.
+func (c *CloudSender) inSession(phone string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.lastInbound[phone]
+	return ok && time.Since(last) < sessionWindow
+}
+
+func (c *CloudSender) send(to string, msg map[string]any) (string, error) {
+	msg["messaging_product"] = "whatsapp"
+	msg["recipient_type"] = "individual"
+	msg["to"] = to
+	var resp struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := c.call(context.Background(), http.MethodPost, "/"+c.cfg.PhoneNumberID+"/messages", msg, &resp); err != nil {
+		return "", fmt.Errorf("send to %s: %w", to, err)
+	}
+	if len(resp.Messages) == 0 {
+		return "", fmt.Errorf("send to %s: resposta sem ID de mensagem", to)
+	}
+	fmt.Printf("[Cloud API enviado → %s]\n", to)
+	return resp.Messages[0].ID, nil
+}
+
+// upload stores a local file in Meta and returns its media ID.
+func (c *CloudSender) upload(path string) (string, error) {
+	data, mimeType, err := readMedia(path)
+	if err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("messaging_product", "whatsapp")
+	_ = w.WriteField("type", mimeType)
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.cfg.APIURL+"/"+c.cfg.PhoneNumberID+"/media", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// call sends a Graph API request with an optional JSON body and decodes the
+// response into result.
+func (c *CloudSender) call(ctx context.Context, method, path string, params any, result any) error {
+	var body io.Reader
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.APIURL+path, body)
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, result)
+}
+
+// do authenticates and runs a request, turning Graph API errors into Go
+// errors and keeping Health in line with what the API says.
+func (c *CloudSender) do(req *http.Request, result any) error {
+	req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.setHealth(HealthDisconnected, err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var env struct {
+			Error struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(raw, &env)
+		msg := env.Error.Message
+		if msg == "" {
+			msg = strings.TrimSpace(string(raw))
+		}
+		switch {
+		case env.Error.Code == 190 || resp.StatusCode == http.StatusUnauthorized:
+			// Expired or revoked token: nothing goes out until it is replaced.
+			c.setHealth(HealthLoggedOut, "token inválido: "+msg)
+		case resp.StatusCode >= 500:
+			c.setHealth(HealthDisconnected, msg)
+		}
+		return fmt.Errorf("HTTP %d (código %d): %s", resp.StatusCode, env.Error.Code, msg)
+	}
+	c.setHealth(HealthConnected, "")
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// ServeHTTP is the webhook endpoint: GET answers Meta's subscription
+// challenge, POST receives signed message and status notifications.
+func (c *CloudSender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		if q.Get("hub.mode") != "subscribe" || q.Get("hub.verify_token") != c.cfg.VerifyToken || c.cfg.VerifyToken == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, q.Get("hub.challenge"))
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !validSignature(c.cfg.AppSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		var payload cloudWebhook
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		// Acknowledge right away; Meta retries slow webhooks.
+		w.WriteHeader(http.StatusOK)
+		go c.dispatch(payload)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validSignature checks the HMAC-SHA256 of body against the
+// "sha256=<hex>" header Meta sends. Without an app secret nothing is valid.
+func validSignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, signPayload(secret, body))
+}
+
+func signPayload(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (c *CloudSender) dispatch(payload cloudWebhook) {
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			for _, m := range change.Value.Messages {
+				c.deliver(m)
+			}
+			for _, s := range change.Value.Statuses {
+				c.receipt(s)
+			}
+		}
+	}
+}
+
+func (c *CloudSender) deliver(m cloudMessage) {
+	from := normalizePhone(m.From)
+	ts := unixString(m.Timestamp)
+	c.mu.Lock()
+	c.lastInbound[from] = time.Now()
+	c.mu.Unlock()
+
+	in := IncomingMessage{From: from, Timestamp: ts}
+	var (
+		file  *cloudMedia
+		media Media
+	)
+	switch m.Type {
+	case "text":
+		in.Body = m.Text.Body
+	case "image":
+		file, media = m.Image, Media{Kind: MediaImage}
+	case "document":
+		file, media = m.Document, Media{Kind: MediaDocument}
+	case "audio":
+		file, media = m.Audio, Media{Kind: MediaAudio}
+	case "video":
+		file, media = m.Video, Media{Kind: MediaVideo}
+	}
+	if file != nil {
+		in.Body = file.Caption
+		media.MimeType = file.MimeType
+		media.FileName = file.Filename
+		path, err := c.download(file.ID, media.MimeType, media.FileName)
+		if err != nil {
+			fmt.Printf("[Cloud API] falha ao baixar mídia de %s: %v\n", from, err)
+			in.Body = lostMediaBody(file.Caption)
+		} else {
+			media.Path = path
+			in.Media = &media
+		}
+	}
+	if in.Body == "" && in.Media == nil {
+		return
+	}
+
+	c.mu.Lock()
+	handlers := append([]func(IncomingMessage){}, c.handlers...)
+	c.mu.Unlock()
+	for _, h := range handlers {
+		h(in)
+	}
+}
+
+func (c *CloudSender) receipt(s cloudStatus) {
+	var status string
+	switch s.Status {
+	case "delivered":
+		status = StatusDelivered
+	case "read":
+		status = StatusRead
+	case "failed":
+		for _, e := range s.Errors {
+			fmt.Printf("[Cloud API] mensagem %s para %s falhou: %s (código %d)\n", s.ID, s.RecipientID, e.Title, e.Code)
+		}
+		return
+	default:
+		return
+	}
+	rc := Receipt{
+		MessageIDs: []string{s.ID},
+		From:       normalizePhone(s.RecipientID),
+		Status:     status,
+		Timestamp:  unixString(s.Timestamp),
+	}
+	c.mu.Lock()
+	handlers := append([]func(Receipt){}, c.receipts...)
+	c.mu.Unlock()
+	for _, h := range handlers {
+		h(rc)
+	}
+}
+
+// download fetches a received media file: the media ID resolves to a
+// short-lived URL that also needs the bearer token.
+func (c *CloudSender) download(mediaID, mimeType, fileName string) (string, error) {
+	var info struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	}
+	if err := c.call(context.Background(), http.MethodGet, "/"+mediaID, nil, &info); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, info.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if mimeType == "" {
+		mimeType = info.MimeType
+	}
+	return SaveMedia(c.cfg.MediaDir, data, mimeType, fileName)
+}
+
+// templateText fits a message into a template parameter, which may not
+// contain newlines, tabs or more than four spaces in a row.
+func templateText(s string) string {
+	s = strings.NewReplacer("\r\n", " | ", "\n", " | ", "\t", " ").Replace(s)
+	for strings.Contains(s, "     ") {
+		s = strings.ReplaceAll(s, "     ", " ")
+	}
+	return strings.TrimSpace(s)
+}
+
+func unixString(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(sec, 0)
+}
+
+// --- Webhook payload (only the fields we use) ---
+
+type cloudWebhook struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Messages []cloudMessage `json:"messages,omitempty"`
+				Statuses []cloudStatus  `json:"statuses,omitempty"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type cloudMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Image    *cloudMedia `json:"image,omitempty"`
+	Document *cloudMedia `json:"document,omitempty"`
+	Audio    *cloudMedia `json:"audio,omitempty"`
+	Video    *cloudMedia `json:"video,omitempty"`
+}
+
+type cloudMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type cloudStatus struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientID string `json:"recipient_id"`
+	Errors      []struct {
+		Code  int    `json:"code"`
+		Title string `json:"title"`
+	} `json:"errors,omitempty"`
+}
@@ -0,0 +1,163 @@
+package whatsapp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/agente/internal/whatsapp"
+	"github.com/user/agente/internal/whatsapp/whatsapptest"
+)
+
+const appSecret = "segredo"
+
+// newCloud starts a CloudSender against a stub Graph API, with its webhook
+// behind a test server the stub notifies.
+func newCloud(t *testing.T, template string) (*whatsapp.CloudSender, *whatsapptest.CloudStub, string, chan whatsapp.IncomingMessage) {
+	t.Helper()
+	var cloud *whatsapp.CloudSender
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cloud.ServeHTTP(w, r)
+	}))
+	t.Cleanup(hook.Close)
+	stub := whatsapptest.NewCloudStub(hook.URL, appSecret)
+	t.Cleanup(stub.Close)
+
+	var err error
+	cloud, err = whatsapp.NewCloudSender(context.Background(), whatsapp.CloudConfig{
+		Token: "token", PhoneNumberID: "100", AppSecret: appSecret, VerifyToken: "verifica",
+		APIURL: stub.URL(), Template: template, MediaDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan whatsapp.IncomingMessage, 4)
+	_ = cloud.Listen(func(m whatsapp.IncomingMessage) { in <- m })
+	return cloud, stub, hook.URL, in
+}
+
+func TestCloudWebhookAcceptsSignedNotifications(t *testing.T) {
+	_, stub, _, in := newCloud(t, "")
+
+	if err := stub.PushText("5567999990000", "arroz R$ 25"); err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, in)
+	if m.From != "5567999990000" || m.Body != "arroz R$ 25" {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestCloudMediaThatFailsToDownloadKeepsCaption(t *testing.T) {
+	_, stub, _, in := newCloud(t, "")
+
+	if err := stub.PushExpiredMedia("5567999990000", "image", "image/jpeg", "tabela de preços"); err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, in)
+	if m.Media != nil || !strings.HasPrefix(m.Body, "tabela de preços\n") || !strings.Contains(m.Body, "não pôde ser baixada") {
+		t.Errorf("with caption: got %+v, want the caption and a marker", m)
+	}
+
+	// Without a caption it is still delivered, rather than dropped as empty
+	if err := stub.PushExpiredMedia("5567999990000", "document", "application/pdf", ""); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, in); !strings.Contains(m.Body, "não pôde ser baixada") {
+		t.Errorf("without caption: got %+v", m)
+	}
+}
+
+func TestCloudWebhookRejectsBadSignatures(t *testing.T) {
+	_, _, hook, in := newCloud(t, "")
+	body := `{"object":"whatsapp_business_account","entry":[{"changes":[{"field":"messages","value":{"messages":[` +
+		`{"from":"5567999990000","id":"wamid.x","timestamp":"1","type":"text","text":{"body":"falso"}}]}}]}]}`
+
+	for name, sig := range map[string]string{
+		"missing":      "",
+		"wrong secret": whatsapptest.Signature("outro", []byte(body)),
+		"not hex":      "sha256=zz",
+	} {
+		req, _ := http.NewRequest(http.MethodPost, hook, strings.NewReader(body))
+		if sig != "" {
+			req.Header.Set("X-Hub-Signature-256", sig)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s signature: HTTP %d, want 401", name, resp.StatusCode)
+		}
+	}
+
+	// The same body, correctly signed, goes through
+	req, _ := http.NewRequest(http.MethodPost, hook, strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", whatsapptest.Signature(appSecret, []byte(body)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("signed: HTTP %d", resp.StatusCode)
+	}
+	if m := receive(t, in); m.Body != "falso" {
+		t.Errorf("signed: got %+v", m)
+	}
+	select {
+	case m := <-in:
+		t.Errorf("unsigned notification delivered: %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCloudWebhookVerification(t *testing.T) {
+	_, _, hook, _ := newCloud(t, "")
+	for token, want := range map[string]int{"verifica": http.StatusOK, "errado": http.StatusForbidden} {
+		resp, err := http.Get(hook + "?hub.mode=subscribe&hub.verify_token=" + token + "&hub.challenge=123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("verify token %q: HTTP %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestCloudMediaOutsideWindow(t *testing.T) {
+	cloud, stub, _, in := newCloud(t, "cotacao")
+	img := filepath.Join(t.TempDir(), "foto.jpg")
+	if err := os.WriteFile(img, []byte("\xff\xd8\xff\xe0 jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A first contact gets the text as a template, but no media
+	if _, err := cloud.Send("5567999990000", "Qual o preço do arroz?"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cloud.SendImage("5567999990000", img, ""); !errors.Is(err, whatsapp.ErrOutsideWindow) {
+		t.Fatalf("media outside the window: got %v, want ErrOutsideWindow", err)
+	}
+
+	// Once they write, the session is open
+	if err := stub.PushText("5567999990000", "oi"); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, in)
+	if _, err := cloud.SendImage("5567999990000", img, "foto"); err != nil {
+		t.Fatalf("media in the window: %v", err)
+	}
+	sent := stub.Sent()
+	if len(sent) != 2 || sent[0].Type != "template" || sent[1].Type != "image" {
+		t.Errorf("sent %+v", sent)
+	}
+}
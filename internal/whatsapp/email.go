@@ -0,0 +1,539 @@
+package whatsapp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	imapclient "github.com/emersion/go-imap/client"
+	"github.com/google/uuid"
+)
+
+// ChannelEmail addresses a contact by e-mail: "email:vendas@loja.com.br".
+const ChannelEmail = "email"
+
+// DefaultEmailSubject is the subject of outgoing e-mails; MessageSender
+// carries only a body, and every e-mail we send is a quote request.
+const DefaultEmailSubject = "Pedido de cotação"
+
+// Mailer sends one raw RFC 5322 message.
+type Mailer interface {
+	SendMail(from string, to []string, msg []byte) error
+}
+
+// Mailbox returns the raw messages that arrived since the last call.
+type Mailbox interface {
+	FetchNew() ([][]byte, error)
+}
+
+// EmailSender implements MessageSender over SMTP (sending) and a polled
+// mailbox (receiving). Message IDs are the RFC 5322 Message-ID headers, so a
+// reply's In-Reply-To/References (IncomingMessage.ThreadRefs) point back to
+// the message that asked for the quote.
+type EmailSender struct {
+	from     string
+	mailer   Mailer
+	mailbox  Mailbox
+	mediaDir string
+	Subject  string
+
+	mu       sync.Mutex
+	handlers []func(msg IncomingMessage)
+	health   Health
+	lastSent map[string]sentRef // per recipient, to thread follow-up attachments
+	stop     chan struct{}
+}
+
+type sentRef struct {
+	id string
+	at time.Time
+}
+
+// NewEmailSender creates the sender and starts polling mailbox every interval.
+func NewEmailSender(from string, mailer Mailer, mailbox Mailbox, mediaDir string, interval time.Duration) *EmailSender {
+	if mediaDir == "" {
+		mediaDir = DefaultMediaDir
+	}
+	e := &EmailSender{
+		from:     from,
+		mailer:   mailer,
+		mailbox:  mailbox,
+		mediaDir: mediaDir,
+		Subject:  DefaultEmailSubject,
+		health:   Health{State: HealthConnected, Since: time.Now()},
+		lastSent: map[string]sentRef{},
+		stop:     make(chan struct{}),
+	}
+	go e.poll(interval)
+	return e
+}
+
+func (e *EmailSender) Send(addr, message string) (string, error) {
+	return e.send(addr, message, "")
+}
+
+func (e *EmailSender) SendImage(addr, path, caption string) (string, error) {
+	return e.send(addr, caption, path)
+}
+
+func (e *EmailSender) SendDocument(addr, path, caption string) (string, error) {
+	return e.send(addr, caption, path)
+}
+
+// send mails body (and the file at attachment, if any) to addr. Files sent
+// right after a message go in the same thread as it.
+func (e *EmailSender) send(addr, body, attachment string) (string, error) {
+	_, to := SplitAddress(addr)
+	id := fmt.Sprintf("<%s@%s>", uuid.New().String(), domainOf(e.from))
+
+	e.mu.Lock()
+	prev, ok := e.lastSent[strings.ToLower(to)]
+	e.mu.Unlock()
+
+	h := textproto.MIMEHeader{}
+	h.Set("From", e.from)
+	h.Set("To", to)
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("Message-ID", id)
+	subject := e.Subject
+	if ok && time.Since(prev.at) < 30*time.Minute {
+		h.Set("In-Reply-To", prev.id)
+		h.Set("References", prev.id)
+		subject = "Re: " + subject
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", subject))
+
+	raw, err := buildMail(h, body, attachment)
+	if err != nil {
+		return "", err
+	}
+	if err := e.mailer.SendMail(e.from, []string{to}, raw); err != nil {
+		return "", fmt.Errorf("e-mail para %s: %w", to, err)
+	}
+	fmt.Printf("[E-mail enviado → %s]\n", to)
+
+	e.mu.Lock()
+	if attachment == "" {
+		e.lastSent[strings.ToLower(to)] = sentRef{id: id, at: time.Now()}
+	}
+	e.mu.Unlock()
+	return id, nil
+}
+
+func (e *EmailSender) Listen(handler func(msg IncomingMessage)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers = append(e.handlers, handler)
+	return nil
+}
+
+// ListenReceipts is a no-op: e-mail has no reliable delivery or read receipts.
+func (e *EmailSender) ListenReceipts(func(r Receipt)) error { return nil }
+
+func (e *EmailSender) Health() Health {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.health
+}
+
+func (e *EmailSender) Close() error {
+	close(e.stop)
+	return nil
+}
+
+func (e *EmailSender) poll(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.fetch()
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *EmailSender) fetch() {
+	raws, err := e.mailbox.FetchNew()
+
+	e.mu.Lock()
+	if err != nil {
+		if e.health.OK() {
+			e.health.Since = time.Now()
+		}
+		e.health.State = HealthDisconnected
+		e.health.Detail = err.Error()
+		e.health.Attempts++
+	} else if !e.health.OK() {
+		e.health = Health{State: HealthConnected, Since: time.Now()}
+	}
+	handlers := append([]func(IncomingMessage){}, e.handlers...)
+	e.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("[E-mail] erro ao buscar mensagens: %v\n", err)
+		return
+	}
+	for _, raw := range raws {
+		msgs, err := parseMail(raw, e.mediaDir)
+		if err != nil {
+			fmt.Printf("[E-mail] mensagem ilegível: %v\n", err)
+			continue
+		}
+		for _, m := range msgs {
+			for _, h := range handlers {
+				h(m)
+			}
+		}
+	}
+}
+
+// --- Composing ---
+
+func buildMail(h textproto.MIMEHeader, body, attachment string) ([]byte, error) {
+	var buf bytes.Buffer
+	h.Set("MIME-Version", "1.0")
+
+	if attachment == "" {
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, h)
+		qp := quotedprintable.NewWriter(&buf)
+		_, _ = qp.Write([]byte(body))
+		_ = qp.Close()
+		return buf.Bytes(), nil
+	}
+
+	data, mimeType, err := readMedia(attachment)
+	if err != nil {
+		return nil, err
+	}
+	mw := multipart.NewWriter(&buf)
+	h.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	var head bytes.Buffer
+	writeHeader(&head, h)
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(text)
+	_, _ = qp.Write([]byte(body))
+	_ = qp.Close()
+
+	name := filepath.Base(attachment)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mimeType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		_, _ = io.WriteString(part, enc[:76]+"\r\n")
+		enc = enc[76:]
+	}
+	_, _ = io.WriteString(part, enc+"\r\n")
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+func writeHeader(w io.Writer, h textproto.MIMEHeader) {
+	for _, k := range []string{"From", "To", "Date", "Subject", "Message-ID", "In-Reply-To", "References", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if v := h.Get(k); v != "" {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	_, _ = io.WriteString(w, "\r\n")
+}
+
+func domainOf(addr string) string {
+	if a, err := mail.ParseAddress(addr); err == nil {
+		addr = a.Address
+	}
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return "localhost"
+}
+
+// --- Parsing ---
+
+var msgIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// parseMail turns a received e-mail into incoming messages: the text with the
+// first attachment, then one message per further attachment, all from
+// "email:<sender>" and carrying the thread references.
+func parseMail(raw []byte, mediaDir string) ([]IncomingMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("remetente: %w", err)
+	}
+	date, err := msg.Header.Date()
+	if err != nil {
+		date = time.Now()
+	}
+	var refs []string
+	seen := map[string]bool{}
+	for _, ref := range msgIDPattern.FindAllString(msg.Header.Get("In-Reply-To")+" "+msg.Header.Get("References"), -1) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	var text string
+	var files []Media
+	if err := walkPart(textproto.MIMEHeader(msg.Header), msg.Body, mediaDir, &text, &files); err != nil {
+		return nil, err
+	}
+
+	base := IncomingMessage{
+		From:       ChannelEmail + ":" + strings.ToLower(from.Address),
+		Timestamp:  date,
+		ThreadRefs: refs,
+	}
+	first := base
+	first.Body = stripQuoted(text)
+	var out []IncomingMessage
+	for i, f := range files {
+		m := base
+		if i == 0 {
+			m = first
+		}
+		m.Media = &f
+		out = append(out, m)
+	}
+	if len(files) == 0 && first.Body != "" {
+		out = append(out, first)
+	}
+	return out, nil
+}
+
+// walkPart collects the plain text and saves the attachments of a MIME part.
+func walkPart(h textproto.MIMEHeader, body io.Reader, mediaDir string, text *string, files *[]Media) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(p.Header, p, mediaDir, text, files); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	fileName := dparams["filename"]
+	if fileName == "" {
+		fileName = params["name"]
+	}
+	if dec, err := new(mime.WordDecoder).DecodeHeader(fileName); err == nil {
+		fileName = dec
+	}
+
+	switch {
+	case disposition != "attachment" && mediaType == "text/plain" && *text == "":
+		*text = string(data)
+	case disposition != "attachment" && mediaType == "text/html" && *text == "":
+		*text = htmlToText(string(data))
+	case disposition == "attachment" || fileName != "" || !strings.HasPrefix(mediaType, "text/"):
+		path, err := SaveMedia(mediaDir, data, mediaType, fileName)
+		if err != nil {
+			return err
+		}
+		*files = append(*files, Media{Kind: mediaKind(mediaType), Path: path, MimeType: mediaType, FileName: fileName})
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// newlineStripper drops line breaks, which base64.NewDecoder does not accept
+// in every position.
+type newlineStripper struct{ r io.Reader }
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	k, err := n.r.Read(p)
+	j := 0
+	for _, b := range p[:k] {
+		if b != '\r' && b != '\n' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+func mediaKind(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return MediaImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return MediaAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return MediaVideo
+	}
+	return MediaDocument
+}
+
+var (
+	htmlTag    = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlBreak  = regexp.MustCompile(`(?i)<(br|/p|/div|/tr)[^>]*>`)
+	replyIntro = regexp.MustCompile(`(?im)^(em .+ escreveu:|on .+ wrote:|-----original message-----|de: .+)$`)
+)
+
+func htmlToText(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// stripQuoted drops the quoted original from a reply, keeping what the
+// supplier wrote.
+func stripQuoted(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if loc := replyIntro.FindStringIndex(s); loc != nil {
+		s = s[:loc[0]]
+	}
+	var keep []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			keep = append(keep, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(keep, "\n"))
+}
+
+// --- SMTP / IMAP ---
+
+// SMTPMailer sends through an SMTP server with STARTTLS (e.g. host:587).
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+func (m SMTPMailer) SendMail(from string, to []string, msg []byte) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := strings.Cut(m.Addr, ":")
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	sender := from
+	if a, err := mail.ParseAddress(from); err == nil {
+		sender = a.Address
+	}
+	return smtp.SendMail(m.Addr, auth, sender, to, msg)
+}
+
+// IMAPMailbox reads unseen messages from an IMAP folder over TLS (host:993)
+// and marks them seen. It opens one connection per fetch.
+type IMAPMailbox struct {
+	Addr     string
+	Username string
+	Password string
+	Folder   string // default INBOX
+}
+
+func (m IMAPMailbox) FetchNew() ([][]byte, error) {
+	c, err := imapclient.DialTLS(m.Addr, &tls.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("imap: %w", err)
+	}
+	defer c.Logout()
+	if err := c.Login(m.Username, m.Password); err != nil {
+		return nil, fmt.Errorf("imap login: %w", err)
+	}
+	folder := m.Folder
+	if folder == "" {
+		folder = "INBOX"
+	}
+	if _, err := c.Select(folder, false); err != nil {
+		return nil, fmt.Errorf("imap select %s: %w", folder, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
+
+	section := &imap.BodySectionName{Peek: true}
+	ch := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() { done <- c.UidFetch(set, []imap.FetchItem{section.FetchItem()}, ch) }()
+
+	var out [][]byte
+	for msg := range ch {
+		if body := msg.GetBody(section); body != nil {
+			raw, err := io.ReadAll(body)
+			if err == nil {
+				out = append(out, raw)
+			}
+		}
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("imap fetch: %w", err)
+	}
+
+	if err := c.UidStore(set, imap.FormatFlagsOp(imap.AddFlags, true), []any{imap.SeenFlag}, nil); err != nil {
+		return out, fmt.Errorf("imap marcar lidas: %w", err)
+	}
+	return out, nil
+}
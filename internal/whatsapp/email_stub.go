@@ -0,0 +1,77 @@
+package whatsapp
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryMailServer stands in for SMTP and IMAP: it is both the Mailer and the
+// Mailbox of an EmailSender, keeps what was sent and lets a demo or test drop
+// replies into the inbox.
+type MemoryMailServer struct {
+	mu    sync.Mutex
+	sent  []SentMail
+	inbox [][]byte
+}
+
+// SentMail is a message handed to the stand-in SMTP server.
+type SentMail struct {
+	From      string
+	To        []string
+	MessageID string
+	Raw       []byte
+}
+
+// NewMemoryMailServer creates an empty stand-in server.
+func NewMemoryMailServer() *MemoryMailServer {
+	return &MemoryMailServer{}
+}
+
+func (s *MemoryMailServer) SendMail(from string, to []string, msg []byte) error {
+	id := ""
+	if m, err := mail.ReadMessage(bytes.NewReader(msg)); err == nil {
+		id = m.Header.Get("Message-ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, SentMail{From: from, To: to, MessageID: id, Raw: msg})
+	return nil
+}
+
+func (s *MemoryMailServer) FetchNew() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.inbox
+	s.inbox = nil
+	return out, nil
+}
+
+// Sent returns a copy of the messages sent so far.
+func (s *MemoryMailServer) Sent() []SentMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMail(nil), s.sent...)
+}
+
+// Deliver drops a raw message into the inbox.
+func (s *MemoryMailServer) Deliver(raw []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inbox = append(s.inbox, raw)
+}
+
+// Reply delivers a plain-text answer from `from` to a sent message, threaded
+// to it through In-Reply-To, the way a mail client would.
+func (s *MemoryMailServer) Reply(orig SentMail, from, text string) {
+	s.Deliver([]byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: Re: %s\r\nDate: %s\r\nMessage-ID: <%s@stub>\r\n"+
+			"In-Reply-To: %s\r\nReferences: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		from, orig.From, DefaultEmailSubject, time.Now().Format(time.RFC1123Z), uuid.New().String(),
+		orig.MessageID, orig.MessageID, text,
+	)))
+}
@@ -0,0 +1,35 @@
+package whatsapp_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/user/agente/internal/whatsapp"
+)
+
+func TestEmailReplyCarriesThreadRefs(t *testing.T) {
+	mail := whatsapp.NewMemoryMailServer()
+	email := whatsapp.NewEmailSender("compras@casa.com.br", mail, mail, t.TempDir(), 10*time.Millisecond)
+	t.Cleanup(func() { email.Close() })
+	in := make(chan whatsapp.IncomingMessage, 4)
+	_ = email.Listen(func(m whatsapp.IncomingMessage) { in <- m })
+
+	id, err := email.Send("email:vendas@atacadao.com.br", "Qual o preço do arroz 5kg?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := mail.Sent()
+	if len(sent) != 1 || sent[0].MessageID != id || !slices.Equal(sent[0].To, []string{"vendas@atacadao.com.br"}) {
+		t.Fatalf("sent %+v, want one mail with ID %s", sent, id)
+	}
+
+	mail.Reply(sent[0], "vendas@atacadao.com.br", "R$ 24,90")
+	m := receive(t, in)
+	if m.From != "email:vendas@atacadao.com.br" || m.Body != "R$ 24,90" {
+		t.Errorf("got %+v", m)
+	}
+	if !slices.Contains(m.ThreadRefs, id) {
+		t.Errorf("thread refs %v do not name the sent message %s", m.ThreadRefs, id)
+	}
+}
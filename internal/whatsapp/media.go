@@ -0,0 +1,64 @@
+package whatsapp
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMediaDir is where received files are stored.
+const DefaultMediaDir = "data/media"
+
+// SaveMedia writes a received file under dir/YYYY-MM-DD/ with a unique name
+// and returns its path. The extension comes from fileName, else from mimeType.
+func SaveMedia(dir string, data []byte, mimeType, fileName string) (string, error) {
+	day := filepath.Join(dir, time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		return "", fmt.Errorf("create media dir: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(strings.Split(mimeType, ";")[0]); len(exts) > 0 {
+			ext = exts[len(exts)-1]
+		}
+	}
+
+	path := filepath.Join(day, uuid.New().String()+ext)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("write media: %w", err)
+	}
+	return path, nil
+}
+
+// mediaLost marks a message whose media could not be downloaded. The message
+// is still delivered with its caption, so a reply such as a photo of a price
+// list is seen rather than dropped.
+const mediaLost = "[mídia não pôde ser baixada]"
+
+// lostMediaBody is the body of a message whose media failed to download.
+func lostMediaBody(caption string) string {
+	if caption == "" {
+		return mediaLost
+	}
+	return caption + "\n" + mediaLost
+}
+
+// readMedia loads a local file to be sent and detects its MIME type.
+func readMedia(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("read %s: %w", path, err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return data, mimeType, nil
+}
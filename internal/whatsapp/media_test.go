@@ -0,0 +1,128 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSaveMedia(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("%PDF-1.4 tabela")
+
+	tests := []struct {
+		mimeType, fileName, wantExt string
+	}{
+		{"application/pdf", "Tabela.PDF", ".pdf"},
+		{"image/png", "", ".png"},
+		{"image/webp; q=1", "", ".webp"},
+	}
+	for _, tt := range tests {
+		path, err := SaveMedia(dir, data, tt.mimeType, tt.fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(path) != filepath.Join(dir, time.Now().Format("2006-01-02")) {
+			t.Errorf("%s saved in %s, want the day's folder", tt.mimeType, filepath.Dir(path))
+		}
+		if ext := filepath.Ext(path); ext != tt.wantExt {
+			t.Errorf("%s %q: extension %q, want %q", tt.mimeType, tt.fileName, ext, tt.wantExt)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+			t.Errorf("%s: content not saved", path)
+		}
+	}
+}
+
+func TestReadMedia(t *testing.T) {
+	dir := t.TempDir()
+	named := filepath.Join(dir, "foto.jpg")
+	unnamed := filepath.Join(dir, "arquivo")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, p := range []string{named, unnamed} {
+		if err := os.WriteFile(p, png, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, mimeType, err := readMedia(named); err != nil || mimeType != "image/jpeg" {
+		t.Errorf("by extension: %q, %v", mimeType, err)
+	}
+	if _, mimeType, err := readMedia(unnamed); err != nil || mimeType != "image/png" {
+		t.Errorf("by content: %q, %v", mimeType, err)
+	}
+	if _, _, err := readMedia(filepath.Join(dir, "nada.jpg")); err == nil {
+		t.Error("missing file: want error")
+	}
+}
+
+func TestMockSenderMedia(t *testing.T) {
+	m := NewMockSender()
+	var got []IncomingMessage
+	_ = m.Listen(func(in IncomingMessage) { got = append(got, in) })
+
+	if _, err := m.SendImage("5567999990000", "foto.jpg", "lanterna"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SendDocument("5567999990000", "lista.pdf", ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Sent) != 2 || m.Sent[0].MediaPath != "foto.jpg" || m.Sent[0].Message != "lanterna" || m.Sent[1].MediaPath != "lista.pdf" {
+		t.Errorf("sent %+v", m.Sent)
+	}
+
+	m.SimulateMedia("5567999990000", Media{Kind: MediaDocument, Path: "data/media/x.pdf"}, "tabela")
+	if len(got) != 1 || got[0].Media == nil || got[0].Body != "tabela" {
+		t.Fatalf("received %+v", got)
+	}
+	if d := got[0].Media.Describe(); !strings.HasPrefix(d, "[documento: ") {
+		t.Errorf("Describe() = %q", d)
+	}
+}
+
+// A photo whose download fails still reaches the handlers with its caption,
+// marked as missing its media.
+func TestRealSenderKeepsCaptionWhenDownloadFails(t *testing.T) {
+	var got []IncomingMessage
+	r := &RealSender{
+		mediaDir: t.TempDir(),
+		handlers: []func(IncomingMessage){func(in IncomingMessage) { got = append(got, in) }},
+		download: func(context.Context, whatsmeow.DownloadableMessage) ([]byte, error) {
+			return nil, errors.New("media expired")
+		},
+	}
+	from := types.NewJID("5567999990000", types.DefaultUserServer)
+	photo := func(caption string) *events.Message {
+		return &events.Message{
+			Info: types.MessageInfo{MessageSource: types.MessageSource{Chat: from, Sender: from}, Timestamp: time.Now()},
+			Message: &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+				Caption:  proto.String(caption),
+				Mimetype: proto.String("image/jpeg"),
+			}},
+		}
+	}
+
+	r.handleMessage(photo("arroz 5kg R$ 24,90"))
+	r.handleMessage(photo(""))
+
+	if len(got) != 2 {
+		t.Fatalf("delivered %d messages, want 2", len(got))
+	}
+	if got[0].From != "5567999990000" || got[0].Media != nil || got[0].Body != "arroz 5kg R$ 24,90\n"+mediaLost {
+		t.Errorf("with caption: got %+v", got[0])
+	}
+	if got[1].Body != mediaLost {
+		t.Errorf("without caption: body %q, want the marker alone", got[1].Body)
+	}
+}
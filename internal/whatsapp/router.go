@@ -0,0 +1,172 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Channels a contact can be reached on. An address is "<channel>:<id>";
+// a bare phone number is a WhatsApp address.
+const (
+	ChannelWhatsApp = "whatsapp"
+	ChannelTelegram = "telegram"
+)
+
+// SplitAddress returns the channel and channel-specific ID of an address.
+func SplitAddress(addr string) (channel, id string) {
+	if i := strings.Index(addr, ":"); i > 0 {
+		if ch := addr[:i]; ch != "" && strings.Trim(ch, "abcdefghijklmnopqrstuvwxyz") == "" {
+			return ch, addr[i+1:]
+		}
+	}
+	return ChannelWhatsApp, addr
+}
+
+// groupSuffix ends the address of a WhatsApp group, which is its JID.
+const groupSuffix = "@g.us"
+
+// IsGroupAddress reports whether addr is a WhatsApp group.
+func IsGroupAddress(addr string) bool {
+	return strings.HasSuffix(strings.TrimSpace(addr), groupSuffix)
+}
+
+// NormalizeAddress canonicalizes an address for storage and comparison:
+// phone numbers keep only their digits, groups their JID, other channels
+// keep their prefix.
+func NormalizeAddress(addr string) string {
+	ch, id := SplitAddress(addr)
+	if ch == ChannelWhatsApp {
+		if IsGroupAddress(id) {
+			return strings.TrimSpace(id)
+		}
+		return normalizePhone(id)
+	}
+	return ch + ":" + strings.TrimSpace(id)
+}
+
+// Router is a MessageSender that dispatches each address to the sender of its
+// channel, so callers address contacts uniformly whatever app they use.
+type Router struct {
+	senders map[string]MessageSender
+	order   []string // channels in registration order; the first is the default
+}
+
+// NewRouter creates a router whose WhatsApp channel is whatsapp.
+func NewRouter(whatsapp MessageSender) *Router {
+	r := &Router{senders: map[string]MessageSender{}}
+	r.Add(ChannelWhatsApp, whatsapp)
+	return r
+}
+
+// Add registers the sender for a channel.
+func (r *Router) Add(channel string, s MessageSender) {
+	if _, ok := r.senders[channel]; !ok {
+		r.order = append(r.order, channel)
+	}
+	r.senders[channel] = s
+}
+
+// Unwrap returns the WhatsApp sender, so WhatsApp-only capabilities
+// (presence, number checks) are still found behind the router.
+func (r *Router) Unwrap() MessageSender { return r.senders[ChannelWhatsApp] }
+
+func (r *Router) route(addr string) (MessageSender, string, error) {
+	ch, id := SplitAddress(addr)
+	s, ok := r.senders[ch]
+	if !ok {
+		return nil, "", fmt.Errorf("canal %q não configurado para %s", ch, addr)
+	}
+	return s, id, nil
+}
+
+func (r *Router) Send(addr, message string) (string, error) {
+	s, id, err := r.route(addr)
+	if err != nil {
+		return "", err
+	}
+	return s.Send(id, message)
+}
+
+func (r *Router) SendImage(addr, path, caption string) (string, error) {
+	s, id, err := r.route(addr)
+	if err != nil {
+		return "", err
+	}
+	return s.SendImage(id, path, caption)
+}
+
+func (r *Router) SendDocument(addr, path, caption string) (string, error) {
+	s, id, err := r.route(addr)
+	if err != nil {
+		return "", err
+	}
+	return s.SendDocument(id, path, caption)
+}
+
+// SendTyping forwards presence to channels that support it.
+func (r *Router) SendTyping(addr string, typing bool) error {
+	s, id, err := r.route(addr)
+	if err != nil {
+		return err
+	}
+	if ps, ok := s.(PresenceSender); ok {
+		return ps.SendTyping(id, typing)
+	}
+	return nil
+}
+
+// IsOnWhatsApp checks WhatsApp addresses; contacts on other channels are
+// reachable by definition.
+func (r *Router) IsOnWhatsApp(addr string) (bool, error) {
+	s, id, err := r.route(addr)
+	if err != nil {
+		return false, err
+	}
+	if ch, _ := SplitAddress(addr); ch != ChannelWhatsApp {
+		return true, nil
+	}
+	return IsOnWhatsApp(s, id)
+}
+
+// Listen registers handler on every channel. Senders other than WhatsApp
+// already report From as a prefixed address.
+func (r *Router) Listen(handler func(msg IncomingMessage)) error {
+	for _, ch := range r.order {
+		if err := r.senders[ch].Listen(handler); err != nil {
+			return fmt.Errorf("%s: %w", ch, err)
+		}
+	}
+	return nil
+}
+
+func (r *Router) ListenReceipts(handler func(rc Receipt)) error {
+	for _, ch := range r.order {
+		if err := r.senders[ch].ListenReceipts(handler); err != nil {
+			return fmt.Errorf("%s: %w", ch, err)
+		}
+	}
+	return nil
+}
+
+// Health reports the first channel that is not connected, or connected.
+func (r *Router) Health() Health {
+	for _, ch := range r.order {
+		if h := r.senders[ch].Health(); !h.OK() {
+			if ch != ChannelWhatsApp {
+				h.Detail = strings.TrimSpace(ch + " " + h.Detail)
+			}
+			return h
+		}
+	}
+	return r.senders[r.order[0]].Health()
+}
+
+func (r *Router) Close() error {
+	var first error
+	for _, ch := range r.order {
+		if err := r.senders[ch].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
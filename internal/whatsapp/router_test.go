@@ -0,0 +1,44 @@
+package whatsapp
+
+import "testing"
+
+func TestRouterDispatchesByChannelPrefix(t *testing.T) {
+	wa, tg := NewMockSender(), NewMockSender()
+	r := NewRouter(wa)
+	r.Add(ChannelTelegram, tg)
+
+	if _, err := r.Send("5567999990000", "oi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Send("telegram:42", "olá"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SendImage("telegram:42", "foto.jpg", "legenda"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Send("email:loja@example.com", "oi"); err == nil {
+		t.Error("send to an unconfigured channel: want error")
+	}
+
+	if len(wa.Sent) != 1 || wa.Sent[0].Phone != "5567999990000" {
+		t.Errorf("whatsapp got %+v, want the bare phone only", wa.Sent)
+	}
+	if len(tg.Sent) != 2 || tg.Sent[0].Phone != "42" || tg.Sent[1].MediaPath != "foto.jpg" {
+		t.Errorf("telegram got %+v, want chat 42 without the prefix", tg.Sent)
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct{ addr, ch, id string }{
+		{"5567999990000", ChannelWhatsApp, "5567999990000"},
+		{"telegram:42", ChannelTelegram, "42"},
+		{"email:loja@example.com", ChannelEmail, "loja@example.com"},
+		{"+55 (67) 99999-0000", ChannelWhatsApp, "+55 (67) 99999-0000"},
+		{"120363@g.us", ChannelWhatsApp, "120363@g.us"},
+	}
+	for _, tt := range tests {
+		if ch, id := SplitAddress(tt.addr); ch != tt.ch || id != tt.id {
+			t.Errorf("SplitAddress(%q) = %q, %q; want %q, %q", tt.addr, ch, id, tt.ch, tt.id)
+		}
+	}
+}
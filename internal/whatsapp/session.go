@@ -6,8 +6,9 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -58,13 +59,31 @@ func init() {
 // RealSender implements MessageSender using whatsmeow (real WhatsApp Web).
 type RealSender struct {
 	client   *whatsmeow.Client
-	handlers []func(from, msg string)
+	handlers []func(msg IncomingMessage)
+	receipts []func(r Receipt)
 	lockFile string
+	mediaDir string // received files are stored here
+
+	// download fetches received media; client.Download unless replaced in tests
+	download func(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error)
+
+	mu           sync.Mutex
+	health       Health
+	reconnecting bool
+	closed       bool
 }
 
+// Reconnect backoff bounds.
+const (
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
 // NewRealSender connects to WhatsApp.
 // On first run it shows a QR code; subsequent runs reuse the saved session.
 // dbPath is the SQLite file for session persistence (e.g. "data/whatsapp.db").
+// Signals are left to the caller, which should Close the sender on shutdown
+// to disconnect and release the session lock.
 func NewRealSender(ctx context.Context, dbPath string) (*RealSender, error) {
 	lockFile := strings.TrimSuffix(dbPath, ".db") + ".lock"
 	if err := acquireLock(lockFile); err != nil {
@@ -81,39 +100,26 @@ func NewRealSender(ctx context.Context, dbPath string) (*RealSender, error) {
 		return nil, fmt.Errorf("get device: %w", err)
 	}
 
-	r := &RealSender{lockFile: lockFile}
+	r := &RealSender{
+		lockFile: lockFile,
+		mediaDir: filepath.Join(filepath.Dir(dbPath), "media"),
+		health:   Health{State: HealthConnecting, Since: time.Now()},
+	}
 	client := whatsmeow.NewClient(deviceStore, waLog.Noop)
+	// Reconnection is ours (see reconnect) so that it backs off and is
+	// visible through Health.
+	client.EnableAutoReconnect = false
 	r.client = client
+	r.download = client.Download
 
 	// Register handler for incoming messages BEFORE connecting
 	client.AddEventHandler(r.handleEvent)
 
 	if client.Store.ID == nil {
 		// First run: pair via QR code
-		qrChan, err := client.GetQRChannel(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("get qr channel: %w", err)
-		}
-		if err := client.Connect(); err != nil {
-			return nil, fmt.Errorf("connect: %w", err)
-		}
-
 		fmt.Println("\n=== WhatsApp — Primeira Conexão ===")
-		fmt.Println("Abra o WhatsApp > Dispositivos conectados > Conectar dispositivo")
-		fmt.Println("Escaneie o QR code abaixo:\n")
-
-		for item := range qrChan {
-			switch item.Event {
-			case "code":
-				qrterminal.GenerateHalfBlock(item.Code, qrterminal.L, os.Stdout)
-				fmt.Printf("(expira em %.0fs)\n", item.Timeout.Seconds())
-			default:
-				if item == whatsmeow.QRChannelSuccess {
-					fmt.Println("\nConectado com sucesso!")
-				} else if item == whatsmeow.QRChannelTimeout {
-					return nil, fmt.Errorf("timeout aguardando QR scan")
-				}
-			}
+		if err := r.pair(ctx); err != nil {
+			return nil, err
 		}
 	} else {
 		// Session already exists — reconnect and wait until fully authenticated
@@ -126,70 +132,403 @@ func NewRealSender(ctx context.Context, dbPath string) (*RealSender, error) {
 		fmt.Printf("WhatsApp conectado: %s\n", client.Store.ID.User)
 	}
 
-	// Release lock and disconnect cleanly on SIGINT/SIGTERM
-	go func() {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-		<-sigs
-		r.client.Disconnect()
-		releaseLock(lockFile)
-		os.Exit(0)
+	return r, nil
+}
+
+// pair shows QR codes in the terminal until the phone links this device.
+func (r *RealSender) pair(ctx context.Context) error {
+	qrChan, err := r.client.GetQRChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("get qr channel: %w", err)
+	}
+	if err := r.client.Connect(); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	fmt.Println("Abra o WhatsApp > Dispositivos conectados > Conectar dispositivo")
+	fmt.Println("Escaneie o QR code abaixo:")
+	fmt.Println()
+
+	for item := range qrChan {
+		switch item.Event {
+		case "code":
+			qrterminal.GenerateHalfBlock(item.Code, qrterminal.L, os.Stdout)
+			fmt.Printf("(expira em %.0fs)\n", item.Timeout.Seconds())
+		default:
+			if item == whatsmeow.QRChannelSuccess {
+				fmt.Println("\nConectado com sucesso!")
+			} else if item == whatsmeow.QRChannelTimeout {
+				return fmt.Errorf("timeout aguardando QR scan")
+			}
+		}
+	}
+	return nil
+}
+
+// Health reports the current state of the WhatsApp link.
+func (r *RealSender) Health() Health {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.health
+}
+
+func (r *RealSender) setHealth(state, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.health.State != state {
+		// Since marks the start of an outage, not of each retry within it
+		if (state == HealthConnected) != r.health.OK() || state == HealthLoggedOut {
+			r.health.Since = time.Now()
+		}
+		if state == HealthConnected {
+			r.health.Attempts = 0
+		}
+		fmt.Printf("[WhatsApp] %s %s\n", state, detail)
+	}
+	r.health.State = state
+	r.health.Detail = detail
+}
+
+// reconnect retries the connection with exponential backoff until it is
+// back or the sender is closed. Only one loop runs at a time.
+func (r *RealSender) reconnect(reason string) {
+	r.mu.Lock()
+	if r.reconnecting || r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.reconnecting = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.reconnecting = false
+		r.mu.Unlock()
 	}()
 
-	return r, nil
+	r.setHealth(HealthDisconnected, reason)
+	backoff := minBackoff
+	for {
+		time.Sleep(backoff)
+		r.mu.Lock()
+		if r.closed || r.health.State == HealthLoggedOut {
+			r.mu.Unlock()
+			return
+		}
+		r.health.Attempts++
+		attempt := r.health.Attempts
+		r.mu.Unlock()
+
+		r.setHealth(HealthConnecting, fmt.Sprintf("tentativa %d", attempt))
+		r.client.Disconnect()
+		err := r.client.Connect()
+		if err == nil && r.client.WaitForConnection(30*time.Second) {
+			r.setHealth(HealthConnected, "")
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("timeout aguardando autenticação")
+		}
+		r.setHealth(HealthDisconnected, err.Error())
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// repair handles a session revoked from the phone: the device store is gone,
+// so the only way back is a new QR pairing in the daemon's terminal.
+func (r *RealSender) repair(reason string) {
+	r.setHealth(HealthLoggedOut, reason)
+	fmt.Println("\n=== WhatsApp — sessão desconectada pelo celular ===")
+	fmt.Println("É preciso parear de novo. Escaneie o QR code abaixo (ou reinicie o daemon).")
+	r.client.Disconnect()
+	if err := r.pair(context.Background()); err != nil {
+		fmt.Printf("[WhatsApp] novo pareamento falhou: %v — reinicie 'comprador serve' para tentar de novo\n", err)
+		return
+	}
+	if r.client.WaitForConnection(30 * time.Second) {
+		r.setHealth(HealthConnected, "")
+	}
 }
 
 // Send sends a WhatsApp text message to the given phone number.
 // phone format: international digits only, e.g. "5567999990000"
-func (r *RealSender) Send(phone, message string) error {
-	phone = normalizePhone(phone)
-	jid := types.NewJID(phone, types.DefaultUserServer)
-
-	_, err := r.client.SendMessage(context.Background(), jid, &waE2E.Message{
+func (r *RealSender) Send(phone, message string) (string, error) {
+	return r.sendMessage(phone, &waE2E.Message{
 		Conversation: proto.String(message),
 	})
+}
+
+// SendImage sends a local image file with an optional caption.
+func (r *RealSender) SendImage(phone, path, caption string) (string, error) {
+	data, mimeType, err := readMedia(path)
+	if err != nil {
+		return "", err
+	}
+	up, err := r.client.Upload(context.Background(), data, whatsmeow.MediaImage)
+	if err != nil {
+		return "", fmt.Errorf("upload %s: %w", path, err)
+	}
+	return r.sendMessage(phone, &waE2E.Message{
+		ImageMessage: &waE2E.ImageMessage{
+			Caption:       proto.String(caption),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+		},
+	})
+}
+
+// SendDocument sends a local file (PDF, spreadsheet...) as a document.
+func (r *RealSender) SendDocument(phone, path, caption string) (string, error) {
+	data, mimeType, err := readMedia(path)
 	if err != nil {
-		return fmt.Errorf("send to %s: %w", phone, err)
+		return "", err
+	}
+	up, err := r.client.Upload(context.Background(), data, whatsmeow.MediaDocument)
+	if err != nil {
+		return "", fmt.Errorf("upload %s: %w", path, err)
+	}
+	name := filepath.Base(path)
+	return r.sendMessage(phone, &waE2E.Message{
+		DocumentMessage: &waE2E.DocumentMessage{
+			Caption:       proto.String(caption),
+			Title:         proto.String(name),
+			FileName:      proto.String(name),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+		},
+	})
+}
+
+func (r *RealSender) sendMessage(phone string, msg *waE2E.Message) (string, error) {
+	jid := chatJID(phone)
+	phone = jid.User
+	resp, err := r.client.SendMessage(context.Background(), jid, msg)
+	if err != nil {
+		return "", fmt.Errorf("send to %s: %w", phone, err)
 	}
 	fmt.Printf("[WhatsApp enviado → %s]\n", phone)
-	return nil
+	return resp.ID, nil
+}
+
+// chatJID returns the chat an address refers to: a group JID as is, or the
+// user JID of a phone number.
+func chatJID(addr string) types.JID {
+	if IsGroupAddress(addr) {
+		return types.NewJID(strings.TrimSuffix(strings.TrimSpace(addr), groupSuffix), types.GroupServer)
+	}
+	return types.NewJID(normalizePhone(addr), types.DefaultUserServer)
+}
+
+// IsOnWhatsApp reports whether phone has a WhatsApp account.
+func (r *RealSender) IsOnWhatsApp(phone string) (bool, error) {
+	if IsGroupAddress(phone) {
+		return true, nil
+	}
+	res, err := r.client.IsOnWhatsApp(context.Background(), []string{"+" + normalizePhone(phone)})
+	if err != nil {
+		return false, fmt.Errorf("verificar %s: %w", phone, err)
+	}
+	return len(res) > 0 && res[0].IsIn, nil
+}
+
+// SendTyping shows (or clears) the "digitando..." indicator in the chat.
+func (r *RealSender) SendTyping(phone string, typing bool) error {
+	jid := chatJID(phone)
+	state := types.ChatPresencePaused
+	if typing {
+		state = types.ChatPresenceComposing
+	}
+	return r.client.SendChatPresence(context.Background(), jid, state, types.ChatPresenceMediaText)
+}
+
+// JoinedGroups lists the groups this number was added to.
+func (r *RealSender) JoinedGroups() ([]GroupInfo, error) {
+	groups, err := r.client.GetJoinedGroups(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("listar grupos: %w", err)
+	}
+	out := make([]GroupInfo, len(groups))
+	for i, g := range groups {
+		out[i] = GroupInfo{Address: g.JID.String(), Name: g.Name}
+		for _, p := range g.Participants {
+			if !p.PhoneNumber.IsEmpty() {
+				out[i].Members = append(out[i].Members, p.PhoneNumber.User)
+			} else if p.JID.Server == types.DefaultUserServer {
+				out[i].Members = append(out[i].Members, p.JID.User)
+			}
+		}
+	}
+	return out, nil
 }
 
-// Listen registers a handler called for every incoming private text message.
-func (r *RealSender) Listen(handler func(from, msg string)) error {
+// Listen registers a handler called for every incoming message, private or
+// posted in a group, text or media.
+func (r *RealSender) Listen(handler func(msg IncomingMessage)) error {
 	r.handlers = append(r.handlers, handler)
 	return nil
 }
 
+// ListenReceipts registers a handler called when a sent message is delivered
+// to or read by its recipient.
+func (r *RealSender) ListenReceipts(handler func(rc Receipt)) error {
+	r.receipts = append(r.receipts, handler)
+	return nil
+}
+
 // Close disconnects from WhatsApp and releases the session lock.
 func (r *RealSender) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
 	r.client.Disconnect()
 	releaseLock(r.lockFile)
 	return nil
 }
 
 func (r *RealSender) handleEvent(evt any) {
-	msg, ok := evt.(*events.Message)
-	if !ok {
+	switch e := evt.(type) {
+	case *events.Message:
+		r.handleMessage(e)
+	case *events.Receipt:
+		r.handleReceipt(e)
+	case *events.Connected, *events.KeepAliveRestored:
+		r.setHealth(HealthConnected, "")
+	case *events.Disconnected:
+		go r.reconnect("conexão encerrada pelo servidor")
+	case *events.StreamError:
+		go r.reconnect("erro de stream: " + e.Code)
+	case *events.ConnectFailure:
+		go r.reconnect(fmt.Sprintf("falha ao conectar: %s", e.Message))
+	case *events.KeepAliveTimeout:
+		// The socket may be dead without noticing; force a reconnect after a
+		// few failed pings instead of waiting for TCP to time out.
+		if e.ErrorCount >= 3 {
+			go r.reconnect(fmt.Sprintf("sem resposta desde %s", e.LastSuccess.Format("15:04:05")))
+		}
+	case *events.LoggedOut:
+		go r.repair("sessão encerrada no celular")
+	case *events.StreamReplaced:
+		// Another client took over this session; reconnecting would fight it
+		r.setHealth(HealthLoggedOut, "sessão aberta em outro lugar")
+	case *events.TemporaryBan:
+		r.setHealth(HealthLoggedOut, "banimento temporário: "+e.String())
+	}
+}
+
+func (r *RealSender) handleReceipt(evt *events.Receipt) {
+	var status string
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		status = StatusDelivered
+	case types.ReceiptTypeRead:
+		status = StatusRead
+	default:
+		return // receipts from our own devices, retries, etc.
+	}
+	if evt.IsFromMe || evt.IsGroup {
 		return
 	}
-	if msg.Info.IsFromMe || msg.Info.IsGroup {
+	rc := Receipt{From: evt.Chat.User, Status: status, Timestamp: evt.Timestamp}
+	for _, id := range evt.MessageIDs {
+		rc.MessageIDs = append(rc.MessageIDs, string(id))
+	}
+	for _, h := range r.receipts {
+		h(rc)
+	}
+}
+
+func (r *RealSender) handleMessage(msg *events.Message) {
+	if msg.Info.IsFromMe {
 		return
 	}
 
+	in := IncomingMessage{
+		From:      msg.Info.Sender.User, // phone number without @s.whatsapp.net
+		Timestamp: msg.Info.Timestamp,
+	}
+	if msg.Info.IsGroup {
+		in.Group = msg.Info.Chat.String()
+		// Groups may hide members behind LIDs; the phone comes as SenderAlt
+		if msg.Info.Sender.Server == types.HiddenUserServer && !msg.Info.SenderAlt.IsEmpty() {
+			in.From = msg.Info.SenderAlt.User
+		}
+	}
+
 	// Extract text — handles plain and extended text messages
-	text := msg.Message.GetConversation()
-	if text == "" && msg.Message.GetExtendedTextMessage() != nil {
-		text = msg.Message.GetExtendedTextMessage().GetText()
+	in.Body = msg.Message.GetConversation()
+	if in.Body == "" && msg.Message.GetExtendedTextMessage() != nil {
+		in.Body = msg.Message.GetExtendedTextMessage().GetText()
 	}
-	if text == "" {
-		return
+
+	media, caption, err := r.downloadMedia(msg.Message)
+	switch {
+	case err != nil:
+		fmt.Printf("[WhatsApp] falha ao baixar mídia de %s: %v\n", in.From, err)
+		in.Body = lostMediaBody(caption)
+	case media != nil:
+		in.Media = media
+		in.Body = caption
 	}
 
-	from := msg.Info.Sender.User // phone number without @s.whatsapp.net
+	if in.Body == "" && in.Media == nil {
+		return
+	}
 	for _, h := range r.handlers {
-		h(from, text)
+		h(in)
+	}
+}
+
+// downloadMedia saves the image, document, audio or video carried by a
+// message, if any, and returns it with its caption.
+func (r *RealSender) downloadMedia(m *waE2E.Message) (*Media, string, error) {
+	var (
+		dl      whatsmeow.DownloadableMessage
+		media   Media
+		caption string
+	)
+	switch {
+	case m.GetImageMessage() != nil:
+		im := m.GetImageMessage()
+		dl, caption = im, im.GetCaption()
+		media = Media{Kind: MediaImage, MimeType: im.GetMimetype()}
+	case m.GetDocumentMessage() != nil:
+		doc := m.GetDocumentMessage()
+		dl, caption = doc, doc.GetCaption()
+		media = Media{Kind: MediaDocument, MimeType: doc.GetMimetype(), FileName: doc.GetFileName()}
+	case m.GetAudioMessage() != nil:
+		au := m.GetAudioMessage()
+		dl = au
+		media = Media{Kind: MediaAudio, MimeType: au.GetMimetype()}
+	case m.GetVideoMessage() != nil:
+		vid := m.GetVideoMessage()
+		dl, caption = vid, vid.GetCaption()
+		media = Media{Kind: MediaVideo, MimeType: vid.GetMimetype()}
+	default:
+		return nil, "", nil
+	}
+
+	data, err := r.download(context.Background(), dl)
+	if err != nil {
+		return nil, caption, err
+	}
+	media.Path, err = SaveMedia(r.mediaDir, data, media.MimeType, media.FileName)
+	if err != nil {
+		return nil, caption, err
 	}
+	return &media, caption, nil
 }
 
 // acquireLock creates a PID lock file to prevent concurrent WhatsApp sessions.
@@ -0,0 +1,374 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTelegramAPI is the public Bot API endpoint.
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// TelegramSender implements MessageSender over the Telegram Bot API.
+// Addresses are chat IDs (for a private chat, the user's numeric ID), with or
+// without the "telegram:" prefix; incoming messages are reported from
+// "telegram:<chat id>" so the Router can send replies back the same way.
+type TelegramSender struct {
+	token    string
+	baseURL  string
+	http     *http.Client
+	mediaDir string
+
+	mu       sync.Mutex
+	handlers []func(msg IncomingMessage)
+	health   Health
+	cancel   context.CancelFunc
+}
+
+// NewTelegramSender checks the bot token and starts polling for updates.
+// baseURL is DefaultTelegramAPI unless pointing at a stub (see
+// whatsapptest.TelegramStub).
+func NewTelegramSender(ctx context.Context, token, baseURL, mediaDir string) (*TelegramSender, error) {
+	if baseURL == "" {
+		baseURL = DefaultTelegramAPI
+	}
+	if mediaDir == "" {
+		mediaDir = DefaultMediaDir
+	}
+	t := &TelegramSender{
+		token:    token,
+		baseURL:  baseURL,
+		http:     &http.Client{Timeout: 60 * time.Second},
+		mediaDir: mediaDir,
+		health:   Health{State: HealthConnecting, Since: time.Now()},
+	}
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := t.call(ctx, "getMe", nil, &me); err != nil {
+		return nil, fmt.Errorf("telegram: %w", err)
+	}
+	fmt.Printf("Telegram conectado: @%s\n", me.Username)
+	t.setHealth(HealthConnected, "")
+
+	pollCtx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	go t.poll(pollCtx)
+	return t, nil
+}
+
+func (t *TelegramSender) Send(chat, message string) (string, error) {
+	var msg tgMessage
+	err := t.call(context.Background(), "sendMessage", map[string]any{
+		"chat_id": telegramChat(chat),
+		"text":    message,
+	}, &msg)
+	if err != nil {
+		return "", fmt.Errorf("send to %s: %w", chat, err)
+	}
+	fmt.Printf("[Telegram enviado → %s]\n", telegramChat(chat))
+	return strconv.FormatInt(msg.MessageID, 10), nil
+}
+
+func (t *TelegramSender) SendImage(chat, path, caption string) (string, error) {
+	return t.upload("sendPhoto", "photo", chat, path, caption)
+}
+
+func (t *TelegramSender) SendDocument(chat, path, caption string) (string, error) {
+	return t.upload("sendDocument", "document", chat, path, caption)
+}
+
+// SendTyping shows "digitando..." in the chat; Telegram clears it by itself.
+func (t *TelegramSender) SendTyping(chat string, typing bool) error {
+	if !typing {
+		return nil
+	}
+	return t.call(context.Background(), "sendChatAction", map[string]any{
+		"chat_id": telegramChat(chat),
+		"action":  "typing",
+	}, nil)
+}
+
+func (t *TelegramSender) Listen(handler func(msg IncomingMessage)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, handler)
+	return nil
+}
+
+// ListenReceipts is a no-op: the Bot API does not report delivery or reads.
+func (t *TelegramSender) ListenReceipts(func(r Receipt)) error { return nil }
+
+func (t *TelegramSender) Health() Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.health
+}
+
+func (t *TelegramSender) Close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return nil
+}
+
+func (t *TelegramSender) setHealth(state, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if (state == HealthConnected) != t.health.OK() {
+		t.health.Since = time.Now()
+	}
+	if state == HealthConnected {
+		t.health.Attempts = 0
+	} else {
+		t.health.Attempts++
+	}
+	t.health.State = state
+	t.health.Detail = detail
+}
+
+// poll long-polls getUpdates until ctx is cancelled, backing off on errors.
+func (t *TelegramSender) poll(ctx context.Context) {
+	var offset int64
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		var updates []tgUpdate
+		err := t.call(ctx, "getUpdates", map[string]any{"offset": offset, "timeout": 30}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.setHealth(HealthDisconnected, err.Error())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+		t.setHealth(HealthConnected, "")
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				t.deliver(ctx, u.Message)
+			}
+		}
+	}
+}
+
+func (t *TelegramSender) deliver(ctx context.Context, m *tgMessage) {
+	if m.Chat.Type != "" && m.Chat.Type != "private" {
+		return
+	}
+	in := IncomingMessage{
+		From:      ChannelTelegram + ":" + strconv.FormatInt(m.Chat.ID, 10),
+		Body:      m.Text,
+		Timestamp: time.Unix(m.Date, 0),
+	}
+
+	media, err := t.downloadMedia(ctx, m)
+	switch {
+	case err != nil:
+		fmt.Printf("[Telegram] falha ao baixar mídia de %s: %v\n", in.From, err)
+		in.Body = lostMediaBody(m.Caption)
+	case media != nil:
+		in.Media = media
+		in.Body = m.Caption
+	}
+	if in.Body == "" && in.Media == nil {
+		return
+	}
+
+	t.mu.Lock()
+	handlers := append([]func(IncomingMessage){}, t.handlers...)
+	t.mu.Unlock()
+	for _, h := range handlers {
+		h(in)
+	}
+}
+
+// downloadMedia saves the photo, document, voice note or video of a message.
+func (t *TelegramSender) downloadMedia(ctx context.Context, m *tgMessage) (*Media, error) {
+	var (
+		fileID string
+		media  Media
+	)
+	switch {
+	case len(m.Photo) > 0:
+		fileID = m.Photo[len(m.Photo)-1].FileID // largest size comes last
+		media = Media{Kind: MediaImage, MimeType: "image/jpeg"}
+	case m.Document != nil:
+		fileID = m.Document.FileID
+		media = Media{Kind: MediaDocument, MimeType: m.Document.MimeType, FileName: m.Document.FileName}
+	case m.Voice != nil:
+		fileID = m.Voice.FileID
+		media = Media{Kind: MediaAudio, MimeType: m.Voice.MimeType}
+	case m.Audio != nil:
+		fileID = m.Audio.FileID
+		media = Media{Kind: MediaAudio, MimeType: m.Audio.MimeType, FileName: m.Audio.FileName}
+	case m.Video != nil:
+		fileID = m.Video.FileID
+		media = Media{Kind: MediaVideo, MimeType: m.Video.MimeType}
+	default:
+		return nil, nil
+	}
+
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := t.call(ctx, "getFile", map[string]any{"file_id": fileID}, &file); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/file/bot%s/%s", t.baseURL, t.token, file.FilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if media.FileName == "" {
+		media.FileName = filepath.Base(file.FilePath)
+	}
+	media.Path, err = SaveMedia(t.mediaDir, data, media.MimeType, media.FileName)
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// upload sends a local file with the given Bot API method and field name.
+func (t *TelegramSender) upload(method, field, chat, path, caption string) (string, error) {
+	data, _, err := readMedia(path)
+	if err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("chat_id", telegramChat(chat))
+	if caption != "" {
+		_ = w.WriteField("caption", caption)
+	}
+	part, err := w.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.methodURL(method), &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	var msg tgMessage
+	if err := t.do(req, &msg); err != nil {
+		return "", fmt.Errorf("%s to %s: %w", method, chat, err)
+	}
+	return strconv.FormatInt(msg.MessageID, 10), nil
+}
+
+// call invokes a Bot API method with a JSON body and decodes its result.
+func (t *TelegramSender) call(ctx context.Context, method string, params map[string]any, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.methodURL(method), bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return t.do(req, result)
+}
+
+func (t *TelegramSender) do(req *http.Request, result any) error {
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var env struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("HTTP %d: %w", resp.StatusCode, err)
+	}
+	if !env.OK {
+		return fmt.Errorf("telegram: %s", env.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(env.Result, result)
+}
+
+func (t *TelegramSender) methodURL(method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", t.baseURL, url.PathEscape(t.token), method)
+}
+
+// telegramChat strips the channel prefix from an address.
+func telegramChat(addr string) string {
+	_, id := SplitAddress(addr)
+	return id
+}
+
+// --- Bot API types (only the fields we use) ---
+
+type tgUpdate struct {
+	UpdateID int64      `json:"update_id"`
+	Message  *tgMessage `json:"message,omitempty"`
+}
+
+type tgMessage struct {
+	MessageID int64    `json:"message_id"`
+	Chat      tgChat   `json:"chat"`
+	Date      int64    `json:"date"`
+	Text      string   `json:"text,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Photo     []tgFile `json:"photo,omitempty"`
+	Document  *tgFile  `json:"document,omitempty"`
+	Voice     *tgFile  `json:"voice,omitempty"`
+	Audio     *tgFile  `json:"audio,omitempty"`
+	Video     *tgFile  `json:"video,omitempty"`
+}
+
+type tgChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type tgFile struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
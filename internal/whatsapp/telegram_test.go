@@ -0,0 +1,87 @@
+package whatsapp_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/agente/internal/whatsapp"
+	"github.com/user/agente/internal/whatsapp/whatsapptest"
+)
+
+func newTelegram(t *testing.T) (*whatsapp.TelegramSender, *whatsapptest.TelegramStub, chan whatsapp.IncomingMessage) {
+	t.Helper()
+	stub := whatsapptest.NewTelegramStub()
+	t.Cleanup(stub.Close)
+	tg, err := whatsapp.NewTelegramSender(context.Background(), "token", stub.URL(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tg.Close() })
+	in := make(chan whatsapp.IncomingMessage, 4)
+	_ = tg.Listen(func(m whatsapp.IncomingMessage) { in <- m })
+	return tg, stub, in
+}
+
+func receive(t *testing.T, in chan whatsapp.IncomingMessage) whatsapp.IncomingMessage {
+	t.Helper()
+	select {
+	case m := <-in:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return whatsapp.IncomingMessage{}
+}
+
+func TestTelegramUpdatesBecomeMessages(t *testing.T) {
+	_, stub, in := newTelegram(t)
+
+	stub.Push(42, "arroz 5kg R$ 25")
+	m := receive(t, in)
+	if m.From != "telegram:42" || m.Body != "arroz 5kg R$ 25" || m.Media != nil {
+		t.Errorf("text update: got %+v", m)
+	}
+
+	photo := []byte("\xff\xd8\xff\xe0 jpeg")
+	stub.PushPhoto(42, photo, "tabela de preços")
+	m = receive(t, in)
+	if m.From != "telegram:42" || m.Body != "tabela de preços" {
+		t.Errorf("photo update: got %+v", m)
+	}
+	if m.Media == nil || m.Media.Kind != whatsapp.MediaImage {
+		t.Fatalf("photo update: media %+v", m.Media)
+	}
+	if data, err := os.ReadFile(m.Media.Path); err != nil || !bytes.Equal(data, photo) {
+		t.Errorf("photo saved as %q: %v", m.Media.Path, err)
+	}
+}
+
+func TestTelegramPhotoThatFailsToDownloadKeepsCaption(t *testing.T) {
+	_, stub, in := newTelegram(t)
+
+	stub.PushExpiredPhoto(42, "arroz 5kg R$ 25")
+	m := receive(t, in)
+	if m.Media != nil || !strings.HasPrefix(m.Body, "arroz 5kg R$ 25\n") || !strings.Contains(m.Body, "não pôde ser baixada") {
+		t.Errorf("got %+v, want the caption and a marker", m)
+	}
+}
+
+func TestTelegramSend(t *testing.T) {
+	tg, stub, _ := newTelegram(t)
+
+	id, err := tg.Send("42", "Bom dia! Qual o preço do arroz?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := stub.Sent()
+	if len(sent) != 1 || sent[0].Method != "sendMessage" || sent[0].ChatID != "42" {
+		t.Fatalf("sent %+v", sent)
+	}
+	if id == "" {
+		t.Error("no message ID returned")
+	}
+}
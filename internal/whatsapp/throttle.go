@@ -0,0 +1,409 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a send would exceed a daily cap. Callers
+// should skip (or defer) the message rather than retry right away.
+var ErrRateLimited = errors.New("limite de envio atingido")
+
+// ThrottleConfig controls the pacing of outgoing messages. Zero values disable
+// the corresponding limit.
+type ThrottleConfig struct {
+	PerMinute          int           // max messages in any 60s window
+	PerDay             int           // max messages per calendar day
+	FirstContactPerDay int           // max messages per day to numbers never messaged before
+	MinDelay           time.Duration // random pause between consecutive sends...
+	MaxDelay           time.Duration // ...drawn from [MinDelay, MaxDelay]
+	TypingPerChar      time.Duration // "digitando..." time per character of text
+	MaxTyping          time.Duration // cap for the typing simulation
+	Exempt             []string      // phones (e.g. the owner) that skip delays and first-contact limits
+}
+
+// DefaultThrottleConfig returns conservative limits for a personal WhatsApp
+// number: a few messages a minute with human-like gaps and typing.
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		PerMinute:          6,
+		PerDay:             200,
+		FirstContactPerDay: 20,
+		MinDelay:           8 * time.Second,
+		MaxDelay:           25 * time.Second,
+		TypingPerChar:      40 * time.Millisecond,
+		MaxTyping:          8 * time.Second,
+	}
+}
+
+// PresenceSender is implemented by senders that can show "digitando..." to the
+// recipient before a message is sent.
+type PresenceSender interface {
+	SendTyping(phone string, typing bool) error
+}
+
+// SendLog persists sends so that limits hold across restarts and processes.
+type SendLog interface {
+	Record(phone string, at time.Time, firstContact bool) error
+	CountSince(since time.Time) (int, error)
+	FirstContactsSince(since time.Time) (int, error)
+	Contacted(phone string) (bool, error)
+}
+
+// Throttled wraps any MessageSender and enforces ThrottleConfig on every
+// WhatsApp send. Sends are spaced out one after another, but the lock is only
+// held while booking a slot, so a send waiting its turn does not block
+// exempt sends, Listen or Close. Addresses on other channels pass through.
+type Throttled struct {
+	inner MessageSender
+	cfg   ThrottleConfig
+	log   SendLog
+
+	// ctx is cancelled by Close, which ends any wait in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	lastSend time.Time // when the latest booked send goes out
+	inFlight int       // sends booked but not yet in the log
+	inFirst  int       // ...of which first contacts
+	rng      *rand.Rand
+
+	// Now and Sleep default to the wall clock; the simulator swaps them for
+	// virtual time.
+	Now   func() time.Time
+	Sleep func(time.Duration)
+}
+
+// NewThrottled wraps inner with the given limits.
+func NewThrottled(inner MessageSender, cfg ThrottleConfig, log SendLog) *Throttled {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Throttled{
+		inner:  inner,
+		cfg:    cfg,
+		log:    log,
+		ctx:    ctx,
+		cancel: cancel,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		Now:    time.Now,
+	}
+	t.Sleep = t.sleep
+	return t
+}
+
+// Unwrap returns the underlying sender.
+func (t *Throttled) Unwrap() MessageSender { return t.inner }
+
+func (t *Throttled) Send(phone, message string) (string, error) {
+	return t.paced(phone, len([]rune(message)), func() (string, error) {
+		return t.inner.Send(phone, message)
+	})
+}
+
+func (t *Throttled) SendImage(phone, path, caption string) (string, error) {
+	return t.paced(phone, 0, func() (string, error) {
+		return t.inner.SendImage(phone, path, caption)
+	})
+}
+
+func (t *Throttled) SendDocument(phone, path, caption string) (string, error) {
+	return t.paced(phone, 0, func() (string, error) {
+		return t.inner.SendDocument(phone, path, caption)
+	})
+}
+
+func (t *Throttled) Listen(handler func(msg IncomingMessage)) error {
+	return t.inner.Listen(handler)
+}
+
+func (t *Throttled) ListenReceipts(handler func(r Receipt)) error {
+	return t.inner.ListenReceipts(handler)
+}
+
+func (t *Throttled) Health() Health { return t.inner.Health() }
+
+// Close cancels the sends still waiting their turn and closes the inner
+// sender.
+func (t *Throttled) Close() error {
+	t.cancel()
+	return t.inner.Close()
+}
+
+// paced books a slot within the caps, waits the human-like delay, shows
+// typing for textLen characters and then runs send.
+func (t *Throttled) paced(phone string, textLen int, send func() (string, error)) (string, error) {
+	// The limits protect the WhatsApp number; other channels have no such risk
+	if ch, _ := SplitAddress(phone); ch != ChannelWhatsApp {
+		return send()
+	}
+
+	exempt := t.isExempt(phone)
+	var first bool
+	var at time.Time
+	for {
+		var full bool
+		var err error
+		first, at, full, err = t.book(phone, exempt)
+		if err != nil {
+			return "", err
+		}
+		if !full {
+			break
+		}
+		// Per-minute window: wait until the oldest send in it expires
+		if err := t.wait(5 * time.Second); err != nil {
+			return "", err
+		}
+	}
+	defer t.release(first)
+
+	if err := t.wait(at.Sub(t.Now())); err != nil {
+		return "", err
+	}
+	if !exempt {
+		if err := t.typing(phone, textLen); err != nil {
+			return "", err
+		}
+	}
+
+	id, err := send()
+	if err != nil {
+		return "", err
+	}
+	now := t.Now()
+	t.mu.Lock()
+	if now.After(t.lastSend) {
+		t.lastSend = now
+	}
+	t.mu.Unlock()
+	return id, t.log.Record(phone, now, first)
+}
+
+// book checks the caps and reserves the next send slot for phone. It
+// returns whether phone is a first contact and when the send may go out, or
+// full if the per-minute window has no room yet. Sends booked but not yet
+// recorded count against the caps.
+func (t *Throttled) book(phone string, exempt bool) (first bool, at time.Time, full bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if t.cfg.PerDay > 0 {
+		n, err := t.log.CountSince(dayStart)
+		if err != nil {
+			return false, now, false, err
+		}
+		if n += t.inFlight; n >= t.cfg.PerDay {
+			return false, now, false, fmt.Errorf("%w: %d mensagens hoje", ErrRateLimited, n)
+		}
+	}
+
+	if !exempt {
+		contacted, err := t.log.Contacted(phone)
+		if err != nil {
+			return false, now, false, err
+		}
+		first = !contacted
+		if first && t.cfg.FirstContactPerDay > 0 {
+			n, err := t.log.FirstContactsSince(dayStart)
+			if err != nil {
+				return false, now, false, err
+			}
+			if n += t.inFirst; n >= t.cfg.FirstContactPerDay {
+				return false, now, false, fmt.Errorf("%w: %d primeiros contatos hoje", ErrRateLimited, n)
+			}
+		}
+	}
+
+	if t.cfg.PerMinute > 0 {
+		n, err := t.log.CountSince(now.Add(-time.Minute))
+		if err != nil {
+			return false, now, false, err
+		}
+		if n+t.inFlight >= t.cfg.PerMinute {
+			return false, now, true, nil
+		}
+	}
+
+	at = now
+	if !exempt {
+		if next := t.lastSend.Add(t.delay()); !t.lastSend.IsZero() && next.After(at) {
+			at = next
+		}
+		t.lastSend = at
+	}
+	t.inFlight++
+	if first {
+		t.inFirst++
+	}
+	return first, at, false, nil
+}
+
+// release drops a booked send from the in-flight counts once it is in the
+// log (or failed).
+func (t *Throttled) release(first bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if first {
+		t.inFirst--
+	}
+}
+
+// wait sleeps d, failing if the sender was closed meanwhile.
+func (t *Throttled) wait(d time.Duration) error {
+	if d > 0 {
+		t.Sleep(d)
+	}
+	if err := t.ctx.Err(); err != nil {
+		return fmt.Errorf("envio cancelado: %w", err)
+	}
+	return nil
+}
+
+// sleep is the default Sleep: the wall clock, cut short by Close.
+func (t *Throttled) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-t.ctx.Done():
+	}
+}
+
+// delay draws the random gap between two sends.
+func (t *Throttled) delay() time.Duration {
+	if t.cfg.MaxDelay <= t.cfg.MinDelay {
+		return t.cfg.MinDelay
+	}
+	return t.cfg.MinDelay + time.Duration(t.rng.Int63n(int64(t.cfg.MaxDelay-t.cfg.MinDelay)))
+}
+
+// typing shows "digitando..." for a time proportional to the text length.
+func (t *Throttled) typing(phone string, textLen int) error {
+	ps, ok := t.inner.(PresenceSender)
+	if !ok || textLen == 0 || t.cfg.TypingPerChar <= 0 {
+		return nil
+	}
+	d := time.Duration(textLen) * t.cfg.TypingPerChar
+	if t.cfg.MaxTyping > 0 && d > t.cfg.MaxTyping {
+		d = t.cfg.MaxTyping
+	}
+	if err := ps.SendTyping(phone, true); err != nil {
+		return nil // presence is cosmetic; never block a send on it
+	}
+	err := t.wait(d)
+	_ = ps.SendTyping(phone, false)
+	return err
+}
+
+func (t *Throttled) isExempt(phone string) bool {
+	p := normalizePhone(phone)
+	for _, e := range t.cfg.Exempt {
+		if normalizePhone(e) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// --- SQLite send log ---
+
+// SQLSendLog stores sends in the send_log table (see internal/db).
+type SQLSendLog struct {
+	db *sql.DB
+}
+
+// NewSQLSendLog creates a send log backed by db.
+func NewSQLSendLog(db *sql.DB) *SQLSendLog {
+	return &SQLSendLog{db: db}
+}
+
+func (l *SQLSendLog) Record(phone string, at time.Time, firstContact bool) error {
+	_, err := l.db.Exec(
+		`INSERT INTO send_log (phone, sent_at, first_contact) VALUES (?, ?, ?)`,
+		normalizePhone(phone), at, firstContact,
+	)
+	return err
+}
+
+func (l *SQLSendLog) CountSince(since time.Time) (int, error) {
+	var n int
+	err := l.db.QueryRow(`SELECT COUNT(*) FROM send_log WHERE sent_at >= ?`, since).Scan(&n)
+	return n, err
+}
+
+func (l *SQLSendLog) FirstContactsSince(since time.Time) (int, error) {
+	var n int
+	err := l.db.QueryRow(
+		`SELECT COUNT(*) FROM send_log WHERE first_contact = 1 AND sent_at >= ?`, since,
+	).Scan(&n)
+	return n, err
+}
+
+func (l *SQLSendLog) Contacted(phone string) (bool, error) {
+	var n int
+	err := l.db.QueryRow(
+		`SELECT COUNT(*) FROM send_log WHERE phone = ?`, normalizePhone(phone),
+	).Scan(&n)
+	return n > 0, err
+}
+
+// --- In-memory send log ---
+
+// MemorySendLog keeps sends in memory; used in dry-run so simulated sends do
+// not count against the real number.
+type MemorySendLog struct {
+	mu      sync.Mutex
+	entries []sendEntry
+}
+
+type sendEntry struct {
+	phone string
+	at    time.Time
+	first bool
+}
+
+// NewMemorySendLog creates an empty in-memory send log.
+func NewMemorySendLog() *MemorySendLog {
+	return &MemorySendLog{}
+}
+
+func (l *MemorySendLog) Record(phone string, at time.Time, firstContact bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, sendEntry{normalizePhone(phone), at, firstContact})
+	return nil
+}
+
+func (l *MemorySendLog) CountSince(since time.Time) (int, error) {
+	return l.count(func(e sendEntry) bool { return !e.at.Before(since) }), nil
+}
+
+func (l *MemorySendLog) FirstContactsSince(since time.Time) (int, error) {
+	return l.count(func(e sendEntry) bool { return e.first && !e.at.Before(since) }), nil
+}
+
+func (l *MemorySendLog) Contacted(phone string) (bool, error) {
+	p := normalizePhone(phone)
+	return l.count(func(e sendEntry) bool { return e.phone == p }) > 0, nil
+}
+
+func (l *MemorySendLog) count(match func(sendEntry) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.entries {
+		if match(e) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThrottledWaitDoesNotBlockOtherSends(t *testing.T) {
+	inner := NewMockSender()
+	th := NewThrottled(inner, ThrottleConfig{
+		MinDelay: time.Hour,
+		MaxDelay: time.Hour,
+		Exempt:   []string{"5567999990000"},
+	}, NewMemorySendLog())
+
+	if _, err := th.Send("5567911110000", "primeiro"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second supplier waits an hour for its turn
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := th.Send("5567922220000", "segundo")
+		waiting <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// ...while the owner, exempt from the delay, is not held up by it
+	done := make(chan error, 1)
+	go func() {
+		_, err := th.Send("5567999990000", "dono")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("exempt send blocked behind a waiting one")
+	}
+
+	// Close ends the wait instead of sleeping it out
+	th.Close()
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("waiting send after Close: got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not interrupt the waiting send")
+	}
+
+	if len(inner.Sent) != 2 || inner.Sent[1].Phone != "5567999990000" {
+		t.Errorf("sent %+v, want the first supplier and the owner only", inner.Sent)
+	}
+}
+
+func TestThrottledCountsBookedSendsAgainstCaps(t *testing.T) {
+	th := NewThrottled(NewMockSender(), ThrottleConfig{
+		PerDay:   1,
+		MinDelay: time.Hour,
+		MaxDelay: time.Hour,
+	}, NewMemorySendLog())
+
+	if _, err := th.Send("5567911110000", "primeiro"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := th.Send("5567922220000", "segundo"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("over the daily cap: got %v, want ErrRateLimited", err)
+	}
+	th.Close()
+}
@@ -0,0 +1,269 @@
+package whatsapptest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloudStub is a local fake of the Graph API, enough for
+// whatsapp.CloudSender to run against without a Meta account: it records
+// what is sent, stores uploaded media and posts signed webhook notifications
+// (incoming messages, status callbacks) to the sender. Point
+// whatsapp.CloudConfig.APIURL at URL().
+type CloudStub struct {
+	srv     *httptest.Server
+	webhook string // CloudSender webhook URL
+	secret  string // app secret used to sign notifications
+
+	mu     sync.Mutex
+	nextID int
+	media  map[string]stubMedia
+	sent   []CloudSent
+}
+
+type stubMedia struct {
+	data     []byte
+	mimeType string
+}
+
+// CloudSent is a message the stub received from the sender.
+type CloudSent struct {
+	ID       string // message ID returned to the sender
+	To       string
+	Type     string // text, template, image, document...
+	Text     string // body, template parameter or caption
+	Template string
+	MediaID  string
+}
+
+// NewCloudStub starts the fake Graph API on a random local port. Webhook
+// notifications go to webhookURL, signed with appSecret.
+func NewCloudStub(webhookURL, appSecret string) *CloudStub {
+	s := &CloudStub{webhook: webhookURL, secret: appSecret, media: map[string]stubMedia{}}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// URL is the base URL to use as whatsapp.CloudConfig.APIURL.
+func (s *CloudStub) URL() string { return s.srv.URL }
+
+// Close stops the server.
+func (s *CloudStub) Close() { s.srv.Close() }
+
+// Sent returns a copy of everything sent so far.
+func (s *CloudStub) Sent() []CloudSent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CloudSent(nil), s.sent...)
+}
+
+// PushText notifies the sender of a text message from phone.
+func (s *CloudStub) PushText(from, text string) error {
+	return s.notify(map[string]any{"messages": []any{map[string]any{
+		"from": from, "id": s.id("wamid.in"), "timestamp": unixNow(),
+		"type": "text", "text": map[string]string{"body": text},
+	}}})
+}
+
+// PushMedia notifies the sender of a media message (kind is one of the
+// whatsapp.Media kinds) from phone; the file is served back when the sender
+// downloads it.
+func (s *CloudStub) PushMedia(from, kind string, data []byte, mimeType, fileName, caption string) error {
+	mediaID := s.store(data, mimeType)
+	return s.notify(map[string]any{"messages": []any{map[string]any{
+		"from": from, "id": s.id("wamid.in"), "timestamp": unixNow(), "type": kind,
+		kind: map[string]string{"id": mediaID, "mime_type": mimeType, "filename": fileName, "caption": caption},
+	}}})
+}
+
+// PushExpiredMedia notifies the sender of a media message from phone whose
+// file is no longer available, so downloading it fails.
+func (s *CloudStub) PushExpiredMedia(from, kind, mimeType, caption string) error {
+	return s.notify(map[string]any{"messages": []any{map[string]any{
+		"from": from, "id": s.id("wamid.in"), "timestamp": unixNow(), "type": kind,
+		kind: map[string]string{"id": "expired", "mime_type": mimeType, "caption": caption},
+	}}})
+}
+
+// PushStatus notifies the sender that a message it sent changed status
+// (sent, delivered, read or failed).
+func (s *CloudStub) PushStatus(messageID, recipient, status string) error {
+	return s.notify(map[string]any{"statuses": []any{map[string]any{
+		"id": messageID, "status": status, "timestamp": unixNow(), "recipient_id": recipient,
+	}}})
+}
+
+func (s *CloudStub) notify(value map[string]any) error {
+	body, err := json.Marshal(map[string]any{
+		"object": "whatsapp_business_account",
+		"entry": []any{map[string]any{
+			"id":      "stub",
+			"changes": []any{map[string]any{"field": "messages", "value": value}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", Signature(s.secret, body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *CloudStub) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		graphError(w, http.StatusUnauthorized, 190, "missing access token")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 2 && parts[0] == "download":
+		s.mu.Lock()
+		m, ok := s.media[parts[1]]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", m.mimeType)
+		_, _ = w.Write(m.data)
+
+	case len(parts) == 2 && parts[1] == "messages" && r.Method == http.MethodPost:
+		var msg struct {
+			To       string                `json:"to"`
+			Type     string                `json:"type"`
+			Text     struct{ Body string } `json:"text"`
+			Template struct {
+				Name       string `json:"name"`
+				Components []struct {
+					Parameters []struct{ Text string } `json:"parameters"`
+				} `json:"components"`
+			} `json:"template"`
+			Image    *cloudMedia `json:"image"`
+			Document *cloudMedia `json:"document"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			graphError(w, http.StatusBadRequest, 100, err.Error())
+			return
+		}
+		sent := CloudSent{ID: s.id("wamid.out"), To: msg.To, Type: msg.Type, Text: msg.Text.Body}
+		switch {
+		case msg.Type == "template":
+			sent.Template = msg.Template.Name
+			for _, c := range msg.Template.Components {
+				for _, p := range c.Parameters {
+					sent.Text += p.Text
+				}
+			}
+		case msg.Image != nil:
+			sent.MediaID, sent.Text = msg.Image.ID, msg.Image.Caption
+		case msg.Document != nil:
+			sent.MediaID, sent.Text = msg.Document.ID, msg.Document.Caption
+		}
+		s.mu.Lock()
+		s.sent = append(s.sent, sent)
+		s.mu.Unlock()
+		graphReply(w, map[string]any{
+			"messaging_product": "whatsapp",
+			"contacts":          []any{map[string]string{"input": msg.To, "wa_id": msg.To}},
+			"messages":          []any{map[string]string{"id": sent.ID}},
+		})
+
+	case len(parts) == 2 && parts[1] == "media" && r.Method == http.MethodPost:
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			graphError(w, http.StatusBadRequest, 100, err.Error())
+			return
+		}
+		data, _ := io.ReadAll(file)
+		file.Close()
+		mimeType := r.FormValue("type")
+		if mimeType == "" {
+			mimeType = header.Header.Get("Content-Type")
+		}
+		graphReply(w, map[string]string{"id": s.store(data, mimeType)})
+
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.mu.Lock()
+		m, ok := s.media[parts[0]]
+		s.mu.Unlock()
+		if ok {
+			graphReply(w, map[string]string{
+				"id": parts[0], "mime_type": m.mimeType, "url": s.srv.URL + "/download/" + parts[0],
+			})
+			return
+		}
+		// Anything else is taken as the business phone number.
+		graphReply(w, map[string]string{
+			"id": parts[0], "display_phone_number": "+55 67 0000-0000", "verified_name": "Stub",
+		})
+
+	default:
+		graphError(w, http.StatusNotFound, 100, "unsupported path "+r.URL.Path)
+	}
+}
+
+func (s *CloudStub) store(data []byte, mimeType string) string {
+	id := s.id("media")
+	s.mu.Lock()
+	s.media[id] = stubMedia{data: data, mimeType: mimeType}
+	s.mu.Unlock()
+	return id
+}
+
+func (s *CloudStub) id(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	return fmt.Sprintf("%s.%06d", prefix, s.nextID)
+}
+
+// Signature is the X-Hub-Signature-256 header Meta sends with a webhook
+// body: its HMAC-SHA256 under the app secret.
+func Signature(appSecret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// cloudMedia is the media object of a Graph API message.
+type cloudMedia struct {
+	ID      string `json:"id"`
+	Caption string `json:"caption,omitempty"`
+}
+
+func unixNow() string { return strconv.FormatInt(time.Now().Unix(), 10) }
+
+func graphReply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func graphError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": msg, "code": code}})
+}
@@ -0,0 +1,216 @@
+// Package whatsapptest provides local fakes of the Telegram Bot API and the
+// WhatsApp Cloud API, for tests and demos of the senders in package
+// whatsapp without real accounts.
+package whatsapptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TelegramStub is a local fake of the Bot API, enough for
+// whatsapp.TelegramSender to run against without a real bot: it records what
+// is sent and lets a demo or test push incoming messages. Point
+// whatsapp.NewTelegramSender at URL().
+type TelegramStub struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	nextID  int64
+	updates []tgUpdate
+	files   map[string][]byte
+	sent    []TelegramSent
+	notify  chan struct{}
+}
+
+// TelegramSent is a message received by the stub from the bot.
+type TelegramSent struct {
+	Method  string // sendMessage, sendPhoto, sendDocument
+	ChatID  string
+	Text    string // text or caption
+	File    string // uploaded file name, for media
+	Message int64  // message_id returned to the sender
+}
+
+// NewTelegramStub starts the fake Bot API on a random local port.
+func NewTelegramStub() *TelegramStub {
+	s := &TelegramStub{files: map[string][]byte{}, notify: make(chan struct{}, 1)}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// URL is the base URL to pass to whatsapp.NewTelegramSender.
+func (s *TelegramStub) URL() string { return s.srv.URL }
+
+// Close stops the server.
+func (s *TelegramStub) Close() { s.srv.Close() }
+
+// Sent returns a copy of everything the bot sent so far.
+func (s *TelegramStub) Sent() []TelegramSent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]TelegramSent(nil), s.sent...)
+}
+
+// Push queues a text message from chatID to the bot.
+func (s *TelegramStub) Push(chatID int64, text string) {
+	s.push(tgMessage{Chat: tgChat{ID: chatID, Type: "private"}, Text: text})
+}
+
+// PushPhoto queues a photo with caption from chatID to the bot.
+func (s *TelegramStub) PushPhoto(chatID int64, data []byte, caption string) {
+	s.mu.Lock()
+	fileID := fmt.Sprintf("file%d", len(s.files)+1)
+	s.files[fileID] = data
+	s.mu.Unlock()
+	s.push(tgMessage{
+		Chat:    tgChat{ID: chatID, Type: "private"},
+		Caption: caption,
+		Photo:   []tgFile{{FileID: fileID}},
+	})
+}
+
+// PushExpiredPhoto queues a photo with caption whose file is no longer
+// available, so downloading it fails.
+func (s *TelegramStub) PushExpiredPhoto(chatID int64, caption string) {
+	s.push(tgMessage{
+		Chat:    tgChat{ID: chatID, Type: "private"},
+		Caption: caption,
+		Photo:   []tgFile{{FileID: "expired"}},
+	})
+}
+
+func (s *TelegramStub) push(m tgMessage) {
+	s.mu.Lock()
+	s.nextID++
+	m.MessageID = s.nextID
+	m.Date = time.Now().Unix()
+	s.updates = append(s.updates, tgUpdate{UpdateID: s.nextID, Message: &m})
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *TelegramStub) serve(w http.ResponseWriter, r *http.Request) {
+	// /file/bot<token>/<file_id>
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		fileID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		s.mu.Lock()
+		data, ok := s.files[fileID]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+		return
+	}
+
+	// /bot<token>/<method>
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params := map[string]string{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err == nil {
+			for k, v := range r.MultipartForm.Value {
+				params[k] = v[0]
+			}
+			for k, v := range r.MultipartForm.File {
+				params[k] = v[0].Filename
+			}
+		}
+	} else {
+		var raw map[string]any
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &raw)
+		for k, v := range raw {
+			params[k] = fmt.Sprint(v)
+		}
+	}
+
+	switch method {
+	case "getMe":
+		reply(w, map[string]any{"id": 1, "is_bot": true, "username": "stub_bot"})
+	case "getUpdates":
+		offset, _ := strconv.ParseInt(params["offset"], 10, 64)
+		reply(w, s.waitUpdates(r, offset))
+	case "sendMessage", "sendPhoto", "sendDocument":
+		s.mu.Lock()
+		s.nextID++
+		sent := TelegramSent{Method: method, ChatID: params["chat_id"], Text: params["text"] + params["caption"],
+			File: params["photo"] + params["document"], Message: s.nextID}
+		s.sent = append(s.sent, sent)
+		s.mu.Unlock()
+		chatID, _ := strconv.ParseInt(sent.ChatID, 10, 64)
+		reply(w, map[string]any{"message_id": sent.Message, "chat": map[string]any{"id": chatID}})
+	case "sendChatAction":
+		reply(w, true)
+	case "getFile":
+		reply(w, map[string]any{"file_id": params["file_id"], "file_path": params["file_id"]})
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "description": "method not found: " + method})
+	}
+}
+
+// waitUpdates returns updates from offset on, waiting briefly for one to
+// arrive like the real long poll does.
+func (s *TelegramStub) waitUpdates(r *http.Request, offset int64) []tgUpdate {
+	deadline := time.After(2 * time.Second)
+	for {
+		s.mu.Lock()
+		var out []tgUpdate
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				out = append(out, u)
+			}
+		}
+		s.mu.Unlock()
+		if len(out) > 0 {
+			return out
+		}
+		select {
+		case <-s.notify:
+		case <-deadline:
+			return []tgUpdate{}
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Bot API objects, as much of them as the stub serves.
+type tgUpdate struct {
+	UpdateID int64      `json:"update_id"`
+	Message  *tgMessage `json:"message,omitempty"`
+}
+
+type tgMessage struct {
+	MessageID int64    `json:"message_id"`
+	Chat      tgChat   `json:"chat"`
+	Date      int64    `json:"date"`
+	Text      string   `json:"text,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Photo     []tgFile `json:"photo,omitempty"`
+}
+
+type tgChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type tgFile struct {
+	FileID string `json:"file_id"`
+}
+
+func reply(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
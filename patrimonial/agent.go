@@ -160,34 +160,70 @@ func (a *Agent) AddMaintenance(assetID string, rec assets.MaintenanceRecord) err
 // issue is an optional description of the problem; if empty and the automatic
 // assessment returns no items, the user is prompted interactively.
 func (a *Agent) Buy(ctx context.Context, assetID, issue string) error {
+	asset, items, err := a.PlanPurchase(ctx, assetID, issue)
+	if err != nil {
+		return err
+	}
+
+	if len(items) == 0 && issue == "" {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Printf("Nenhum item automático para %s. Descreva o que precisa: ", asset.Name)
+		issue, _ = reader.ReadString('\n')
+		issue = strings.TrimSpace(issue)
+
+		items, err = a.predictor.ProcurementList(ctx, *asset, issue)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = a.trigger.Buy(asset.Name, items, asset.Photos)
+	return err
+}
+
+// PlanPurchase assesses an asset and returns the items it needs, without
+// prompting. When the assessment finds nothing and issue is set, the list is
+// derived from the issue instead.
+func (a *Agent) PlanPurchase(ctx context.Context, assetID, issue string) (*assets.Asset, []string, error) {
 	asset, err := a.store.Get(assetID)
 	if err != nil || asset == nil {
-		return fmt.Errorf("ativo não encontrado: %s", assetID)
+		return nil, nil, fmt.Errorf("ativo não encontrado: %s", assetID)
 	}
 
 	hist, _ := a.store.MaintenanceHistory(assetID)
 	assessment, err := a.predictor.AssessAsset(ctx, *asset, hist)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	if len(assessment.ProcureList) == 0 {
-		if issue == "" {
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Printf("Nenhum item automático para %s. Descreva o que precisa: ", asset.Name)
-			issue, _ = reader.ReadString('\n')
-			issue = strings.TrimSpace(issue)
-		}
-
-		items, err := a.predictor.ProcurementList(ctx, *asset, issue)
+	items := assessment.ProcureList
+	if len(items) == 0 && issue != "" {
+		items, err = a.predictor.ProcurementList(ctx, *asset, issue)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		assessment.ProcureList = items
 	}
+	return asset, items, nil
+}
 
-	_, err = a.trigger.Buy(asset.Name, assessment.ProcureList)
-	return err
+// Assets returns all tracked assets.
+func (a *Agent) Assets() ([]assets.Asset, error) {
+	return a.store.List()
+}
+
+// Assess returns the risk assessment of every asset, most urgent first.
+func (a *Agent) Assess(ctx context.Context) ([]assets.RiskAssessment, error) {
+	return a.predictor.AssessAll(ctx)
+}
+
+// AssessOne returns the risk assessment of a single asset.
+func (a *Agent) AssessOne(ctx context.Context, assetID string) (*assets.RiskAssessment, error) {
+	asset, err := a.store.Get(assetID)
+	if err != nil || asset == nil {
+		return nil, fmt.Errorf("ativo não encontrado: %s", assetID)
+	}
+	hist, _ := a.store.MaintenanceHistory(assetID)
+	return a.predictor.AssessAsset(ctx, *asset, hist)
 }
 
 func scoreIndicator(score int) string {
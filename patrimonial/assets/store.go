@@ -20,6 +20,7 @@ type Asset struct {
 	Location   string
 	Metadata   map[string]any
 	Notes      string
+	Photos     []string // local image paths, attached to quote requests for this asset
 }
 
 // MaintenanceRecord records a maintenance event for an asset.
@@ -49,15 +50,16 @@ func (s *Store) Add(a Asset) (string, error) {
 		a.ID = uuid.New().String()
 	}
 	meta, _ := json.Marshal(a.Metadata)
+	photos, _ := json.Marshal(append([]string{}, a.Photos...))
 	var acquiredAt any
 	if a.AcquiredAt != nil {
 		acquiredAt = a.AcquiredAt.Format("2006-01-02")
 	}
 
 	_, err := s.db.Exec(
-		`INSERT INTO assets (id, name, type, brand, model, acquired_at, location, metadata, notes)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		a.ID, a.Name, a.Type, a.Brand, a.Model, acquiredAt, a.Location, string(meta), a.Notes,
+		`INSERT INTO assets (id, name, type, brand, model, acquired_at, location, metadata, notes, photos)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.Name, a.Type, a.Brand, a.Model, acquiredAt, a.Location, string(meta), a.Notes, string(photos),
 	)
 	if err != nil {
 		return "", fmt.Errorf("insert asset: %w", err)
@@ -69,7 +71,7 @@ func (s *Store) Add(a Asset) (string, error) {
 func (s *Store) List() ([]Asset, error) {
 	rows, err := s.db.Query(
 		`SELECT id, name, type, COALESCE(brand,''), COALESCE(model,''),
-		 COALESCE(acquired_at,''), COALESCE(location,''), metadata, COALESCE(notes,'')
+		 COALESCE(acquired_at,''), COALESCE(location,''), metadata, COALESCE(notes,''), photos
 		 FROM assets ORDER BY name`,
 	)
 	if err != nil {
@@ -92,7 +94,7 @@ func (s *Store) List() ([]Asset, error) {
 func (s *Store) Get(id string) (*Asset, error) {
 	row := s.db.QueryRow(
 		`SELECT id, name, type, COALESCE(brand,''), COALESCE(model,''),
-		 COALESCE(acquired_at,''), COALESCE(location,''), metadata, COALESCE(notes,'')
+		 COALESCE(acquired_at,''), COALESCE(location,''), metadata, COALESCE(notes,''), photos
 		 FROM assets WHERE id = ?`, id,
 	)
 	return scanAssetRow(row)
@@ -108,9 +110,9 @@ func scanAsset(r rowScanner) (*Asset, error) {
 
 func scanAssetRow(r rowScanner) (*Asset, error) {
 	var a Asset
-	var metaJSON, acquiredStr string
+	var metaJSON, acquiredStr, photosJSON string
 	err := r.Scan(&a.ID, &a.Name, &a.Type, &a.Brand, &a.Model,
-		&acquiredStr, &a.Location, &metaJSON, &a.Notes)
+		&acquiredStr, &a.Location, &metaJSON, &a.Notes, &photosJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +123,7 @@ func scanAssetRow(r rowScanner) (*Asset, error) {
 	if err := json.Unmarshal([]byte(metaJSON), &a.Metadata); err != nil {
 		a.Metadata = make(map[string]any)
 	}
+	_ = json.Unmarshal([]byte(photosJSON), &a.Photos)
 	return &a, nil
 }
 
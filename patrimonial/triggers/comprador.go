@@ -21,13 +21,18 @@ type TriggerResult struct {
 }
 
 // Buy triggers the Comprador to quote items needed for an asset.
-func (t *CompradorTrigger) Buy(assetName string, items []string) (*TriggerResult, error) {
+// photos of the asset, if any, are attached to the quote with --image.
+func (t *CompradorTrigger) Buy(assetName string, items, photos []string) (*TriggerResult, error) {
 	if len(items) == 0 {
 		return nil, fmt.Errorf("nenhum item para comprar")
 	}
 
 	description := fmt.Sprintf("Para %s: %s", assetName, strings.Join(items, ", "))
-	cmd := fmt.Sprintf("comprador quote %q", description)
+	cmd := "comprador quote"
+	for _, p := range photos {
+		cmd += fmt.Sprintf(" --image %q", p)
+	}
+	cmd += fmt.Sprintf(" %q", description)
 
 	fmt.Println("\n=== Acionar Comprador ===")
 	fmt.Printf("Execute o comando abaixo para solicitar cotação:\n\n  %s\n\n", cmd)